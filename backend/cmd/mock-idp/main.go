@@ -0,0 +1,305 @@
+// Command mock-idp is a minimal OpenID Connect and CAS identity provider for trying out and checking SSO login locally.
+// It signs in anyone with the username and groups typed into its login form. Never expose it outside a development machine.
+//
+//	MOCK_IDP_PORT           default 9090
+//	MOCK_IDP_ISSUER         default http://localhost:9090
+//	MOCK_IDP_CLIENT_ID      default advice
+//	MOCK_IDP_CLIENT_SECRET  default secret
+package main
+
+import (
+	"advice/utils"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mock-key"
+
+// login is a signed in user waiting for its authorization code or service ticket to be redeemed
+type login struct {
+	Username    string
+	Groups      []string
+	ClientID    string
+	RedirectURI string // OIDC redirect URI or CAS service URL
+	Nonce       string
+	Challenge   string
+	ExpiresAt   time.Time
+}
+
+type server struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+
+	mu     sync.Mutex
+	logins map[string]login // Authorization codes and service tickets
+}
+
+var loginForm = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Mock IdP</title></head>
+<body>
+<h1>Mock {{.Protocol}} login</h1>
+<form method="post">
+{{range $name, $value := .Hidden}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<p><label>Username <input name="username" required autofocus></label></p>
+<p><label>Groups (comma separated) <input name="groups"></label></p>
+<p><button type="submit">Sign in</button></p>
+</form>
+</body></html>`))
+
+func main() {
+	port := utils.Getenv("MOCK_IDP_PORT", "9090")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal("Failed to generate signing key:", err)
+	}
+	s := &server{
+		issuer:       strings.TrimRight(utils.Getenv("MOCK_IDP_ISSUER", "http://localhost:"+port), "/"),
+		clientID:     utils.Getenv("MOCK_IDP_CLIENT_ID", "advice"),
+		clientSecret: utils.Getenv("MOCK_IDP_CLIENT_SECRET", "secret"),
+		key:          key,
+		logins:       map[string]login{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/cas/login", s.casLogin)
+	mux.HandleFunc("/cas/p3/serviceValidate", s.casValidate)
+
+	log.Printf("Mock identity provider listening on :%s, issuer %s", port, s.issuer)
+	log.Fatal(http.ListenAndServe(":"+port, mux))
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func splitGroups(raw string) []string {
+	groups := []string{}
+	for _, g := range strings.Split(raw, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// redeem returns a login by its code or ticket, which can only be used once
+func (s *server) redeem(code string) (login, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.logins[code]
+	delete(s.logins, code)
+	return l, ok && time.Now().Before(l.ExpiresAt)
+}
+
+func (s *server) store(prefix string, l login) string {
+	code := prefix + randomString()
+	l.ExpiresAt = time.Now().Add(time.Minute)
+	s.mu.Lock()
+	s.logins[code] = l
+	s.mu.Unlock()
+	return code
+}
+
+func (s *server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *server) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": keyID,
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *server) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("client_id") != s.clientID || r.Form.Get("response_type") != "code" || r.Form.Get("redirect_uri") == "" {
+		http.Error(w, "unknown client, missing redirect_uri or unsupported response_type", http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("code_challenge") == "" || r.Form.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		hidden := map[string]string{}
+		for _, name := range []string{"client_id", "response_type", "redirect_uri", "state", "nonce", "code_challenge", "code_challenge_method"} {
+			hidden[name] = r.Form.Get(name)
+		}
+		loginForm.Execute(w, map[string]interface{}{"Protocol": "OIDC", "Hidden": hidden})
+		return
+	}
+
+	code := s.store("", login{
+		Username:    strings.TrimSpace(r.PostForm.Get("username")),
+		Groups:      splitGroups(r.PostForm.Get("groups")),
+		ClientID:    r.Form.Get("client_id"),
+		RedirectURI: r.Form.Get("redirect_uri"),
+		Nonce:       r.Form.Get("nonce"),
+		Challenge:   r.Form.Get("code_challenge"),
+	})
+	query := url.Values{"code": {code}, "state": {r.Form.Get("state")}}
+	http.Redirect(w, r, r.Form.Get("redirect_uri")+"?"+query.Encode(), http.StatusFound)
+}
+
+func (s *server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != s.clientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(s.clientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	l, ok := s.redeem(r.PostForm.Get("code"))
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || l.ClientID != clientID || l.RedirectURI != r.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != l.Challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                s.issuer,
+		"aud":                clientID,
+		"sub":                "mock-" + l.Username,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              l.Nonce,
+		"preferred_username": l.Username,
+		"email":              l.Username + "@example.edu",
+		"groups":             l.Groups,
+	})
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (s *server) casLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("service") == "" {
+		http.Error(w, "service is required", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodPost {
+		loginForm.Execute(w, map[string]interface{}{"Protocol": "CAS", "Hidden": map[string]string{"service": r.Form.Get("service")}})
+		return
+	}
+
+	ticket := s.store("ST-", login{
+		Username:    strings.TrimSpace(r.PostForm.Get("username")),
+		Groups:      splitGroups(r.PostForm.Get("groups")),
+		RedirectURI: r.Form.Get("service"),
+	})
+	separator := "?"
+	if strings.Contains(r.Form.Get("service"), "?") {
+		separator = "&"
+	}
+	http.Redirect(w, r, r.Form.Get("service")+separator+url.Values{"ticket": {ticket}}.Encode(), http.StatusFound)
+}
+
+type casAttribute struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+func (s *server) casValidate(w http.ResponseWriter, r *http.Request) {
+	type failure struct {
+		Code    string `xml:"code,attr"`
+		Message string `xml:",chardata"`
+	}
+	type success struct {
+		User       string         `xml:"cas:user"`
+		Attributes []casAttribute `xml:"cas:attributes>attr"` // Each attribute is named by its XMLName
+	}
+	response := struct {
+		XMLName xml.Name `xml:"cas:serviceResponse"`
+		NS      string   `xml:"xmlns:cas,attr"`
+		Success *success `xml:"cas:authenticationSuccess,omitempty"`
+		Failure *failure `xml:"cas:authenticationFailure,omitempty"`
+	}{NS: "http://www.yale.edu/tp/cas"}
+
+	l, ok := s.redeem(r.URL.Query().Get("ticket"))
+	if !ok || l.RedirectURI != r.URL.Query().Get("service") {
+		response.Failure = &failure{Code: "INVALID_TICKET", Message: "Ticket not recognized"}
+	} else {
+		attributes := []casAttribute{{XMLName: xml.Name{Local: "cas:mail"}, Value: l.Username + "@example.edu"}}
+		for _, g := range l.Groups {
+			attributes = append(attributes, casAttribute{XMLName: xml.Name{Local: "cas:groups"}, Value: g})
+		}
+		response.Success = &success{User: l.Username, Attributes: attributes}
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	xml.NewEncoder(w).Encode(response)
+}
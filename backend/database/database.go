@@ -4,6 +4,7 @@ import (
 	"advice/models"
 	"advice/utils"
 	"log"
+	"strings"
 
 	"github.com/glebarez/sqlite" // Pure go
 	"gorm.io/gorm"
@@ -20,14 +21,34 @@ func ConnectDatabase() {
 }
 
 func AutoMigrate() {
-	err := DB.AutoMigrate(&models.AdminUser{}, &models.Suggestion{}, &models.Department{}, &models.Reply{})
+	// Attachments predating approval were public together with their suggestion
+	approveLegacyAttachments := DB.Migrator().HasTable(&models.Attachment{}) && !DB.Migrator().HasColumn(&models.Attachment{}, "is_approved")
+
+	err := DB.AutoMigrate(&models.AdminUser{}, &models.Suggestion{}, &models.Department{}, &models.Reply{}, &models.Category{}, &models.RoutingRule{}, &models.Attachment{},
+		&models.SLAPolicy{}, &models.Holiday{}, &models.DuplicateCandidate{}, &models.Vote{}, &models.Reaction{}, &models.Comment{},
+		&models.SensitiveWordList{}, &models.LoginAttempt{}, &models.SuggestionContact{}, &models.Notification{}, &models.NotificationPreference{},
+		&models.Webhook{}, &models.WebhookDelivery{}, &models.SatisfactionRating{}, &models.SuggestionRevision{}, &models.Student{}, &models.AdminIdentity{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// Full-text search index over suggestions and their replies, filled with bigram tokens by the search service
+	err = DB.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS suggestion_fts USING fts5(suggestion_id UNINDEXED, title, content, replies, tokenize='unicode61')").Error
+	if err != nil {
+		log.Fatal("Failed to create search index:", err)
+	}
+
 	// Seed initial data
 	seedDepartments()
 	seedAdmin()
+	seedSLAPolicy()
+	seedCategories()
+	seedWordLists()
+	migrateFreeTextCategories()
+	migrateSLAMarkers()
+	if approveLegacyAttachments {
+		DB.Exec("UPDATE attachments SET is_approved = ?", true)
+	}
 }
 
 func seedDepartments() {
@@ -64,3 +85,145 @@ func seedAdmin() {
 		}
 	}
 }
+
+func seedSLAPolicy() {
+	var count int64
+	DB.Model(&models.SLAPolicy{}).Count(&count)
+	if count == 0 {
+		// Default policy applying to all departments and categories
+		policy := models.SLAPolicy{FirstReplyDays: 3, ResolutionDays: 10}
+		if err := DB.Create(&policy).Error; err != nil {
+			log.Fatal("Failed to seed SLA policy:", err)
+		}
+	}
+}
+
+func seedCategories() {
+	var count int64
+	DB.Model(&models.Category{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	defaults := []struct {
+		Name, Description, Department string
+	}{
+		{"教学教务", "课程安排、考试、教学质量等", "教务处"},
+		{"后勤服务", "校园设施、维修、水电等", "后勤保障部"},
+		{"食堂餐饮", "食堂菜品、价格、卫生等", "后勤保障部"},
+		{"宿舍住宿", "宿舍环境、热水、门禁等", "后勤保障部"},
+		{"学生活动", "社团、文体活动、评优等", "学生工作处"},
+		{"校园安全", "安全隐患、消防、交通等", ""},
+		{"其他", "其他建议", ""},
+	}
+	for i, d := range defaults {
+		category := models.Category{Name: d.Name, Description: d.Description, IsActive: true, SortOrder: i + 1}
+		var department models.Department
+		if d.Department != "" && DB.Where("name = ?", d.Department).First(&department).Error == nil {
+			category.DefaultDepartmentID = &department.ID
+		}
+		if err := DB.Create(&category).Error; err != nil {
+			log.Fatal("Failed to seed categories:", err)
+		}
+	}
+}
+
+func seedWordLists() {
+	var count int64
+	DB.Model(&models.SensitiveWordList{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	defaults := []models.SensitiveWordList{
+		{Name: "不文明用语", Action: "mask", Words: "傻逼\n傻B\n煞笔\n沙比\n他妈的\n妈的\n操你\n草泥马\n脑残\n贱人\n白痴\nfuck\nshit\nbitch\nasshole"},
+		{Name: "违规广告", Action: "block", Words: "代写作业\n代考\n办证\n刷单\n网络赌博\n贷款秒批"},
+		{Name: "需人工复核", Action: "flag", Words: "自杀\n自残\n霸凌\n欺凌\n性骚扰\n体罚"},
+	}
+	for _, list := range defaults {
+		list.IsActive = true
+		if err := DB.Create(&list).Error; err != nil {
+			log.Fatal("Failed to seed sensitive word lists:", err)
+		}
+	}
+}
+
+// categoryAliases maps keywords of common free-text spellings onto the seeded category names, first match wins
+var categoryAliases = [][2]string{
+	{"安全", "校园安全"},
+	{"食堂", "食堂餐饮"}, {"餐饮", "食堂餐饮"}, {"饭菜", "食堂餐饮"},
+	{"宿舍", "宿舍住宿"}, {"住宿", "宿舍住宿"}, {"寝室", "宿舍住宿"},
+	{"教学", "教学教务"}, {"教务", "教学教务"}, {"课程", "教学教务"}, {"考试", "教学教务"},
+	{"后勤", "后勤服务"}, {"设施", "后勤服务"}, {"维修", "后勤服务"},
+	{"活动", "学生活动"}, {"社团", "学生活动"},
+}
+
+// migrateFreeTextCategories maps suggestion categories that are not managed categories onto one,
+// creating an inactive category for values that cannot be matched so a super admin can review them
+func migrateFreeTextCategories() {
+	var categories []models.Category
+	DB.Find(&categories)
+	byName := map[string]string{}
+	for _, c := range categories {
+		byName[strings.ToLower(c.Name)] = c.Name
+	}
+
+	var values []string
+	DB.Model(&models.Suggestion{}).Distinct("category").Where("category <> ''").Pluck("category", &values)
+	for _, value := range values {
+		normalized := strings.ToLower(strings.TrimSpace(value))
+		if byName[normalized] == value {
+			continue
+		}
+
+		target, ok := byName[normalized]
+		if !ok {
+			for _, alias := range categoryAliases {
+				if strings.Contains(normalized, alias[0]) && byName[strings.ToLower(alias[1])] != "" {
+					target, ok = byName[strings.ToLower(alias[1])], true
+					break
+				}
+			}
+		}
+		if !ok {
+			target = strings.TrimSpace(value)
+			if target == "" {
+				target = "其他"
+			}
+			if byName[strings.ToLower(target)] == "" {
+				category := models.Category{Name: target, Description: "由历史建议分类迁移", IsActive: false, SortOrder: len(byName) + 1}
+				if err := DB.Create(&category).Error; err != nil {
+					log.Fatal("Failed to migrate category:", err)
+				}
+				byName[strings.ToLower(target)] = target
+			}
+		}
+		if target == value {
+			continue
+		}
+
+		if err := DB.Model(&models.Suggestion{}).Where("category = ?", value).Update("category", target).Error; err != nil {
+			log.Fatal("Failed to migrate suggestion categories:", err)
+		}
+		log.Printf("Migrated suggestion category %q to %q", value, target)
+	}
+}
+
+// migrateSLAMarkers moves the single escalation and warning times of older databases to the deadline they were for
+func migrateSLAMarkers() {
+	migrator := DB.Migrator()
+	if migrator.HasColumn(&models.Suggestion{}, "escalated_at") {
+		DB.Exec("UPDATE suggestions SET first_reply_escalated_at = escalated_at WHERE escalated_at IS NOT NULL AND first_reply_due_at <= escalated_at AND (first_replied_at IS NULL OR first_replied_at > first_reply_due_at)")
+		DB.Exec("UPDATE suggestions SET resolution_escalated_at = escalated_at WHERE escalated_at IS NOT NULL AND resolution_due_at <= escalated_at")
+		if err := migrator.DropColumn(&models.Suggestion{}, "escalated_at"); err != nil {
+			log.Fatal("Failed to migrate SLA escalations:", err)
+		}
+	}
+	if migrator.HasColumn(&models.Suggestion{}, "sla_warned_at") {
+		DB.Exec("UPDATE suggestions SET first_reply_warned_at = sla_warned_at WHERE sla_warned_at IS NOT NULL AND first_replied_at IS NULL")
+		DB.Exec("UPDATE suggestions SET resolution_warned_at = sla_warned_at WHERE sla_warned_at IS NOT NULL AND first_replied_at IS NOT NULL")
+		if err := migrator.DropColumn(&models.Suggestion{}, "sla_warned_at"); err != nil {
+			log.Fatal("Failed to migrate SLA warnings:", err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package events
+
+import (
+	"advice/utils"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// Event is something that happened to a suggestion, pushed to connected admins
+type Event struct {
+	ID   string // "<bus epoch>-<sequence>", sent as the SSE event ID
+	Seq  uint64
+	Type string
+	// Departments of the suggestion the event is about, before and after the change.
+	// A nil entry means a suggestion without department, visible to every admin.
+	Departments []*uint
+	Data        interface{}
+	Time        time.Time
+}
+
+// Subscription receives the events published after it was created
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	closed bool
+}
+
+// Bus is an in-process publish/subscribe hub that keeps recent events for reconnecting subscribers
+type Bus struct {
+	mu      sync.Mutex
+	epoch   string
+	seq     uint64
+	history []Event // Ring buffer of the last len(history) events
+	subs    map[*Subscription]struct{}
+}
+
+// Default is the bus the handlers publish to, keeping EVENT_HISTORY_SIZE events for replay
+var Default = NewBus(utils.GetenvInt("EVENT_HISTORY_SIZE", 1000))
+
+// NewBus creates a bus keeping historySize events for replay
+func NewBus(historySize int) *Bus {
+	if historySize < 1 {
+		historySize = 1
+	}
+	return &Bus{
+		// Event IDs of a previous process cannot be replayed, the epoch tells them apart
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		history: make([]Event, historySize),
+		subs:    map[*Subscription]struct{}{},
+	}
+}
+
+// Publish sends an event to every subscriber. Subscribers that fell too far behind are closed, they can reconnect and replay.
+func (b *Bus) Publish(kind string, departments []*uint, data interface{}) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event := Event{
+		ID:          fmt.Sprintf("%s-%d", b.epoch, b.seq),
+		Seq:         b.seq,
+		Type:        kind,
+		Departments: departments,
+		Data:        data,
+		Time:        time.Now(),
+	}
+	b.history[b.seq%uint64(len(b.history))] = event
+
+	for sub := range b.subs {
+		select {
+		case sub.c <- event:
+		default:
+			b.closeLocked(sub)
+		}
+	}
+	return event
+}
+
+// Subscribe registers a subscriber. With the ID of the last event a client received, it also returns the
+// events it missed; complete is false when some of them can no longer be replayed.
+func (b *Bus) Subscribe(lastEventID string) (sub *Subscription, missed []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan Event, subscriberBuffer)
+	sub = &Subscription{C: c, c: c}
+	b.subs[sub] = struct{}{}
+
+	if lastEventID == "" {
+		return sub, nil, true
+	}
+	epoch, seqStr, found := strings.Cut(lastEventID, "-")
+	last, err := strconv.ParseUint(seqStr, 10, 64)
+	if !found || err != nil || epoch != b.epoch || last > b.seq {
+		return sub, nil, false
+	}
+
+	size := uint64(len(b.history))
+	complete = b.seq-last <= size
+	from := last + 1
+	if !complete {
+		from = b.seq - size + 1
+	}
+	for seq := from; seq <= b.seq; seq++ {
+		missed = append(missed, b.history[seq%size])
+	}
+	return sub, missed, complete
+}
+
+// Unsubscribe stops a subscription and closes its channel
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closeLocked(sub)
+}
+
+func (b *Bus) closeLocked(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subs, sub)
+	close(sub.c)
+}
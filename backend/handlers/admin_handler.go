@@ -3,13 +3,18 @@ package handlers
 import (
 	"advice/database"
 	"advice/models"
+	"advice/services"
 	"advice/utils"
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type LoginInput struct {
@@ -33,6 +38,13 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	attempt := models.LoginAttempt{Username: input.Username, IP: c.ClientIP(), UserAgent: c.Request.UserAgent(), Method: "password"}
+	defer func() {
+		if err := database.DB.Create(&attempt).Error; err != nil {
+			log.Println("Failed to record login attempt:", err)
+		}
+	}()
+
 	var user models.AdminUser
 	if err := database.DB.Where("username = ?", input.Username).First(&user).Error; err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
@@ -55,9 +67,56 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	attempt.Success = true
 	c.JSON(http.StatusOK, gin.H{"token": token})
 }
 
+// GetLoginAttempts godoc
+// @Summary Get login attempts
+// @Description Get recorded admin and student logins, newest first, with the client IP resolved through the trusted proxies.
+// @Tags admin-users
+// @Security ApiKeyAuth
+// @Produce  json
+// @Param username query string false "Username"
+// @Param method query string false "Login method: password, oidc or cas, or student for student accounts"
+// @Param success query bool false "Only successful or only failed attempts"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/login-attempts [get]
+func GetLoginAttempts(c *gin.Context) {
+	page, pageSize := paginate(c)
+
+	query := database.DB.Model(&models.LoginAttempt{}).Order("created_at DESC")
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if method := c.Query("method"); method != "" {
+		query = query.Where("method = ?", method)
+	}
+	if success, set, err := parseBoolQuery(c, "success"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if set {
+		query = query.Where("success = ?", success)
+	}
+
+	var total int64
+	attempts := []models.LoginAttempt{}
+	query.Count(&total)
+	if err := query.Limit(pageSize).Offset((page - 1) * pageSize).Find(&attempts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve login attempts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"data":      attempts,
+	})
+}
+
 // GetAllSuggestions godoc
 // @Summary Get all suggestions (for admins)
 // @Description Get a paginated list of all suggestions, with filters.
@@ -66,8 +125,22 @@ func Login(c *gin.Context) {
 // @Produce  json
 // @Param page query int false "Page number"
 // @Param pageSize query int false "Page size"
-// @Param status query string false "Filter by status"
+// @Param status query string false "Filter by status, comma separated for several (已审核 matches every reviewed status)"
 // @Param department_id query int false "Filter by department ID"
+// @Param category query string false "Filter by category, comma separated for several"
+// @Param priority query string false "Filter by priority (low, normal, high, urgent), comma separated for several"
+// @Param assignee query string false "Filter by assignee: me, unassigned or an admin ID"
+// @Param overdue query bool false "Only show open suggestions past an SLA deadline"
+// @Param keyword query string false "Search title and content"
+// @Param is_public query bool false "Filter by public/private"
+// @Param has_replies query bool false "Filter by whether the suggestion has replies"
+// @Param upvotes_min query int false "Minimum upvotes"
+// @Param upvotes_max query int false "Maximum upvotes"
+// @Param submitter_class query string false "Filter by submitter class"
+// @Param created_from query string false "Created on or after this date (YYYY-MM-DD)"
+// @Param created_to query string false "Created on or before this date (YYYY-MM-DD)"
+// @Param sort query string false "Sort by created (default), updated, upvotes or priority"
+// @Param order query string false "Sort direction: desc (default) or asc"
 // @Success 200 {object} map[string]interface{}
 // @Router /admin/suggestions [get]
 func GetAllSuggestions(c *gin.Context) {
@@ -88,28 +161,16 @@ func GetAllSuggestions(c *gin.Context) {
 	offset := (page - 1) * pageSize
 
 	// Query
-	query := database.DB.Model(&models.Suggestion{}).Preload("Department").Order("created_at DESC")
+	query := database.DB.Model(&models.Suggestion{}).Preload("Department").Preload("Assignee")
 
-	// Authorization: Department admins can only see their department's suggestions unless CanViewAll is true
-	if adminClaims.Role == "department_admin" && !adminClaims.CanViewAll {
-		query = query.Where("department_id = ? OR department_id IS NULL", adminClaims.DepartmentID)
-		// Department admins should only see suggestions that have been reviewed
-		query = query.Where("status <> ?", "待审核")
-	}
+	// Authorization
+	query = restrictToVisibleSuggestions(query, adminClaims)
 
-	// Filtering
-	if status := c.Query("status"); status != "" {
-		if status == "已审核" {
-			query = query.Where("status <> ?", "待审核")
-		} else {
-			query = query.Where("status = ?", status)
-		}
-	}
-	if departmentID := c.Query("department_id"); departmentID != "" {
-		// Super admins or admins with CanViewAll can filter by any department
-		if adminClaims.Role == "super_admin" || adminClaims.CanViewAll {
-			query = query.Where("department_id = ?", departmentID)
-		}
+	// Filtering and sorting
+	query, err := applySuggestionFilters(c, query, adminClaims)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	var suggestions []models.Suggestion
@@ -120,6 +181,11 @@ func GetAllSuggestions(c *gin.Context) {
 		return
 	}
 
+	// Do not show assignee's password hash
+	for i := range suggestions {
+		suggestions[i].Assignee.PasswordHash = ""
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"total":     total,
 		"page":      page,
@@ -128,6 +194,22 @@ func GetAllSuggestions(c *gin.Context) {
 	})
 }
 
+// restrictToVisibleSuggestions limits a query on the suggestions table to what the admin may see.
+// Department admins can only see their department's suggestions unless CanViewAll is true.
+func restrictToVisibleSuggestions(query *gorm.DB, adminClaims *utils.Claims) *gorm.DB {
+	if adminClaims.Role == "department_admin" && !adminClaims.CanViewAll {
+		query = query.Where("suggestions.department_id = ? OR suggestions.department_id IS NULL", adminClaims.DepartmentID)
+		// Department admins should only see suggestions that have been reviewed
+		query = query.Where("suggestions.status <> ?", "待审核")
+	}
+	return query
+}
+
+// canAccessSuggestion reports whether the admin is authorized to access the suggestion
+func canAccessSuggestion(adminClaims *utils.Claims, suggestion *models.Suggestion) bool {
+	return !(adminClaims.Role == "department_admin" && !adminClaims.CanViewAll && suggestion.DepartmentID != nil && *suggestion.DepartmentID != adminClaims.DepartmentID)
+}
+
 // getSuggestionAndCheckAuth is a helper to get a suggestion and check if the admin is authorized to access it
 func getSuggestionAndCheckAuth(c *gin.Context) (*models.Suggestion, error) {
 	suggestionID := c.Param("id")
@@ -141,13 +223,15 @@ func getSuggestionAndCheckAuth(c *gin.Context) (*models.Suggestion, error) {
 	}
 
 	// Authorization check
-	if adminClaims.Role == "department_admin" && !adminClaims.CanViewAll && suggestion.DepartmentID != nil && *suggestion.DepartmentID != adminClaims.DepartmentID {
+	if !canAccessSuggestion(adminClaims, &suggestion) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to access this suggestion"})
 		return nil, errors.New("unauthorized")
 	}
 
 	// Preload details
-	if err := database.DB.Preload("Department").Preload("Replies").Preload("Replies.Replier").First(&suggestion).Error; err != nil {
+	if err := database.DB.Preload("Department").Preload("Assignee").Preload("Replies").Preload("Replies.Replier").
+		Preload("Attachments", "reply_id IS NULL").Preload("Replies.Attachments").Preload("Ratings").
+		Preload("Revisions", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).First(&suggestion).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestion not found after auth check"})
 		return nil, err
 	}
@@ -170,10 +254,11 @@ func GetSuggestionByID(c *gin.Context) {
 		return // Error response is already sent by the helper
 	}
 
-	// Sanitize replier info
+	// Sanitize replier and assignee info
 	for i := range suggestion.Replies {
 		suggestion.Replies[i].Replier.PasswordHash = ""
 	}
+	suggestion.Assignee.PasswordHash = ""
 
 	c.JSON(http.StatusOK, suggestion)
 }
@@ -204,12 +289,103 @@ func UpdateSuggestionStatus(c *gin.Context) {
 	if err != nil {
 		return // Error response is already sent by the helper
 	}
+	// Only the student withdraws a suggestion, and a withdrawn suggestion stays withdrawn
+	if suggestion.Status == "已撤回" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "建议已被学生撤回，无法修改状态"})
+		return
+	}
+	if input.Status == "已撤回" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只有提交者可以撤回建议"})
+		return
+	}
+
+	// Route suggestions without a department once they pass review
+	formerDepartmentID := suggestion.DepartmentID
+	routed := suggestion.Status == "待审核" && input.Status != "待审核" && services.RouteSuggestion(suggestion)
+	if routed {
+		database.DB.First(&suggestion.Department, *suggestion.DepartmentID)
+		// Department specific SLA policies apply from now on
+		services.ApplySLA(suggestion)
+	}
+
+	// Files uploaded before review are approved together with the suggestion
+	if slices.Contains(unapprovedStatuses, suggestion.Status) && !slices.Contains(unapprovedStatuses, input.Status) {
+		if err := services.ApproveSubmittedAttachments(suggestion.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve attachments"})
+			return
+		}
+	}
 
+	statusChanged := suggestion.Status != input.Status
+	if statusChanged && input.Status == "已解决" {
+		claims, _ := c.Get("user_claims")
+		services.MarkResolved(suggestion, claims.(*utils.Claims).UserID)
+	} else if statusChanged && suggestion.Status == "已解决" {
+		services.ClearResolution(suggestion)
+	}
 	suggestion.Status = input.Status
+	suggestion.IsOverdue = services.IsOverdue(suggestion, time.Now())
 	if err := database.DB.Save(&suggestion).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
 		return
 	}
+	if statusChanged {
+		services.NotifyStudentStatus(suggestion.ID)
+	}
+	if routed {
+		services.NotifyResponsibleAdmins(suggestion, services.NotificationNewSuggestion, "本部门收到新建议",
+			fmt.Sprintf("建议 #%d「%s」已通过审核并分派至本部门", suggestion.ID, suggestion.Title))
+	}
+	services.PublishSuggestionEvent(services.EventSuggestionUpdated, suggestion, formerDepartmentID)
+	if statusChanged && suggestion.Status == "已解决" {
+		services.PublishSuggestionEvent(services.EventSuggestionResolved, suggestion)
+	}
+
+	c.JSON(http.StatusOK, suggestion)
+}
+
+type UpdatePriorityInput struct {
+	Priority string `json:"priority" binding:"required"`
+}
+
+// UpdateSuggestionPriority godoc
+// @Summary Update suggestion priority
+// @Description Change the priority of a suggestion to low, normal, high or urgent.
+// @Tags admin-suggestions
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Suggestion ID"
+// @Param priority body UpdatePriorityInput true "New Priority"
+// @Success 200 {object} models.Suggestion
+// @Router /admin/suggestions/{id}/priority [put]
+func UpdateSuggestionPriority(c *gin.Context) {
+	var input UpdatePriorityInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !services.IsValidPriority(input.Priority) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid priority"})
+		return
+	}
+
+	suggestion, err := getSuggestionAndCheckAuth(c)
+	if err != nil {
+		return // Error response is already sent by the helper
+	}
+
+	if err := database.DB.Model(suggestion).Update("priority", input.Priority).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update priority"})
+		return
+	}
+	services.PublishSuggestionEvent(services.EventSuggestionUpdated, suggestion)
+
+	// Sanitize replier and assignee info
+	for i := range suggestion.Replies {
+		suggestion.Replies[i].Replier.PasswordHash = ""
+	}
+	suggestion.Assignee.PasswordHash = ""
 
 	c.JSON(http.StatusOK, suggestion)
 }
@@ -255,6 +431,16 @@ func AddReply(c *gin.Context) {
 		return
 	}
 
+	// The first reply satisfies the first response deadline
+	if suggestion.FirstRepliedAt == nil {
+		suggestion.FirstRepliedAt = &reply.CreatedAt
+		suggestion.IsOverdue = services.IsOverdue(suggestion, time.Now())
+		database.DB.Model(suggestion).Updates(map[string]interface{}{"first_replied_at": reply.CreatedAt, "is_overdue": suggestion.IsOverdue})
+	}
+	services.IndexSuggestion(suggestion.ID)
+	services.NotifyStudentReply(suggestion.ID, reply.Content)
+	services.PublishReplyEvent(suggestion, &reply)
+
 	c.JSON(http.StatusOK, reply)
 }
 
@@ -336,7 +522,7 @@ func CreateAdmin(c *gin.Context) {
 // @Router /admin/users [get]
 func GetAdmins(c *gin.Context) {
 	var admins []models.AdminUser
-	if err := database.DB.Preload("Department").Find(&admins).Error; err != nil {
+	if err := database.DB.Preload("Department").Preload("Identities").Find(&admins).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve admins"})
 		return
 	}
@@ -444,6 +630,25 @@ func DeleteAdmin(c *gin.Context) {
 		return
 	}
 
+	// Release suggestions assigned to this admin
+	if err := database.DB.Model(&models.Suggestion{}).Where("assignee_id = ?", adminID).Update("assignee_id", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unassign suggestions"})
+		return
+	}
+
+	if err := database.DB.Where("admin_id = ?", adminID).Delete(&models.Notification{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notifications"})
+		return
+	}
+	if err := database.DB.Where("admin_id = ?", adminID).Delete(&models.NotificationPreference{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notification preferences"})
+		return
+	}
+	if err := database.DB.Where("admin_id = ?", adminID).Delete(&models.AdminIdentity{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete linked SSO accounts"})
+		return
+	}
+
 	if err := database.DB.Delete(&models.AdminUser{}, adminID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete admin user"})
 		return
@@ -458,8 +663,27 @@ type DashboardStats struct {
 	ProcessingSuggestions int64                       `json:"processing_suggestions"`
 	ResolvedSuggestions   int64                       `json:"resolved_suggestions"`
 	ResolutionRate        float64                     `json:"resolution_rate"`
+	OverdueSuggestions    int64                       `json:"overdue_suggestions"`
+	OverdueByDept         []DepartmentSuggestionCount `json:"overdue_by_dept"`
 	WeeklyTrend           []DailyTrend                `json:"weekly_trend"`
 	SuggestionsByDept     []DepartmentSuggestionCount `json:"suggestions_by_dept"`
+	SuggestionsByCategory []CategorySuggestionCount   `json:"suggestions_by_category"`
+	AverageSatisfaction   float64                     `json:"average_satisfaction"` // 0 when nothing was rated
+	RatingCount           int64                       `json:"rating_count"`
+	SatisfactionByDept    []SatisfactionAverage       `json:"satisfaction_by_dept"`
+	SatisfactionByAdmin   []SatisfactionAverage       `json:"satisfaction_by_admin"`
+}
+
+// SatisfactionAverage is the average resolution rating of a department or admin
+type SatisfactionAverage struct {
+	Name    string  `json:"name"`
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+}
+
+type CategorySuggestionCount struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
 }
 
 type DailyTrend struct {
@@ -482,13 +706,14 @@ type DepartmentSuggestionCount struct {
 // @Success 200 {object} DashboardStats
 // @Router /admin/dashboard/stats [get]
 func GetDashboardStats(c *gin.Context) {
-	var total, pending, processing, resolved int64
+	var total, pending, processing, resolved, overdue int64
 
 	db := database.DB
 	db.Model(&models.Suggestion{}).Count(&total)
 	db.Model(&models.Suggestion{}).Where("status = ?", "待审核").Count(&pending)
 	db.Model(&models.Suggestion{}).Where("status = ?", "处理中").Count(&processing)
 	db.Model(&models.Suggestion{}).Where("status = ?", "已解决").Count(&resolved)
+	services.WhereOverdue(db.Model(&models.Suggestion{}), time.Now()).Count(&overdue)
 
 	var rate float64
 	if total > 0 {
@@ -576,14 +801,76 @@ func GetDashboardStats(c *gin.Context) {
 		})
 	}
 
+	// Overdue suggestions by Department
+	var overdueCounts []DeptCountResult
+	services.WhereOverdue(db.Table("suggestions"), time.Now()).
+		Select("departments.name, count(suggestions.id) as count").
+		Joins("join departments on departments.id = suggestions.department_id").
+		Group("departments.name").
+		Order("count DESC").
+		Scan(&overdueCounts)
+
+	var overdueByDept []DepartmentSuggestionCount
+	for _, row := range overdueCounts {
+		overdueByDept = append(overdueByDept, DepartmentSuggestionCount{
+			DepartmentName: row.Name,
+			Count:          row.Count,
+		})
+	}
+
+	// Suggestions by Category
+	suggestionsByCategory := []CategorySuggestionCount{}
+	db.Model(&models.Suggestion{}).
+		Select("category, count(*) as count").
+		Where("category <> ''").
+		Group("category").
+		Order("count DESC").
+		Scan(&suggestionsByCategory)
+
+	// Satisfaction ratings of resolutions
+	var satisfaction struct {
+		Average float64
+		Count   int64
+	}
+	db.Model(&models.SatisfactionRating{}).Select("COALESCE(AVG(score), 0) as average, COUNT(*) as count").Scan(&satisfaction)
+
+	satisfactionByDept := []SatisfactionAverage{}
+	db.Table("satisfaction_ratings").
+		Select("departments.name, AVG(satisfaction_ratings.score) as average, COUNT(*) as count").
+		Joins("join departments on departments.id = satisfaction_ratings.department_id").
+		Group("departments.name").
+		Order("average DESC").
+		Scan(&satisfactionByDept)
+
+	// Department admins only see how the admins of their own department are rated
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+	satisfactionByAdmin := []SatisfactionAverage{}
+	byAdmin := db.Table("satisfaction_ratings").
+		Select("admin_users.username as name, AVG(satisfaction_ratings.score) as average, COUNT(*) as count").
+		Joins("join admin_users on admin_users.id = satisfaction_ratings.admin_id")
+	if adminClaims.Role != "super_admin" {
+		byAdmin = byAdmin.Where("admin_users.department_id = ?", adminClaims.DepartmentID)
+	}
+	byAdmin.Group("admin_users.username").
+		Order("average DESC").
+		Scan(&satisfactionByAdmin)
+
 	stats := DashboardStats{
 		TotalSuggestions:      total,
 		PendingSuggestions:    pending,
 		ProcessingSuggestions: processing,
 		ResolvedSuggestions:   resolved,
 		ResolutionRate:        rate,
+		OverdueSuggestions:    overdue,
+		OverdueByDept:         overdueByDept,
 		WeeklyTrend:           weeklyTrend,
 		SuggestionsByDept:     suggestionsByDept,
+		SuggestionsByCategory: suggestionsByCategory,
+		AverageSatisfaction:   satisfaction.Average,
+		RatingCount:           satisfaction.Count,
+		SatisfactionByDept:    satisfactionByDept,
+		SatisfactionByAdmin:   satisfactionByAdmin,
 	}
 
 	c.JSON(http.StatusOK, stats)
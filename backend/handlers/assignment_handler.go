@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/services"
+	"advice/utils"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AssignInput struct {
+	AssigneeID uint `json:"assignee_id" binding:"required"`
+}
+
+// canHandleSuggestion reports whether an admin is allowed to be responsible for a suggestion
+func canHandleSuggestion(admin models.AdminUser, suggestion *models.Suggestion) bool {
+	if admin.Role == "super_admin" || admin.CanViewAll || suggestion.DepartmentID == nil {
+		return true
+	}
+	return admin.DepartmentID != nil && *admin.DepartmentID == *suggestion.DepartmentID
+}
+
+// setAssignee stores the new assignee and responds with the updated suggestion
+func setAssignee(c *gin.Context, suggestion *models.Suggestion, assigneeID *uint) {
+	if err := database.DB.Model(suggestion).Update("assignee_id", assigneeID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update assignee"})
+		return
+	}
+
+	suggestion.AssigneeID = assigneeID
+	suggestion.Assignee = models.AdminUser{}
+	if assigneeID != nil {
+		database.DB.Preload("Department").First(&suggestion.Assignee, *assigneeID)
+	}
+	services.PublishSuggestionEvent(services.EventSuggestionUpdated, suggestion)
+
+	// Sanitize replier and assignee info
+	for i := range suggestion.Replies {
+		suggestion.Replies[i].Replier.PasswordHash = ""
+	}
+	suggestion.Assignee.PasswordHash = ""
+
+	c.JSON(http.StatusOK, suggestion)
+}
+
+// AssignSuggestion godoc
+// @Summary Assign a suggestion to an admin
+// @Description Make an admin responsible for handling a suggestion. The assignee must be allowed to handle the suggestion's department.
+// @Tags admin-suggestions
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Suggestion ID"
+// @Param assignee body AssignInput true "Assignee"
+// @Success 200 {object} models.Suggestion
+// @Router /admin/suggestions/{id}/assignee [put]
+func AssignSuggestion(c *gin.Context) {
+	var input AssignInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	suggestion, err := getSuggestionAndCheckAuth(c)
+	if err != nil {
+		return // Error response is already sent by the helper
+	}
+
+	var assignee models.AdminUser
+	if err := database.DB.First(&assignee, input.AssigneeID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignee ID"})
+		return
+	}
+	if !canHandleSuggestion(assignee, suggestion) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Assignee does not belong to the suggestion's department"})
+		return
+	}
+
+	setAssignee(c, suggestion, &assignee.ID)
+}
+
+// UnassignSuggestion godoc
+// @Summary Unassign a suggestion
+// @Description Remove the current assignee from a suggestion.
+// @Tags admin-suggestions
+// @Security ApiKeyAuth
+// @Produce  json
+// @Param id path int true "Suggestion ID"
+// @Success 200 {object} models.Suggestion
+// @Router /admin/suggestions/{id}/assignee [delete]
+func UnassignSuggestion(c *gin.Context) {
+	suggestion, err := getSuggestionAndCheckAuth(c)
+	if err != nil {
+		return // Error response is already sent by the helper
+	}
+
+	setAssignee(c, suggestion, nil)
+}
+
+// ClaimSuggestion godoc
+// @Summary Claim a suggestion
+// @Description Assign a suggestion to the current admin.
+// @Tags admin-suggestions
+// @Security ApiKeyAuth
+// @Produce  json
+// @Param id path int true "Suggestion ID"
+// @Success 200 {object} models.Suggestion
+// @Router /admin/suggestions/{id}/claim [post]
+func ClaimSuggestion(c *gin.Context) {
+	suggestion, err := getSuggestionAndCheckAuth(c)
+	if err != nil {
+		return // Error response is already sent by the helper
+	}
+
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+
+	if suggestion.AssigneeID != nil && *suggestion.AssigneeID != adminClaims.UserID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Suggestion is already assigned to another admin"})
+		return
+	}
+
+	setAssignee(c, suggestion, &adminClaims.UserID)
+}
+
+type TransferInput struct {
+	DepartmentID uint `json:"department_id" binding:"required"`
+}
+
+// TransferSuggestion godoc
+// @Summary Transfer a suggestion to another department
+// @Description Move a suggestion to another department and notify its admins. The assignee is removed unless allowed to handle the new department.
+// @Tags admin-suggestions
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Suggestion ID"
+// @Param department body TransferInput true "New Department"
+// @Success 200 {object} models.Suggestion
+// @Router /admin/suggestions/{id}/department [put]
+func TransferSuggestion(c *gin.Context) {
+	var input TransferInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	suggestion, err := getSuggestionAndCheckAuth(c)
+	if err != nil {
+		return // Error response is already sent by the helper
+	}
+	if suggestion.DepartmentID != nil && *suggestion.DepartmentID == input.DepartmentID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Suggestion already belongs to this department"})
+		return
+	}
+
+	var department models.Department
+	if err := database.DB.First(&department, input.DepartmentID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		return
+	}
+
+	formerDepartmentID := suggestion.DepartmentID
+	suggestion.DepartmentID = &department.ID
+	suggestion.Department = department
+	// Due dates follow the new department's SLA policy, and its admins are warned again as deadlines approach
+	services.ApplySLA(suggestion)
+	suggestion.IsOverdue = services.IsOverdue(suggestion, time.Now())
+	suggestion.FirstReplyWarnedAt, suggestion.ResolutionWarnedAt = nil, nil
+	updates := map[string]interface{}{
+		"department_id":         department.ID,
+		"first_reply_due_at":    suggestion.FirstReplyDueAt,
+		"resolution_due_at":     suggestion.ResolutionDueAt,
+		"is_overdue":            suggestion.IsOverdue,
+		"first_reply_warned_at": nil,
+		"resolution_warned_at":  nil,
+	}
+	if suggestion.AssigneeID != nil && !canHandleSuggestion(suggestion.Assignee, suggestion) {
+		suggestion.AssigneeID = nil
+		suggestion.Assignee = models.AdminUser{}
+		updates["assignee_id"] = nil
+	}
+	if err := database.DB.Model(&models.Suggestion{}).Where("id = ?", suggestion.ID).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer suggestion"})
+		return
+	}
+
+	services.NotifyResponsibleAdmins(suggestion, services.NotificationTransfer, "建议已转至本部门",
+		fmt.Sprintf("建议 #%d「%s」已转交至%s处理", suggestion.ID, suggestion.Title, department.Name))
+	services.PublishSuggestionEvent(services.EventSuggestionUpdated, suggestion, formerDepartmentID)
+
+	// Sanitize replier and assignee info
+	for i := range suggestion.Replies {
+		suggestion.Replies[i].Replier.PasswordHash = ""
+	}
+	suggestion.Assignee.PasswordHash = ""
+
+	c.JSON(http.StatusOK, suggestion)
+}
+
+type AdminWorkload struct {
+	AdminID        uint   `json:"admin_id"`
+	Username       string `json:"username"`
+	DepartmentName string `json:"department_name"`
+	OpenCount      int    `json:"open_count"`
+	TotalCount     int    `json:"total_count"`
+}
+
+// GetAdminWorkload godoc
+// @Summary Get admin workload
+// @Description Get the number of open and total suggestions assigned to each admin.
+// @Tags admin-users
+// @Security ApiKeyAuth
+// @Produce  json
+// @Success 200 {array} AdminWorkload
+// @Router /admin/workload [get]
+func GetAdminWorkload(c *gin.Context) {
+	workload := []AdminWorkload{}
+	err := database.DB.Table("admin_users").
+		Select(`admin_users.id as admin_id, admin_users.username, COALESCE(departments.name, '') as department_name,
+			COUNT(suggestions.id) as total_count,
+			COALESCE(SUM(CASE WHEN suggestions.status NOT IN ? THEN 1 ELSE 0 END), 0) as open_count`,
+			services.ClosedStatuses).
+		Joins("left join departments on departments.id = admin_users.department_id").
+		Joins("left join suggestions on suggestions.assignee_id = admin_users.id").
+		Group("admin_users.id, admin_users.username, departments.name").
+		Order("open_count DESC").
+		Scan(&workload).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve workload"})
+		return
+	}
+
+	c.JSON(http.StatusOK, workload)
+}
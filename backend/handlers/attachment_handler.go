@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/services"
+	"advice/storage"
+	"advice/utils"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// saveUploadedAttachments stores the files of the "files" form field, responding with an error on failure.
+// existing is the number of attachments the suggestion or reply already has.
+func saveUploadedAttachments(c *gin.Context, suggestionID uint, replyID *uint, uploaderID *uint, existing int64) ([]models.Attachment, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxAttachmentSize*services.MaxAttachmentsPerItem+1<<20)
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart upload or files too large"})
+		return nil, false
+	}
+
+	files := form.File["files"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No files uploaded"})
+		return nil, false
+	}
+	if existing+int64(len(files)) > services.MaxAttachmentsPerItem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "最多只能上传5个附件"})
+		return nil, false
+	}
+
+	var saved []models.Attachment
+	for _, file := range files {
+		attachment, err := services.SaveAttachment(file, suggestionID, replyID, uploaderID)
+		if err != nil {
+			// Keep the upload all-or-nothing
+			for i := range saved {
+				services.DeleteAttachment(&saved[i])
+			}
+			if errors.Is(err, services.ErrInvalidAttachment) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+			}
+			return nil, false
+		}
+		saved = append(saved, *attachment)
+	}
+	return saved, true
+}
+
+// serveAttachment streams an attachment file, or its thumbnail when the thumbnail query parameter is true
+func serveAttachment(c *gin.Context, attachment *models.Attachment) {
+	key, contentType, size := attachment.StorageKey, attachment.ContentType, attachment.Size
+	if c.Query("thumbnail") == "true" {
+		if !attachment.HasThumbnail {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment has no thumbnail"})
+			return
+		}
+		key, contentType, size = attachment.ThumbnailKey, "image/jpeg", -1
+	}
+
+	file, err := storage.Default.Get(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment file not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read attachment"})
+		}
+		return
+	}
+	defer file.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(contentType, "image/") {
+		disposition = "inline"
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, file, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}),
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, max-age=3600",
+	})
+}
+
+// UploadSuggestionAttachments godoc
+// @Summary Upload attachments to a suggestion
+// @Description Attach photos (JPEG, PNG, GIF) or documents (PDF, Word, Excel, PowerPoint) to a suggestion using its tracking code. Image metadata such as location is removed. Files uploaded after review are shown publicly only once an admin approves them.
+// @Tags suggestions
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param tracking_code path string true "Suggestion Tracking Code"
+// @Param files formData file true "Files to upload"
+// @Success 200 {array} models.Attachment
+// @Router /tracking/{tracking_code}/attachments [post]
+func UploadSuggestionAttachments(c *gin.Context) {
+	var suggestion models.Suggestion
+	if err := database.DB.Where("tracking_code = ?", c.Param("tracking_code")).First(&suggestion).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestion not found"})
+		return
+	}
+	for _, status := range services.ClosedStatuses {
+		if suggestion.Status == status {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot add attachments to a closed suggestion"})
+			return
+		}
+	}
+
+	var existing int64
+	database.DB.Model(&models.Attachment{}).Where("suggestion_id = ? AND reply_id IS NULL", suggestion.ID).Count(&existing)
+
+	attachments, ok := saveUploadedAttachments(c, suggestion.ID, nil, nil, existing)
+	if !ok {
+		return
+	}
+	// Files added while the suggestion awaits review are approved with the submission itself,
+	// later ones stay private until an admin approves them
+	if suggestion.Status != "待审核" {
+		services.NotifyResponsibleAdmins(&suggestion, services.NotificationFollowUp, "学生补充了建议附件",
+			fmt.Sprintf("建议 #%d「%s」的提交者上传了 %d 个新附件，公开前需要审核", suggestion.ID, suggestion.Title, len(attachments)))
+	}
+	services.PublishSuggestionEvent(services.EventSuggestionUpdated, &suggestion)
+	c.JSON(http.StatusOK, attachments)
+}
+
+// GetTrackedAttachment godoc
+// @Summary Download an attachment by tracking code
+// @Description Download an attachment of a suggestion or of its replies using the suggestion's tracking code.
+// @Tags suggestions
+// @Produce  octet-stream
+// @Param tracking_code path string true "Suggestion Tracking Code"
+// @Param attachment_id path int true "Attachment ID"
+// @Param thumbnail query bool false "Download the image thumbnail"
+// @Success 200 {file} file
+// @Router /tracking/{tracking_code}/attachments/{attachment_id} [get]
+func GetTrackedAttachment(c *gin.Context) {
+	var suggestion models.Suggestion
+	if err := database.DB.Where("tracking_code = ?", c.Param("tracking_code")).First(&suggestion).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestion not found"})
+		return
+	}
+
+	var attachment models.Attachment
+	if err := database.DB.First(&attachment, c.Param("attachment_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	// The submitter of a merged duplicate also sees the replies of the canonical suggestion
+	ownAttachment := attachment.SuggestionID == suggestion.ID
+	canonicalReply := suggestion.CanonicalID != nil && attachment.SuggestionID == *suggestion.CanonicalID && attachment.ReplyID != nil
+	if !ownAttachment && !canonicalReply {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	serveAttachment(c, &attachment)
+}
+
+// GetPublicAttachment godoc
+// @Summary Download an attachment of a public suggestion
+// @Description Download an approved attachment of a public, approved suggestion or of its replies.
+// @Tags suggestions
+// @Produce  octet-stream
+// @Param id path int true "Attachment ID"
+// @Param thumbnail query bool false "Download the image thumbnail"
+// @Success 200 {file} file
+// @Router /attachments/{id} [get]
+func GetPublicAttachment(c *gin.Context) {
+	var attachment models.Attachment
+	if err := database.DB.Where("is_approved = ?", true).First(&attachment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.Suggestion{}).
+		Where("id = ?", attachment.SuggestionID).
+		Where("is_public = ?", true).
+		Where("status NOT IN ?", []string{"待审核", "审核不通过", "已撤回"}).
+		Where("canonical_id IS NULL").
+		Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	serveAttachment(c, &attachment)
+}
+
+// UploadReplyAttachments godoc
+// @Summary Upload attachments to a reply
+// @Description Attach photos or documents, such as notices, to an admin reply.
+// @Tags admin-suggestions
+// @Security ApiKeyAuth
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param id path int true "Suggestion ID"
+// @Param reply_id path int true "Reply ID"
+// @Param files formData file true "Files to upload"
+// @Success 200 {array} models.Attachment
+// @Router /admin/suggestions/{id}/replies/{reply_id}/attachments [post]
+func UploadReplyAttachments(c *gin.Context) {
+	suggestion, err := getSuggestionAndCheckAuth(c)
+	if err != nil {
+		return // Error response is already sent by the helper
+	}
+
+	var reply models.Reply
+	if err := database.DB.Where("suggestion_id = ?", suggestion.ID).First(&reply, c.Param("reply_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reply not found"})
+		return
+	}
+
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+
+	var existing int64
+	database.DB.Model(&models.Attachment{}).Where("reply_id = ?", reply.ID).Count(&existing)
+
+	attachments, ok := saveUploadedAttachments(c, suggestion.ID, &reply.ID, &adminClaims.UserID, existing)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// GetAdminAttachment godoc
+// @Summary Download an attachment (for admins)
+// @Description Download an attachment of a suggestion the admin is authorized to access.
+// @Tags admin-suggestions
+// @Security ApiKeyAuth
+// @Produce  octet-stream
+// @Param id path int true "Attachment ID"
+// @Param thumbnail query bool false "Download the image thumbnail"
+// @Success 200 {file} file
+// @Router /admin/attachments/{id} [get]
+func GetAdminAttachment(c *gin.Context) {
+	var attachment models.Attachment
+	if err := database.DB.First(&attachment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	var suggestion models.Suggestion
+	if err := database.DB.First(&suggestion, attachment.SuggestionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestion not found"})
+		return
+	}
+
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+	if !canAccessSuggestion(adminClaims, &suggestion) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to access this suggestion"})
+		return
+	}
+
+	serveAttachment(c, &attachment)
+}
+
+// ApproveAttachment godoc
+// @Summary Approve an attachment
+// @Description Approve an attachment the student uploaded after review, showing it with the public suggestion.
+// @Tags admin-suggestions
+// @Security ApiKeyAuth
+// @Produce  json
+// @Param id path int true "Attachment ID"
+// @Success 200 {object} models.Attachment
+// @Router /admin/attachments/{id}/approve [post]
+func ApproveAttachment(c *gin.Context) {
+	var attachment models.Attachment
+	if err := database.DB.First(&attachment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	var suggestion models.Suggestion
+	if err := database.DB.First(&suggestion, attachment.SuggestionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestion not found"})
+		return
+	}
+
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+	if !canAccessSuggestion(adminClaims, &suggestion) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to access this suggestion"})
+		return
+	}
+
+	if err := database.DB.Model(&attachment).Update("is_approved", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve attachment"})
+		return
+	}
+	c.JSON(http.StatusOK, attachment)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CategoryInput struct {
+	Name                string `json:"name" binding:"required"`
+	Description         string `json:"description"`
+	IsActive            *bool  `json:"is_active"`
+	SortOrder           int    `json:"sort_order"`
+	DefaultDepartmentID *uint  `json:"default_department_id"`
+}
+
+// bindCategoryInput binds and validates a category request body
+func bindCategoryInput(c *gin.Context) (*CategoryInput, bool) {
+	var input CategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category name cannot be empty"})
+		return nil, false
+	}
+
+	// Validate DefaultDepartmentID exists if provided
+	if input.DefaultDepartmentID != nil {
+		var department models.Department
+		if err := database.DB.First(&department, *input.DefaultDepartmentID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+			return nil, false
+		}
+	}
+
+	return &input, true
+}
+
+// GetCategories godoc
+// @Summary Get active categories
+// @Description Get the categories students can choose from when submitting a suggestion.
+// @Tags categories
+// @Produce  json
+// @Success 200 {array} models.Category
+// @Router /categories [get]
+func GetCategories(c *gin.Context) {
+	var categories []models.Category
+	if err := database.DB.Where("is_active = ?", true).Order("sort_order ASC, id ASC").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve categories"})
+		return
+	}
+	c.JSON(http.StatusOK, categories)
+}
+
+// GetAllCategories godoc
+// @Summary Get all categories
+// @Description Get all categories, including inactive ones.
+// @Tags admin-categories
+// @Security ApiKeyAuth
+// @Produce  json
+// @Success 200 {array} models.Category
+// @Router /admin/categories [get]
+func GetAllCategories(c *gin.Context) {
+	var categories []models.Category
+	if err := database.DB.Preload("DefaultDepartment").Order("sort_order ASC, id ASC").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve categories"})
+		return
+	}
+	c.JSON(http.StatusOK, categories)
+}
+
+// CreateCategory godoc
+// @Summary Create a new category
+// @Description Add a new suggestion category. Categories are active unless is_active is false.
+// @Tags admin-categories
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param category body CategoryInput true "Category"
+// @Success 200 {object} models.Category
+// @Router /admin/categories [post]
+func CreateCategory(c *gin.Context) {
+	input, ok := bindCategoryInput(c)
+	if !ok {
+		return
+	}
+
+	category := models.Category{
+		Name:                input.Name,
+		Description:         input.Description,
+		IsActive:            input.IsActive == nil || *input.IsActive,
+		SortOrder:           input.SortOrder,
+		DefaultDepartmentID: input.DefaultDepartmentID,
+	}
+	if err := database.DB.Create(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+// UpdateCategory godoc
+// @Summary Update a category
+// @Description Update a category. Renaming a category also renames it on existing suggestions and SLA policies.
+// @Tags admin-categories
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Category ID"
+// @Param category body CategoryInput true "Category"
+// @Success 200 {object} models.Category
+// @Router /admin/categories/{id} [put]
+func UpdateCategory(c *gin.Context) {
+	categoryID := c.Param("id")
+	input, ok := bindCategoryInput(c)
+	if !ok {
+		return
+	}
+
+	var category models.Category
+	if err := database.DB.First(&category, categoryID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	oldName := category.Name
+	category.Name = input.Name
+	category.Description = input.Description
+	if input.IsActive != nil {
+		category.IsActive = *input.IsActive
+	}
+	category.SortOrder = input.SortOrder
+	category.DefaultDepartmentID = input.DefaultDepartmentID
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("DefaultDepartment").Save(&category).Error; err != nil {
+			return err
+		}
+		if oldName == category.Name {
+			return nil
+		}
+		if err := tx.Model(&models.Suggestion{}).Where("category = ?", oldName).Update("category", category.Name).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.SLAPolicy{}).Where("category = ?", oldName).Update("category", category.Name).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RoutingRule{}).Where("category = ?", oldName).Update("category", category.Name).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory godoc
+// @Summary Delete a category
+// @Description Remove a category that no suggestion uses. Deactivate categories that are in use instead.
+// @Tags admin-categories
+// @Security ApiKeyAuth
+// @Param id path int true "Category ID"
+// @Success 204
+// @Router /admin/categories/{id} [delete]
+func DeleteCategory(c *gin.Context) {
+	var category models.Category
+	if err := database.DB.First(&category, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.Suggestion{}).Where("category = ?", category.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete a category used by suggestions, deactivate it instead"})
+		return
+	}
+
+	if err := database.DB.Delete(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
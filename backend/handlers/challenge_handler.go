@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"advice/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetChallenge godoc
+// @Summary Get a submission challenge
+// @Description Get a challenge to solve before submitting a suggestion. In "pow" mode, find a nonce such that SHA-256(token + ":" + nonce) starts with difficulty zero bits; in "captcha" mode, answer the arithmetic question in the image; in "off" mode no challenge is needed.
+// @Tags suggestions
+// @Produce  json
+// @Success 200 {object} services.Challenge
+// @Router /challenge [get]
+func GetChallenge(c *gin.Context) {
+	challenge, err := services.NewChallenge()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, challenge)
+}
+
+// verifyChallenge checks the challenge solution of a submission, responding with an error when it fails
+func verifyChallenge(c *gin.Context, token, solution string) bool {
+	err := services.VerifyChallenge(token, solution)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrChallengeFailed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证失败，请重试", "code": "challenge_failed"})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证已失效，请刷新后重试", "code": "challenge_required"})
+	}
+	return false
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/services"
+	"advice/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var commentStatuses = []string{"待审核", "已通过", "审核不通过"}
+
+type CommentInput struct {
+	Nickname string `json:"nickname" binding:"max=30"`
+	Content  string `json:"content" binding:"required,max=500"`
+}
+
+type CommentStatusInput struct {
+	Status string `json:"status" binding:"required"` // "待审核", "已通过", "审核不通过"
+}
+
+// paginate reads the page and pageSize query parameters
+func paginate(c *gin.Context) (page, pageSize int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err = strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 10
+	}
+	return page, pageSize
+}
+
+// GetSuggestionComments godoc
+// @Summary Get the comments of a suggestion
+// @Description Get the approved public comments of a public, approved suggestion.
+// @Tags suggestions
+// @Produce  json
+// @Param suggestion_id query int true "Suggestion ID"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} map[string]interface{}
+// @Router /comments [get]
+func GetSuggestionComments(c *gin.Context) {
+	if _, err := strconv.Atoi(c.Query("suggestion_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "suggestion_id is required"})
+		return
+	}
+	suggestion, ok := getVotableSuggestion(c, c.Query("suggestion_id"))
+	if !ok {
+		return
+	}
+	page, pageSize := paginate(c)
+
+	query := database.DB.Model(&models.Comment{}).
+		Where("suggestion_id = ? AND status = ?", suggestion.ID, "已通过").
+		Order("created_at ASC")
+
+	var total int64
+	comments := []models.Comment{}
+	query.Count(&total)
+	if err := query.Limit(pageSize).Offset((page - 1) * pageSize).Find(&comments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments"})
+		return
+	}
+	for i := range comments {
+		comments[i].Content = services.MaskPII(comments[i].Content)
+		comments[i].Moderation = models.ModerationResult{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"data":      comments,
+	})
+}
+
+// SubmitComment godoc
+// @Summary Comment on a suggestion
+// @Description Post a public comment on a public, approved suggestion. The comment is moderated and shown after an admin approves it.
+// @Tags suggestions
+// @Accept  json
+// @Produce  json
+// @Param   id     path    int     true        "Suggestion ID"
+// @Param comment body CommentInput true "Comment"
+// @Success 200 {object} models.Comment
+// @Router /suggestions/{id}/comments [post]
+func SubmitComment(c *gin.Context) {
+	var input CommentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	suggestion, ok := getVotableSuggestion(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	check := services.Moderate(&input.Nickname, &input.Content)
+	if check.Blocked {
+		moderationBlockedResponse(c)
+		return
+	}
+
+	comment := models.Comment{
+		SuggestionID: suggestion.ID,
+		Nickname:     input.Nickname,
+		Content:      input.Content,
+		Status:       "待审核",
+		Moderation:   check.Result,
+		VoterID:      ensureVoterID(c),
+		IPHash:       utils.HashIP(c.ClientIP()),
+	}
+	if err := database.DB.Create(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit comment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
+
+// GetAllComments godoc
+// @Summary Get comments for moderation
+// @Description Get the public comments on suggestions the admin can access, newest first.
+// @Tags admin-comments
+// @Security ApiKeyAuth
+// @Produce  json
+// @Param status query string false "Comment status"
+// @Param suggestion_id query int false "Suggestion ID"
+// @Param flagged query bool false "Only comments flagged by content moderation"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/comments [get]
+func GetAllComments(c *gin.Context) {
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+	page, pageSize := paginate(c)
+
+	query := database.DB.Model(&models.Comment{}).
+		Joins("JOIN suggestions ON suggestions.id = comments.suggestion_id").
+		Preload("Suggestion", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "tracking_code", "title", "status", "department_id")
+		}).
+		Order("comments.created_at DESC")
+	query = restrictToVisibleSuggestions(query, adminClaims)
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("comments.status = ?", status)
+	}
+	if suggestionID := c.Query("suggestion_id"); suggestionID != "" {
+		query = query.Where("comments.suggestion_id = ?", suggestionID)
+	}
+	if c.Query("flagged") == "true" {
+		query = query.Where("comments.moderation_action = ?", services.ModerationFlag)
+	}
+
+	var total int64
+	comments := []models.Comment{}
+	query.Count(&total)
+	if err := query.Limit(pageSize).Offset((page - 1) * pageSize).Find(&comments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"data":      comments,
+	})
+}
+
+// getCommentAndCheckAuth loads a comment and checks that the admin can access its suggestion
+func getCommentAndCheckAuth(c *gin.Context) (*models.Comment, bool) {
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+
+	var comment models.Comment
+	if err := database.DB.Preload("Suggestion").First(&comment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, false
+	}
+	if comment.Suggestion == nil || !canAccessSuggestion(adminClaims, comment.Suggestion) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to access this comment"})
+		return nil, false
+	}
+	return &comment, true
+}
+
+// ModerateComment godoc
+// @Summary Moderate a comment
+// @Description Approve or reject a public comment. Only approved comments are shown publicly.
+// @Tags admin-comments
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Comment ID"
+// @Param status body CommentStatusInput true "New Status"
+// @Success 200 {object} models.Comment
+// @Router /admin/comments/{id}/status [put]
+func ModerateComment(c *gin.Context) {
+	var input CommentStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	valid := false
+	for _, status := range commentStatuses {
+		if status == input.Status {
+			valid = true
+		}
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment status"})
+		return
+	}
+
+	comment, ok := getCommentAndCheckAuth(c)
+	if !ok {
+		return
+	}
+
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+	now := time.Now()
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(comment).Updates(map[string]interface{}{
+			"status":       input.Status,
+			"moderator_id": adminClaims.UserID,
+			"moderated_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return services.RecountInteractions(tx, comment.SuggestionID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate comment"})
+		return
+	}
+
+	comment.Status = input.Status
+	comment.ModeratorID = &adminClaims.UserID
+	comment.ModeratedAt = &now
+	comment.Suggestion = nil
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment godoc
+// @Summary Delete a comment
+// @Description Permanently remove a public comment.
+// @Tags admin-comments
+// @Security ApiKeyAuth
+// @Param id path int true "Comment ID"
+// @Success 204
+// @Router /admin/comments/{id} [delete]
+func DeleteComment(c *gin.Context) {
+	comment, ok := getCommentAndCheckAuth(c)
+	if !ok {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(comment).Error; err != nil {
+			return err
+		}
+		return services.RecountInteractions(tx, comment.SuggestionID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
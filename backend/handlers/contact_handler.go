@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/utils"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unsubscribePage asks students to confirm before unsubscribing, as mail scanners and link previews follow GET links
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>退订邮件通知 / Unsubscribe</title></head>
+<body>
+{{if .Confirm}}<p>确定不再接收建议「{{.Title}}」的邮件通知吗？<br>Stop the email notifications about "{{.Title}}"?</p>
+<form method="post"><button type="submit">退订 / Unsubscribe</button></form>
+{{else}}<p>{{.Message}}</p>
+{{end}}</body></html>`))
+
+// ConfirmUnsubscribe godoc
+// @Summary Confirm unsubscribing from email notifications
+// @Description Show a page that asks to confirm stopping the status and reply emails of a suggestion. It is the signed link sent in every notification; nothing changes until the page is submitted.
+// @Tags suggestions
+// @Produce  html
+// @Param tracking_code path string true "Tracking Code"
+// @Param token query string true "Unsubscribe token from the email"
+// @Success 200 {string} string
+// @Router /tracking/{tracking_code}/unsubscribe [get]
+func ConfirmUnsubscribe(c *gin.Context) {
+	suggestion, status, message := unsubscribeTarget(c)
+	if suggestion == nil {
+		renderUnsubscribePage(c, status, gin.H{"Message": message})
+		return
+	}
+	renderUnsubscribePage(c, http.StatusOK, gin.H{"Confirm": true, "Title": suggestion.Title})
+}
+
+// Unsubscribe godoc
+// @Summary Unsubscribe from email notifications
+// @Description Stop the status and reply emails of a suggestion, submitted from the confirmation page or as a one-click request from mail clients.
+// @Tags suggestions
+// @Produce  json,html
+// @Param tracking_code path string true "Tracking Code"
+// @Param token query string true "Unsubscribe token from the email"
+// @Success 200 {object} map[string]string
+// @Router /tracking/{tracking_code}/unsubscribe [post]
+func Unsubscribe(c *gin.Context) {
+	suggestion, status, message := unsubscribeTarget(c)
+	if suggestion != nil {
+		status, message = http.StatusOK, "已退订该建议的邮件通知 / You have been unsubscribed"
+		if err := database.DB.Model(&models.SuggestionContact{}).Where("suggestion_id = ?", suggestion.ID).
+			Update("unsubscribed", true).Error; err != nil {
+			status, message = http.StatusInternalServerError, "Failed to unsubscribe"
+		}
+	}
+
+	// Browsers submitting the confirmation page get a page back, mail clients JSON
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		renderUnsubscribePage(c, status, gin.H{"Message": message})
+		return
+	}
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	c.JSON(status, gin.H{"message": message})
+}
+
+// unsubscribeTarget returns the suggestion of a signed unsubscribe link, or the status and message of why it is not valid
+func unsubscribeTarget(c *gin.Context) (*models.Suggestion, int, string) {
+	trackingCode := c.Param("tracking_code")
+	if !utils.VerifyUnsubscribe(trackingCode, c.Query("token")) {
+		return nil, http.StatusForbidden, "退订链接无效"
+	}
+	var suggestion models.Suggestion
+	if err := database.DB.Where("tracking_code = ?", trackingCode).First(&suggestion).Error; err != nil {
+		return nil, http.StatusNotFound, "Suggestion not found"
+	}
+	return &suggestion, 0, ""
+}
+
+func renderUnsubscribePage(c *gin.Context, status int, data gin.H) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	unsubscribePage.Execute(c.Writer, data)
+}
@@ -89,7 +89,7 @@ func UpdateDepartment(c *gin.Context) {
 
 // DeleteDepartment godoc
 // @Summary Delete a department
-// @Description Remove a department.
+// @Description Remove a department that no admin user or routing rule references.
 // @Tags admin-departments
 // @Security ApiKeyAuth
 // @Param id path int true "Department ID"
@@ -106,6 +106,13 @@ func DeleteDepartment(c *gin.Context) {
 		return
 	}
 
+	// Routing rules would otherwise keep sending suggestions to a department that no longer exists
+	database.DB.Model(&models.RoutingRule{}).Where("department_id = ?", departmentID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete department referenced by routing rules"})
+		return
+	}
+
 	if err := database.DB.Delete(&models.Department{}, departmentID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete department"})
 		return
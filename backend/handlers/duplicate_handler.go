@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/services"
+	"advice/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DuplicateSuggestion struct {
+	ID             uint      `json:"id"`
+	Title          string    `json:"title"`
+	Status         string    `json:"status"`
+	DepartmentName string    `json:"department_name"`
+	Upvotes        int       `json:"upvotes"`
+	CanonicalID    *uint     `json:"canonical_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Score          float64   `json:"score"`
+}
+
+type MergeInput struct {
+	CanonicalID uint `json:"canonical_id" binding:"required"`
+}
+
+// GetDuplicateCandidates godoc
+// @Summary Get possible duplicates of a suggestion
+// @Description List suggestions with similar text detected on submission, and the suggestions already merged into this one.
+// @Tags admin-suggestions
+// @Security ApiKeyAuth
+// @Produce  json
+// @Param id path int true "Suggestion ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/suggestions/{id}/duplicates [get]
+func GetDuplicateCandidates(c *gin.Context) {
+	suggestion, err := getSuggestionAndCheckAuth(c)
+	if err != nil {
+		return // Error response is already sent by the helper
+	}
+
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+
+	// Candidates are recorded from the newer suggestion, so look in both directions
+	var records []models.DuplicateCandidate
+	if err := database.DB.Where("suggestion_id = ? OR candidate_id = ?", suggestion.ID, suggestion.ID).Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve duplicate candidates"})
+		return
+	}
+	scores := map[uint]float64{}
+	for _, r := range records {
+		otherID := r.CandidateID
+		if otherID == suggestion.ID {
+			otherID = r.SuggestionID
+		}
+		if r.Score > scores[otherID] {
+			scores[otherID] = r.Score
+		}
+	}
+
+	candidates := []DuplicateSuggestion{}
+	if len(scores) > 0 {
+		ids := make([]uint, 0, len(scores))
+		for id := range scores {
+			ids = append(ids, id)
+		}
+		var found []models.Suggestion
+		query := database.DB.Model(&models.Suggestion{}).Preload("Department").Where("suggestions.id IN ?", ids)
+		if err := restrictToVisibleSuggestions(query, adminClaims).Order("created_at ASC").Find(&found).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve duplicate candidates"})
+			return
+		}
+		for _, s := range found {
+			candidates = append(candidates, toDuplicateSuggestion(s, scores[s.ID]))
+		}
+	}
+
+	var mergedSuggestions []models.Suggestion
+	if err := database.DB.Preload("Department").Where("canonical_id = ?", suggestion.ID).Order("created_at ASC").Find(&mergedSuggestions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve merged duplicates"})
+		return
+	}
+	merged := []DuplicateSuggestion{}
+	for _, s := range mergedSuggestions {
+		merged = append(merged, toDuplicateSuggestion(s, scores[s.ID]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"candidates": candidates,
+		"merged":     merged,
+	})
+}
+
+func toDuplicateSuggestion(s models.Suggestion, score float64) DuplicateSuggestion {
+	return DuplicateSuggestion{
+		ID:             s.ID,
+		Title:          s.Title,
+		Status:         s.Status,
+		DepartmentName: s.Department.Name,
+		Upvotes:        s.Upvotes,
+		CanonicalID:    s.CanonicalID,
+		CreatedAt:      s.CreatedAt,
+		Score:          score,
+	}
+}
+
+// MergeSuggestion godoc
+// @Summary Merge a duplicate suggestion
+// @Description Mark a suggestion as a duplicate of a canonical suggestion. Its upvotes move to the canonical suggestion, and its submitter sees the canonical status and replies through their tracking code.
+// @Tags admin-suggestions
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Duplicate Suggestion ID"
+// @Param merge body MergeInput true "Canonical Suggestion"
+// @Success 200 {object} models.Suggestion
+// @Router /admin/suggestions/{id}/merge [post]
+func MergeSuggestion(c *gin.Context) {
+	var input MergeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	duplicate, err := getSuggestionAndCheckAuth(c)
+	if err != nil {
+		return // Error response is already sent by the helper
+	}
+	if duplicate.CanonicalID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Suggestion is already merged"})
+		return
+	}
+	if duplicate.Status == "已撤回" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge a withdrawn suggestion"})
+		return
+	}
+	if input.CanonicalID == duplicate.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge a suggestion into itself"})
+		return
+	}
+
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+
+	var canonical models.Suggestion
+	if err := database.DB.First(&canonical, input.CanonicalID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid canonical suggestion ID"})
+		return
+	}
+	if !canAccessSuggestion(adminClaims, &canonical) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to access the canonical suggestion"})
+		return
+	}
+	if canonical.CanonicalID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Canonical suggestion is itself a merged duplicate"})
+		return
+	}
+	if canonical.Status == "已撤回" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge into a withdrawn suggestion"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Voters who upvoted both suggestions only count once
+		sameVoters := tx.Model(&models.Vote{}).Select("voter_id").Where("suggestion_id = ?", canonical.ID)
+		overlap := tx.Where("suggestion_id = ? AND voter_id IN (?)", duplicate.ID, sameVoters).Delete(&models.Vote{})
+		if overlap.Error != nil {
+			return overlap.Error
+		}
+		if err := tx.Model(&models.Vote{}).Where("suggestion_id = ?", duplicate.ID).
+			Update("suggestion_id", canonical.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Suggestion{}).Where("id = ?", canonical.ID).
+			Update("upvotes", gorm.Expr("upvotes + ?", max(duplicate.Upvotes-int(overlap.RowsAffected), 0))).Error; err != nil {
+			return err
+		}
+		// Reactions and comments move too, keeping the canonical suggestion's reaction of voters who reacted to both
+		sameReactors := tx.Model(&models.Reaction{}).Select("voter_id").Where("suggestion_id = ?", canonical.ID)
+		if err := tx.Where("suggestion_id = ? AND voter_id IN (?)", duplicate.ID, sameReactors).Delete(&models.Reaction{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.Reaction{}, &models.Comment{}} {
+			if err := tx.Model(model).Where("suggestion_id = ?", duplicate.ID).Update("suggestion_id", canonical.ID).Error; err != nil {
+				return err
+			}
+		}
+		if err := services.RecountInteractions(tx, canonical.ID); err != nil {
+			return err
+		}
+		if err := services.RecountInteractions(tx, duplicate.ID); err != nil {
+			return err
+		}
+		// Duplicates of the duplicate follow it to the canonical suggestion
+		if err := tx.Model(&models.Suggestion{}).Where("canonical_id = ?", duplicate.ID).
+			Update("canonical_id", canonical.ID).Error; err != nil {
+			return err
+		}
+		return tx.Model(duplicate).Updates(map[string]interface{}{
+			"canonical_id": canonical.ID,
+			"status":       "已合并",
+			"upvotes":      0,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge suggestion"})
+		return
+	}
+	services.NotifyStudentStatus(duplicate.ID)
+	database.DB.First(&canonical, canonical.ID)
+	services.PublishSuggestionEvent(services.EventSuggestionUpdated, duplicate)
+	services.PublishSuggestionEvent(services.EventSuggestionUpdated, &canonical)
+
+	// Sanitize replier and assignee info
+	for i := range duplicate.Replies {
+		duplicate.Replies[i].Replier.PasswordHash = ""
+	}
+	duplicate.Assignee.PasswordHash = ""
+
+	c.JSON(http.StatusOK, duplicate)
+}
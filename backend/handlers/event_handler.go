@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"advice/events"
+	"advice/models"
+	"advice/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventHeartbeat keeps idle streams open through proxies, configured by EVENT_HEARTBEAT_SECONDS
+var eventHeartbeat = time.Duration(utils.GetenvInt("EVENT_HEARTBEAT_SECONDS", 15)) * time.Second
+
+// canSeeEvent reports whether an admin may see an event, using the same scope as canAccessSuggestion
+func canSeeEvent(adminClaims *utils.Claims, event events.Event) bool {
+	for _, departmentID := range event.Departments {
+		if canAccessSuggestion(adminClaims, &models.Suggestion{DepartmentID: departmentID}) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeEvent writes an event in the SSE format
+func writeEvent(c *gin.Context, event events.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// CreateEventTicket godoc
+// @Summary Get an event stream ticket
+// @Description Issue a ticket that opens the event stream for one minute, so EventSource clients don't put the admin token in the URL,
+// @Description where it would end up in access logs. The stream stays open until the admin token expires.
+// @Tags admin-suggestions
+// @Security ApiKeyAuth
+// @Produce  json
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/events/ticket [post]
+func CreateEventTicket(c *gin.Context) {
+	claims, _ := c.Get("user_claims")
+	ticket, err := utils.SignEventTicket(claims.(*utils.Claims))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ticket": ticket, "expires_in": int(utils.EventTicketTTL.Seconds())})
+}
+
+// StreamEvents godoc
+// @Summary Stream suggestion events
+// @Description Server-Sent Events stream of suggestion.created, suggestion.updated, suggestion.replied and suggestion.deleted events
+// @Description for the suggestions the admin can see. Reconnecting clients send Last-Event-ID to receive the events they missed;
+// @Description a "reset" event tells them to refetch when that is not possible. EventSource clients, which cannot set the Authorization header,
+// @Description pass a ticket from POST /admin/events/ticket instead, and the events they missed as last_event_id when reconnecting with a new ticket.
+// @Tags admin-suggestions
+// @Security ApiKeyAuth
+// @Produce  text/event-stream
+// @Param Last-Event-ID header string false "ID of the last event received"
+// @Param ticket query string false "Event stream ticket, for clients that cannot set the Authorization header"
+// @Param last_event_id query string false "ID of the last event received, for clients that cannot set Last-Event-ID"
+// @Success 200 {string} string "event stream"
+// @Router /admin/events [get]
+func StreamEvents(c *gin.Context) {
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	sub, missed, complete := events.Default.Subscribe(lastEventID)
+	defer events.Default.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable response buffering in nginx
+	c.Status(http.StatusOK)
+
+	fmt.Fprintf(c.Writer, "retry: 3000\n\n")
+	if !complete {
+		fmt.Fprintf(c.Writer, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range missed {
+		if canSeeEvent(adminClaims, event) {
+			if err := writeEvent(c, event); err != nil {
+				return
+			}
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	// End the stream when the token expires, the client reconnects with a new one
+	var expired <-chan time.Time
+	if adminClaims.ExpiresAt != nil {
+		timer := time.NewTimer(time.Until(adminClaims.ExpiresAt.Time))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-expired:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprintf(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event, ok := <-sub.C:
+			if !ok {
+				return // Dropped for falling behind, the client reconnects and replays
+			}
+			if !canSeeEvent(adminClaims, event) {
+				continue
+			}
+			if err := writeEvent(c, event); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
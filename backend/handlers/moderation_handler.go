@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WordListInput struct {
+	Name     string `json:"name" binding:"required"`
+	Action   string `json:"action" binding:"required"` // "block", "mask", "flag"
+	Words    string `json:"words"`                     // One word per line or comma separated
+	IsActive *bool  `json:"is_active"`
+}
+
+type ModerationTestInput struct {
+	Text string `json:"text" binding:"required"`
+}
+
+// bindWordListInput binds and validates a sensitive word list request body
+func bindWordListInput(c *gin.Context) (*WordListInput, bool) {
+	var input WordListInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if !services.IsValidModerationAction(input.Action) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Action must be one of: block, mask, flag"})
+		return nil, false
+	}
+	input.Words = strings.Join(services.SplitWords(input.Words), "\n")
+	return &input, true
+}
+
+// moderationBlockedResponse responds to text rejected by a "block" word list
+func moderationBlockedResponse(c *gin.Context) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": "内容包含违规词语，请修改后再提交"})
+}
+
+// maskPersonalInfo hides phone, ID card and student numbers in a suggestion before public display,
+// along with the moderation result, whose matched words would reveal the word lists and the masked text
+func maskPersonalInfo(suggestion *models.Suggestion) {
+	suggestion.StudentNumber = ""
+	suggestion.Moderation = models.ModerationResult{}
+	// The roster name and class of a verified submitter are shown to admins only
+	if suggestion.IsVerified {
+		suggestion.SubmitterName = ""
+		suggestion.SubmitterClass = ""
+	}
+	suggestion.Title = services.MaskPII(suggestion.Title)
+	suggestion.Content = services.MaskPII(suggestion.Content)
+	for i := range suggestion.Replies {
+		suggestion.Replies[i].Content = services.MaskPII(suggestion.Replies[i].Content)
+	}
+}
+
+// GetWordLists godoc
+// @Summary Get all sensitive word lists
+// @Description Get the word lists checked by content moderation.
+// @Tags admin-moderation
+// @Security ApiKeyAuth
+// @Produce  json
+// @Success 200 {array} models.SensitiveWordList
+// @Router /admin/word-lists [get]
+func GetWordLists(c *gin.Context) {
+	var lists []models.SensitiveWordList
+	if err := database.DB.Order("id ASC").Find(&lists).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve word lists"})
+		return
+	}
+	c.JSON(http.StatusOK, lists)
+}
+
+// CreateWordList godoc
+// @Summary Create a sensitive word list
+// @Description Add a word list. Matching words are rejected (block), replaced with asterisks (mask) or kept and flagged for review (flag).
+// @Tags admin-moderation
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param list body WordListInput true "Word List"
+// @Success 200 {object} models.SensitiveWordList
+// @Router /admin/word-lists [post]
+func CreateWordList(c *gin.Context) {
+	input, ok := bindWordListInput(c)
+	if !ok {
+		return
+	}
+
+	list := models.SensitiveWordList{
+		Name:     input.Name,
+		Action:   input.Action,
+		Words:    input.Words,
+		IsActive: input.IsActive == nil || *input.IsActive,
+	}
+	if err := database.DB.Create(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create word list"})
+		return
+	}
+	services.ReloadWordLists()
+	c.JSON(http.StatusOK, list)
+}
+
+// UpdateWordList godoc
+// @Summary Update a sensitive word list
+// @Description Update an existing word list. Content already submitted is not checked again.
+// @Tags admin-moderation
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Word List ID"
+// @Param list body WordListInput true "Word List"
+// @Success 200 {object} models.SensitiveWordList
+// @Router /admin/word-lists/{id} [put]
+func UpdateWordList(c *gin.Context) {
+	listID := c.Param("id")
+	input, ok := bindWordListInput(c)
+	if !ok {
+		return
+	}
+
+	var list models.SensitiveWordList
+	if err := database.DB.First(&list, listID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Word list not found"})
+		return
+	}
+
+	list.Name = input.Name
+	list.Action = input.Action
+	list.Words = input.Words
+	if input.IsActive != nil {
+		list.IsActive = *input.IsActive
+	}
+	if err := database.DB.Save(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update word list"})
+		return
+	}
+	services.ReloadWordLists()
+	c.JSON(http.StatusOK, list)
+}
+
+// DeleteWordList godoc
+// @Summary Delete a sensitive word list
+// @Description Remove a word list.
+// @Tags admin-moderation
+// @Security ApiKeyAuth
+// @Param id path int true "Word List ID"
+// @Success 204
+// @Router /admin/word-lists/{id} [delete]
+func DeleteWordList(c *gin.Context) {
+	if err := database.DB.Delete(&models.SensitiveWordList{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete word list"})
+		return
+	}
+	services.ReloadWordLists()
+	c.Status(http.StatusNoContent)
+}
+
+// TestModeration godoc
+// @Summary Test content moderation
+// @Description Run the moderation pipeline on a sample text and show the result, the text as stored and the text as displayed publicly.
+// @Tags admin-moderation
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param sample body ModerationTestInput true "Sample Text"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/word-lists/test [post]
+func TestModeration(c *gin.Context) {
+	var input ModerationTestInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	text := input.Text
+	check := services.Moderate(&text)
+	c.JSON(http.StatusOK, gin.H{
+		"blocked":       check.Blocked,
+		"blocked_words": check.BlockedWords,
+		"result":        check.Result,
+		"stored_text":   text,
+		"public_text":   services.MaskPII(text),
+	})
+}
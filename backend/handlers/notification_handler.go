@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/services"
+	"advice/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotifications godoc
+// @Summary Get my notifications
+// @Description Get the notifications of the current admin, newest first, with the number of unread ones.
+// @Tags admin-notifications
+// @Security ApiKeyAuth
+// @Produce  json
+// @Param unread query bool false "Only unread (true) or read (false) notifications"
+// @Param type query string false "Filter by type (new_suggestion, follow_up, transfer, sla_warning)"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/notifications [get]
+func GetNotifications(c *gin.Context) {
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+	page, pageSize := paginate(c)
+
+	query := database.DB.Model(&models.Notification{}).Where("admin_id = ?", adminClaims.UserID)
+	if unread, set, err := parseBoolQuery(c, "unread"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if set && unread {
+		query = query.Where("read_at IS NULL")
+	} else if set {
+		query = query.Where("read_at IS NOT NULL")
+	}
+	if kind := c.Query("type"); kind != "" {
+		query = query.Where("type = ?", kind)
+	}
+
+	var total, unreadCount int64
+	notifications := []models.Notification{}
+	query.Count(&total)
+	if err := query.Order("created_at DESC, id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+		return
+	}
+	database.DB.Model(&models.Notification{}).Where("admin_id = ? AND read_at IS NULL", adminClaims.UserID).Count(&unreadCount)
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":        total,
+		"unread_count": unreadCount,
+		"page":         page,
+		"page_size":    pageSize,
+		"data":         notifications,
+	})
+}
+
+type MarkNotificationInput struct {
+	Read *bool `json:"read" binding:"required"`
+}
+
+// MarkNotification godoc
+// @Summary Mark a notification as read or unread
+// @Description Mark one of the current admin's notifications as read or unread.
+// @Tags admin-notifications
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Notification ID"
+// @Param read body MarkNotificationInput true "Read State"
+// @Success 200 {object} models.Notification
+// @Router /admin/notifications/{id} [put]
+func MarkNotification(c *gin.Context) {
+	var input MarkNotificationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+
+	var notification models.Notification
+	if err := database.DB.Where("id = ? AND admin_id = ?", c.Param("id"), adminClaims.UserID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	var readAt *time.Time
+	if *input.Read {
+		now := time.Now()
+		readAt = &now
+		if notification.ReadAt != nil {
+			readAt = notification.ReadAt
+		}
+	}
+	if err := database.DB.Model(&notification).Update("read_at", readAt).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+	notification.ReadAt = readAt
+
+	c.JSON(http.StatusOK, notification)
+}
+
+// MarkAllNotificationsRead godoc
+// @Summary Mark all notifications as read
+// @Description Mark every unread notification of the current admin as read.
+// @Tags admin-notifications
+// @Security ApiKeyAuth
+// @Produce  json
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/notifications/read-all [post]
+func MarkAllNotificationsRead(c *gin.Context) {
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+
+	result := database.DB.Model(&models.Notification{}).Where("admin_id = ? AND read_at IS NULL", adminClaims.UserID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": result.RowsAffected})
+}
+
+type NotificationPreferenceInput struct {
+	MutedTypes []string `json:"muted_types"`
+	Email      string   `json:"email"`
+	EmailMode  string   `json:"email_mode" binding:"required"` // "off", "instant", "daily"
+}
+
+type NotificationPreferenceResponse struct {
+	MutedTypes []string   `json:"muted_types"`
+	Email      string     `json:"email"`
+	EmailMode  string     `json:"email_mode"`
+	LastDigest *time.Time `json:"last_digest_at"`
+}
+
+func preferenceResponse(pref models.NotificationPreference) NotificationPreferenceResponse {
+	muted := []string{}
+	for _, t := range strings.Split(pref.MutedTypes, ",") {
+		if t != "" {
+			muted = append(muted, t)
+		}
+	}
+	if pref.EmailMode == "" {
+		pref.EmailMode = "off"
+	}
+	return NotificationPreferenceResponse{
+		MutedTypes: muted,
+		Email:      pref.Email,
+		EmailMode:  pref.EmailMode,
+		LastDigest: pref.LastDigestAt,
+	}
+}
+
+// GetNotificationPreferences godoc
+// @Summary Get my notification preferences
+// @Description Get which notification types the current admin muted and how they are emailed.
+// @Tags admin-notifications
+// @Security ApiKeyAuth
+// @Produce  json
+// @Success 200 {object} NotificationPreferenceResponse
+// @Router /admin/notifications/preferences [get]
+func GetNotificationPreferences(c *gin.Context) {
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+
+	var pref models.NotificationPreference
+	database.DB.Where("admin_id = ?", adminClaims.UserID).Limit(1).Find(&pref)
+
+	c.JSON(http.StatusOK, preferenceResponse(pref))
+}
+
+// UpdateNotificationPreferences godoc
+// @Summary Update my notification preferences
+// @Description Mute notification types and choose whether notifications are emailed instantly, in a daily digest or not at all.
+// @Tags admin-notifications
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param preferences body NotificationPreferenceInput true "Notification Preferences"
+// @Success 200 {object} NotificationPreferenceResponse
+// @Router /admin/notifications/preferences [put]
+func UpdateNotificationPreferences(c *gin.Context) {
+	var input NotificationPreferenceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, t := range input.MutedTypes {
+		if !services.IsValidNotificationType(t) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification type: " + t})
+			return
+		}
+	}
+	if !services.IsValidEmailMode(input.EmailMode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email mode"})
+		return
+	}
+	if input.Email != "" {
+		email, err := services.NormalizeContactEmail(input.Email)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+			return
+		}
+		input.Email = email
+	}
+	if input.EmailMode != "off" && input.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An email address is required to receive notifications by email"})
+		return
+	}
+
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+
+	var pref models.NotificationPreference
+	database.DB.Where("admin_id = ?", adminClaims.UserID).Limit(1).Find(&pref)
+	pref.AdminID = adminClaims.UserID
+	pref.MutedTypes = strings.Join(input.MutedTypes, ",")
+	pref.Email = input.Email
+	pref.EmailMode = input.EmailMode
+	if err := database.DB.Save(&pref).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferenceResponse(pref))
+}
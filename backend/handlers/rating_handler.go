@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/services"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RatingInput struct {
+	Score   int    `json:"score" binding:"required,min=1,max=5"`
+	Comment string `json:"comment"`
+}
+
+type ReopenInput struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// getTrackedSuggestion loads the suggestion of the tracking code in the URL, responding with an error when not found
+func getTrackedSuggestion(c *gin.Context) (*models.Suggestion, bool) {
+	var suggestion models.Suggestion
+	if err := database.DB.Where("tracking_code = ?", c.Param("tracking_code")).First(&suggestion).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestion not found"})
+		return nil, false
+	}
+	return &suggestion, true
+}
+
+// RateSuggestion godoc
+// @Summary Rate the resolution of a suggestion
+// @Description Rate a resolved suggestion from 1 to 5 with an optional comment, once per resolution.
+// @Tags suggestions
+// @Accept  json
+// @Produce  json
+// @Param tracking_code path string true "Tracking Code"
+// @Param rating body RatingInput true "Rating"
+// @Success 200 {object} models.SatisfactionRating
+// @Router /tracking/{tracking_code}/rating [post]
+func RateSuggestion(c *gin.Context) {
+	var input RatingInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Comment) > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "评价内容不能超过1000个字符"})
+		return
+	}
+
+	suggestion, ok := getTrackedSuggestion(c)
+	if !ok {
+		return
+	}
+	if suggestion.Status != "已解决" || suggestion.ResolvedAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只能评价已解决的建议"})
+		return
+	}
+
+	if services.Moderate(&input.Comment).Blocked {
+		moderationBlockedResponse(c)
+		return
+	}
+
+	rating := models.SatisfactionRating{
+		SuggestionID: suggestion.ID,
+		ResolvedAt:   *suggestion.ResolvedAt,
+		Score:        input.Score,
+		Comment:      input.Comment,
+		AdminID:      services.RatedAdmin(suggestion),
+		DepartmentID: suggestion.DepartmentID,
+	}
+	result := database.DB.Where("suggestion_id = ? AND resolved_at = ?", suggestion.ID, rating.ResolvedAt).
+		Attrs(rating).FirstOrCreate(&rating)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rating"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "您已评价过本次处理结果"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rating)
+}
+
+// ReopenSuggestion godoc
+// @Summary Reopen a resolved suggestion
+// @Description Send a resolved suggestion back to 处理中 when the student is not satisfied, within the reopen window after resolution.
+// @Description Not possible once the resolution was rated above 2.
+// @Tags suggestions
+// @Accept  json
+// @Produce  json
+// @Param tracking_code path string true "Tracking Code"
+// @Param reason body ReopenInput true "Reason"
+// @Success 200 {object} map[string]string
+// @Router /tracking/{tracking_code}/reopen [post]
+func ReopenSuggestion(c *gin.Context) {
+	var input ReopenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Reason) > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "重新处理的理由不能超过1000个字符"})
+		return
+	}
+
+	suggestion, ok := getTrackedSuggestion(c)
+	if !ok {
+		return
+	}
+	if suggestion.Status != "已解决" || suggestion.ResolvedAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只能重新打开已解决的建议"})
+		return
+	}
+	if suggestion.ReopenableUntil == nil || time.Now().After(*suggestion.ReopenableUntil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "已超过可重新打开的期限"})
+		return
+	}
+
+	var rating models.SatisfactionRating
+	err := database.DB.Where("suggestion_id = ? AND resolved_at = ?", suggestion.ID, *suggestion.ResolvedAt).First(&rating).Error
+	if err == nil && rating.Score > services.MaxUnsatisfiedScore {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "您已对处理结果表示满意，无法重新打开"})
+		return
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rating"})
+		return
+	}
+
+	if services.Moderate(&input.Reason).Blocked {
+		moderationBlockedResponse(c)
+		return
+	}
+
+	services.ClearResolution(suggestion)
+	suggestion.Status = "处理中"
+	suggestion.ReopenCount++
+	suggestion.ReopenReason = input.Reason
+	if err := database.DB.Save(suggestion).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reopen suggestion"})
+		return
+	}
+
+	services.NotifyResponsibleAdmins(suggestion, services.NotificationFollowUp, "学生重新打开了建议",
+		fmt.Sprintf("建议 #%d「%s」的提交者对处理结果不满意并重新打开：%s", suggestion.ID, suggestion.Title, input.Reason))
+	services.PublishSuggestionEvent(services.EventSuggestionUpdated, suggestion)
+	services.NotifyStudentStatus(suggestion.ID)
+
+	c.JSON(http.StatusOK, gin.H{"status": suggestion.Status})
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/services"
+	"advice/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReactionInput struct {
+	Type string `json:"type" binding:"required"` // "me_too", "disagree"
+}
+
+// respondWithReactions sends the current reaction counts of a suggestion
+func respondWithReactions(c *gin.Context, suggestionID uint, reaction string) {
+	var suggestion models.Suggestion
+	database.DB.Select("me_too_count", "disagree_count").First(&suggestion, suggestionID)
+	c.JSON(http.StatusOK, gin.H{
+		"me_too_count":   suggestion.MeTooCount,
+		"disagree_count": suggestion.DisagreeCount,
+		"reaction":       reaction,
+	})
+}
+
+// ReactToSuggestion godoc
+// @Summary React to a suggestion
+// @Description React to a public, approved suggestion with "me_too" (I have this problem too) or "disagree". Each voter has one reaction per suggestion; reacting again changes it.
+// @Tags suggestions
+// @Accept  json
+// @Produce  json
+// @Param   id     path    int     true        "Suggestion ID"
+// @Param reaction body ReactionInput true "Reaction"
+// @Success 200 {object} map[string]interface{}
+// @Router /suggestions/{id}/reactions [post]
+func ReactToSuggestion(c *gin.Context) {
+	var input ReactionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !services.IsValidReactionType(input.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reaction type must be one of: me_too, disagree"})
+		return
+	}
+
+	suggestion, ok := getVotableSuggestion(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	reaction := models.Reaction{
+		SuggestionID: suggestion.ID,
+		VoterID:      ensureVoterID(c),
+		Type:         input.Type,
+		IPHash:       utils.HashIP(c.ClientIP()),
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Reaction{}).
+			Where("suggestion_id = ? AND voter_id = ?", reaction.SuggestionID, reaction.VoterID).
+			Update("type", reaction.Type)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var ipReactions int64
+			if err := tx.Model(&models.Reaction{}).Where("suggestion_id = ? AND ip_hash = ?", reaction.SuggestionID, reaction.IPHash).Count(&ipReactions).Error; err != nil {
+				return err
+			}
+			if ipReactions >= maxVotesPerIP {
+				return errIPVoteLimit
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction).Error; err != nil {
+				return err
+			}
+		}
+		return services.RecountInteractions(tx, suggestion.ID)
+	})
+	switch {
+	case errors.Is(err, errIPVoteLimit):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many reactions from your network for this suggestion"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save reaction"})
+		return
+	}
+
+	respondWithReactions(c, suggestion.ID, reaction.Type)
+}
+
+// RemoveReaction godoc
+// @Summary Remove a reaction
+// @Description Withdraw the current voter's reaction to a suggestion.
+// @Tags suggestions
+// @Produce  json
+// @Param   id     path    int     true        "Suggestion ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /suggestions/{id}/reactions [delete]
+func RemoveReaction(c *gin.Context) {
+	suggestion, ok := getVotableSuggestion(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	voterID, ok := currentVoterID(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You have not reacted to this suggestion"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("suggestion_id = ? AND voter_id = ?", suggestion.ID, voterID).Delete(&models.Reaction{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNotVotedYet
+		}
+		return services.RecountInteractions(tx, suggestion.ID)
+	})
+	switch {
+	case errors.Is(err, errNotVotedYet):
+		c.JSON(http.StatusNotFound, gin.H{"error": "You have not reacted to this suggestion"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove reaction"})
+		return
+	}
+
+	respondWithReactions(c, suggestion.ID, "")
+}
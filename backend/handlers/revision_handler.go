@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/services"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type EditSuggestionInput struct {
+	Title        string `json:"title" binding:"required"`
+	Content      string `json:"content" binding:"required"`
+	Category     string `json:"category"`
+	DepartmentID uint   `json:"department_id"` // 0 for all departments
+}
+
+type WithdrawInput struct {
+	Reason string `json:"reason"`
+}
+
+// EditSuggestion godoc
+// @Summary Edit a suggestion awaiting review
+// @Description Change the title, content, category or department of a suggestion while it is still 待审核.
+// @Description The previous text is kept in the suggestion's revisions for admins.
+// @Tags suggestions
+// @Accept  json
+// @Produce  json
+// @Param tracking_code path string true "Tracking Code"
+// @Param suggestion body EditSuggestionInput true "Edited Suggestion"
+// @Success 200 {object} models.Suggestion
+// @Router /tracking/{tracking_code} [put]
+func EditSuggestion(c *gin.Context) {
+	var input EditSuggestionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Content) > 3000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "建议内容不能超过3000个字符"})
+		return
+	}
+	if len(input.Title) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "建议标题不能超过100个字符"})
+		return
+	}
+
+	suggestion, ok := getTrackedSuggestion(c)
+	if !ok {
+		return
+	}
+	if suggestion.Status != "待审核" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "建议已进入处理流程，无法修改"})
+		return
+	}
+
+	// The submitter's name and class are moderated again so the result covers the whole suggestion
+	name, class := suggestion.SubmitterName, suggestion.SubmitterClass
+	check := services.Moderate(&input.Title, &input.Content, &name, &class)
+	if check.Blocked {
+		moderationBlockedResponse(c)
+		return
+	}
+
+	revision := models.SuggestionRevision{
+		SuggestionID: suggestion.ID,
+		Title:        suggestion.Title,
+		Content:      suggestion.Content,
+		Category:     suggestion.Category,
+		DepartmentID: suggestion.DepartmentID,
+	}
+	formerDepartmentID := suggestion.DepartmentID
+
+	suggestion.Title = input.Title
+	suggestion.Content = input.Content
+	suggestion.Moderation = check.Result
+	if !setCategoryAndDepartment(c, suggestion, input.Category, input.DepartmentID) {
+		return
+	}
+	if revision.Title == suggestion.Title && revision.Content == suggestion.Content && revision.Category == suggestion.Category &&
+		sameDepartment(revision.DepartmentID, suggestion.DepartmentID) {
+		c.JSON(http.StatusOK, suggestion)
+		return
+	}
+	services.ApplySLA(suggestion)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		return tx.Save(suggestion).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update suggestion"})
+		return
+	}
+	services.IndexSuggestion(suggestion.ID)
+	// Duplicate candidates are found again for the new text
+	database.DB.Where("suggestion_id = ?", suggestion.ID).Delete(&models.DuplicateCandidate{})
+	services.RecordDuplicateCandidates(suggestion)
+
+	services.NotifyResponsibleAdmins(suggestion, services.NotificationFollowUp, "学生修改了建议",
+		fmt.Sprintf("建议 #%d「%s」的提交者在审核前修改了建议内容", suggestion.ID, suggestion.Title))
+	services.PublishSuggestionEvent(services.EventSuggestionUpdated, suggestion, formerDepartmentID)
+
+	c.JSON(http.StatusOK, suggestion)
+}
+
+// sameDepartment reports whether two optional department IDs are equal
+func sameDepartment(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// WithdrawSuggestion godoc
+// @Summary Withdraw a suggestion
+// @Description Withdraw a suggestion at any stage. 已撤回 is final: the suggestion is hidden from the public and admins can no longer change its status.
+// @Tags suggestions
+// @Accept  json
+// @Produce  json
+// @Param tracking_code path string true "Tracking Code"
+// @Param reason body WithdrawInput false "Reason"
+// @Success 200 {object} map[string]string
+// @Router /tracking/{tracking_code}/withdraw [post]
+func WithdrawSuggestion(c *gin.Context) {
+	var input WithdrawInput
+	// The reason is optional, so an empty body is accepted
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if len(input.Reason) > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "撤回理由不能超过1000个字符"})
+		return
+	}
+
+	suggestion, ok := getTrackedSuggestion(c)
+	if !ok {
+		return
+	}
+	if suggestion.Status == "已撤回" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "建议已撤回"})
+		return
+	}
+
+	if services.Moderate(&input.Reason).Blocked {
+		moderationBlockedResponse(c)
+		return
+	}
+
+	if suggestion.Status == "已解决" {
+		services.ClearResolution(suggestion)
+	}
+	now := time.Now()
+	suggestion.Status = "已撤回"
+	suggestion.WithdrawnAt = &now
+	suggestion.WithdrawReason = input.Reason
+	if err := database.DB.Save(suggestion).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to withdraw suggestion"})
+		return
+	}
+
+	message := fmt.Sprintf("建议 #%d「%s」已被提交者撤回", suggestion.ID, suggestion.Title)
+	if input.Reason != "" {
+		message += "：" + input.Reason
+	}
+	services.NotifyResponsibleAdmins(suggestion, services.NotificationFollowUp, "学生撤回了建议", message)
+	services.PublishSuggestionEvent(services.EventSuggestionUpdated, suggestion)
+	services.NotifyStudentStatus(suggestion.ID)
+
+	c.JSON(http.StatusOK, gin.H{"status": suggestion.Status})
+}
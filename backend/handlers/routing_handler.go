@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/services"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoutingRuleInput struct {
+	Name         string `json:"name" binding:"required"`
+	Priority     int    `json:"priority"`
+	Category     string `json:"category"`
+	Keywords     string `json:"keywords"` // Comma separated
+	Pattern      string `json:"pattern"`  // Regular expression
+	DepartmentID uint   `json:"department_id" binding:"required"`
+	IsActive     *bool  `json:"is_active"`
+}
+
+type RoutingTestInput struct {
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Category string `json:"category"`
+}
+
+// bindRoutingRuleInput binds and validates a routing rule request body
+func bindRoutingRuleInput(c *gin.Context) (*RoutingRuleInput, bool) {
+	var input RoutingRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	input.Keywords = strings.Join(services.SplitKeywords(input.Keywords), ",")
+	if input.Category == "" && input.Keywords == "" && input.Pattern == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A routing rule needs a category, keywords or a pattern"})
+		return nil, false
+	}
+	if input.Pattern != "" {
+		if _, err := regexp.Compile(input.Pattern); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pattern: " + err.Error()})
+			return nil, false
+		}
+	}
+	if input.Category != "" {
+		var category models.Category
+		if err := database.DB.Where("name = ?", input.Category).First(&category).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category"})
+			return nil, false
+		}
+	}
+
+	var department models.Department
+	if err := database.DB.First(&department, input.DepartmentID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		return nil, false
+	}
+
+	return &input, true
+}
+
+// GetRoutingRules godoc
+// @Summary Get all routing rules
+// @Description Get the rules that assign departments to suggestions, in the order they are tried.
+// @Tags admin-routing
+// @Security ApiKeyAuth
+// @Produce  json
+// @Success 200 {array} models.RoutingRule
+// @Router /admin/routing-rules [get]
+func GetRoutingRules(c *gin.Context) {
+	var rules []models.RoutingRule
+	if err := database.DB.Preload("Department").Order("priority ASC, id ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve routing rules"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateRoutingRule godoc
+// @Summary Create a routing rule
+// @Description Add a rule assigning a department to suggestions submitted without one. Every condition that is set must match.
+// @Tags admin-routing
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param rule body RoutingRuleInput true "Routing Rule"
+// @Success 200 {object} models.RoutingRule
+// @Router /admin/routing-rules [post]
+func CreateRoutingRule(c *gin.Context) {
+	input, ok := bindRoutingRuleInput(c)
+	if !ok {
+		return
+	}
+
+	rule := models.RoutingRule{
+		Name:         input.Name,
+		Priority:     input.Priority,
+		Category:     input.Category,
+		Keywords:     input.Keywords,
+		Pattern:      input.Pattern,
+		DepartmentID: input.DepartmentID,
+		IsActive:     input.IsActive == nil || *input.IsActive,
+	}
+	if err := database.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create routing rule"})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// UpdateRoutingRule godoc
+// @Summary Update a routing rule
+// @Description Update an existing routing rule.
+// @Tags admin-routing
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Routing Rule ID"
+// @Param rule body RoutingRuleInput true "Routing Rule"
+// @Success 200 {object} models.RoutingRule
+// @Router /admin/routing-rules/{id} [put]
+func UpdateRoutingRule(c *gin.Context) {
+	ruleID := c.Param("id")
+	input, ok := bindRoutingRuleInput(c)
+	if !ok {
+		return
+	}
+
+	var rule models.RoutingRule
+	if err := database.DB.First(&rule, ruleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Routing rule not found"})
+		return
+	}
+
+	rule.Name = input.Name
+	rule.Priority = input.Priority
+	rule.Category = input.Category
+	rule.Keywords = input.Keywords
+	rule.Pattern = input.Pattern
+	rule.DepartmentID = input.DepartmentID
+	if input.IsActive != nil {
+		rule.IsActive = *input.IsActive
+	}
+	if err := database.DB.Omit("Department").Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update routing rule"})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRoutingRule godoc
+// @Summary Delete a routing rule
+// @Description Remove a routing rule.
+// @Tags admin-routing
+// @Security ApiKeyAuth
+// @Param id path int true "Routing Rule ID"
+// @Success 204
+// @Router /admin/routing-rules/{id} [delete]
+func DeleteRoutingRule(c *gin.Context) {
+	if err := database.DB.Delete(&models.RoutingRule{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete routing rule"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// TestRoutingRules godoc
+// @Summary Test the routing rules
+// @Description Show which active routing rule would fire for a sample suggestion, and every rule that matches it.
+// @Tags admin-routing
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param sample body RoutingTestInput true "Sample Suggestion"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/routing-rules/test [post]
+func TestRoutingRules(c *gin.Context) {
+	var input RoutingTestInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	matched := services.MatchingRoutingRules(input.Title, input.Content, input.Category)
+	if matched == nil {
+		matched = []models.RoutingRule{}
+	}
+
+	var fired *models.RoutingRule
+	if len(matched) > 0 {
+		fired = &matched[0]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rule":    fired,
+		"matched": matched,
+	})
+}
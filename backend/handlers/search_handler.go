@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/services"
+	"advice/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const snippetWidth = 80
+
+type SearchResult struct {
+	Suggestion     models.Suggestion `json:"suggestion"`
+	TitleHighlight string            `json:"title_highlight"`
+	Snippet        string            `json:"snippet"`
+	Score          float64           `json:"score"`
+}
+
+type PublicSearchResult struct {
+	ID             uint      `json:"id"`
+	Title          string    `json:"title"`
+	Status         string    `json:"status"`
+	DepartmentName string    `json:"department_name"`
+	Upvotes        int       `json:"upvotes"`
+	CreatedAt      time.Time `json:"created_at"`
+	TitleHighlight string    `json:"title_highlight"`
+	Snippet        string    `json:"snippet"`
+}
+
+// searchParams reads the search text and pagination, responding with an error when the text is missing
+func searchParams(c *gin.Context) (q string, page, pageSize int, ok bool) {
+	q = strings.TrimSpace(c.Query("q"))
+	if services.BuildMatchQuery(q) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search text is required"})
+		return "", 0, 0, false
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err = strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 10
+	}
+	return q, page, pageSize, true
+}
+
+// loadSearchHits loads the suggestions of the search hits, keeping their ranking order
+func loadSearchHits(hits []services.SearchHit) ([]models.Suggestion, error) {
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.SuggestionID
+	}
+
+	var suggestions []models.Suggestion
+	if err := database.DB.Preload("Department").Preload("Assignee").Preload("Replies").Where("id IN ?", ids).Find(&suggestions).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Suggestion, len(suggestions))
+	for _, s := range suggestions {
+		byID[s.ID] = s
+	}
+	ordered := make([]models.Suggestion, 0, len(hits))
+	for _, hit := range hits {
+		if s, ok := byID[hit.SuggestionID]; ok {
+			ordered = append(ordered, s)
+		}
+	}
+	return ordered, nil
+}
+
+// highlightSuggestion builds the highlighted title and the best matching snippet of a suggestion
+func highlightSuggestion(suggestion *models.Suggestion, q string) (title, snippet string) {
+	terms := utils.SplitTerms(q)
+
+	title = utils.Highlight(suggestion.Title, terms, len([]rune(suggestion.Title)))
+	if title == "" {
+		title = utils.Excerpt(suggestion.Title, len([]rune(suggestion.Title)))
+	}
+
+	snippet = utils.Highlight(suggestion.Content, terms, snippetWidth)
+	for i := 0; snippet == "" && i < len(suggestion.Replies); i++ {
+		snippet = utils.Highlight(suggestion.Replies[i].Content, terms, snippetWidth)
+	}
+	if snippet == "" {
+		snippet = utils.Excerpt(suggestion.Content, snippetWidth)
+	}
+	return title, snippet
+}
+
+// SearchSuggestions godoc
+// @Summary Search suggestions (for admins)
+// @Description Full-text search over the title, content and replies of the suggestions visible to the admin. Results are ranked by relevance, highlights are HTML with matches wrapped in <mark>.
+// @Tags admin-suggestions
+// @Security ApiKeyAuth
+// @Produce  json
+// @Param q query string true "Search text"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/search [get]
+func SearchSuggestions(c *gin.Context) {
+	q, page, pageSize, ok := searchParams(c)
+	if !ok {
+		return
+	}
+
+	claims, _ := c.Get("user_claims")
+	adminClaims := claims.(*utils.Claims)
+
+	scope := func(query *gorm.DB) *gorm.DB {
+		return restrictToVisibleSuggestions(query, adminClaims)
+	}
+	hits, total, err := services.SearchSuggestions(q, scope, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search suggestions"})
+		return
+	}
+	suggestions, err := loadSearchHits(hits)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve suggestions"})
+		return
+	}
+
+	scores := make(map[uint]float64, len(hits))
+	for _, hit := range hits {
+		scores[hit.SuggestionID] = hit.Score
+	}
+
+	results := make([]SearchResult, 0, len(suggestions))
+	for _, s := range suggestions {
+		title, snippet := highlightSuggestion(&s, q)
+		s.Assignee.PasswordHash = ""
+		s.Replies = nil
+		results = append(results, SearchResult{
+			Suggestion:     s,
+			TitleHighlight: title,
+			Snippet:        snippet,
+			Score:          scores[s.ID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"data":      results,
+	})
+}
+
+// SearchPublicSuggestions godoc
+// @Summary Search public suggestions
+// @Description Full-text search over public, approved suggestions and their replies.
+// @Tags suggestions
+// @Produce  json
+// @Param q query string true "Search text"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} map[string]interface{}
+// @Router /suggestions/search [get]
+func SearchPublicSuggestions(c *gin.Context) {
+	q, page, pageSize, ok := searchParams(c)
+	if !ok {
+		return
+	}
+
+	scope := func(query *gorm.DB) *gorm.DB {
+		return query.Where("suggestions.is_public = ?", true).
+			Where("suggestions.status NOT IN ?", []string{"待审核", "审核不通过", "已撤回"}).
+			Where("suggestions.canonical_id IS NULL")
+	}
+	hits, total, err := services.SearchSuggestions(q, scope, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search suggestions"})
+		return
+	}
+	suggestions, err := loadSearchHits(hits)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve suggestions"})
+		return
+	}
+
+	results := make([]PublicSearchResult, 0, len(suggestions))
+	for _, s := range suggestions {
+		maskPersonalInfo(&s)
+		title, snippet := highlightSuggestion(&s, q)
+		results = append(results, PublicSearchResult{
+			ID:             s.ID,
+			Title:          s.Title,
+			Status:         s.Status,
+			DepartmentName: s.Department.Name,
+			Upvotes:        s.Upvotes,
+			CreatedAt:      s.CreatedAt,
+			TitleHighlight: title,
+			Snippet:        snippet,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"data":      results,
+	})
+}
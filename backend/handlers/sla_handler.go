@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SLAPolicyInput struct {
+	DepartmentID   *uint  `json:"department_id"`
+	Category       string `json:"category"`
+	FirstReplyDays int    `json:"first_reply_days" binding:"min=0"`
+	ResolutionDays int    `json:"resolution_days" binding:"required,min=1"`
+}
+
+// bindSLAPolicyInput binds and validates an SLA policy request body
+func bindSLAPolicyInput(c *gin.Context) (*SLAPolicyInput, bool) {
+	var input SLAPolicyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if input.FirstReplyDays > input.ResolutionDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "First reply deadline cannot be later than resolution deadline"})
+		return nil, false
+	}
+
+	// Validate DepartmentID exists if provided
+	if input.DepartmentID != nil {
+		var department models.Department
+		if err := database.DB.First(&department, *input.DepartmentID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+			return nil, false
+		}
+	}
+
+	return &input, true
+}
+
+// GetSLAPolicies godoc
+// @Summary Get all SLA policies
+// @Description Get the response deadlines configured per department and category.
+// @Tags admin-sla
+// @Security ApiKeyAuth
+// @Produce  json
+// @Success 200 {array} models.SLAPolicy
+// @Router /admin/sla-policies [get]
+func GetSLAPolicies(c *gin.Context) {
+	var policies []models.SLAPolicy
+	if err := database.DB.Preload("Department").Find(&policies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve SLA policies"})
+		return
+	}
+	c.JSON(http.StatusOK, policies)
+}
+
+// CreateSLAPolicy godoc
+// @Summary Create an SLA policy
+// @Description Add response deadlines, in working days, for a department and/or category. Leave both empty for the default policy.
+// @Tags admin-sla
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param policy body SLAPolicyInput true "SLA Policy"
+// @Success 200 {object} models.SLAPolicy
+// @Router /admin/sla-policies [post]
+func CreateSLAPolicy(c *gin.Context) {
+	input, ok := bindSLAPolicyInput(c)
+	if !ok {
+		return
+	}
+
+	policy := models.SLAPolicy{
+		DepartmentID:   input.DepartmentID,
+		Category:       input.Category,
+		FirstReplyDays: input.FirstReplyDays,
+		ResolutionDays: input.ResolutionDays,
+	}
+	if err := database.DB.Create(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create SLA policy"})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// UpdateSLAPolicy godoc
+// @Summary Update an SLA policy
+// @Description Update an existing SLA policy. Due dates of existing suggestions are not recalculated.
+// @Tags admin-sla
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "SLA Policy ID"
+// @Param policy body SLAPolicyInput true "SLA Policy"
+// @Success 200 {object} models.SLAPolicy
+// @Router /admin/sla-policies/{id} [put]
+func UpdateSLAPolicy(c *gin.Context) {
+	policyID := c.Param("id")
+	input, ok := bindSLAPolicyInput(c)
+	if !ok {
+		return
+	}
+
+	var policy models.SLAPolicy
+	if err := database.DB.First(&policy, policyID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SLA policy not found"})
+		return
+	}
+
+	policy.DepartmentID = input.DepartmentID
+	policy.Category = input.Category
+	policy.FirstReplyDays = input.FirstReplyDays
+	policy.ResolutionDays = input.ResolutionDays
+	if err := database.DB.Save(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update SLA policy"})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// DeleteSLAPolicy godoc
+// @Summary Delete an SLA policy
+// @Description Remove an SLA policy.
+// @Tags admin-sla
+// @Security ApiKeyAuth
+// @Param id path int true "SLA Policy ID"
+// @Success 204
+// @Router /admin/sla-policies/{id} [delete]
+func DeleteSLAPolicy(c *gin.Context) {
+	if err := database.DB.Delete(&models.SLAPolicy{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete SLA policy"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+type HolidayInput struct {
+	Date      string `json:"date" binding:"required"` // "2006-01-02"
+	Name      string `json:"name"`
+	IsWorkday bool   `json:"is_workday"`
+}
+
+// GetHolidays godoc
+// @Summary Get the holiday calendar
+// @Description Get all holidays and make-up working days used for SLA calculation.
+// @Tags admin-sla
+// @Security ApiKeyAuth
+// @Produce  json
+// @Success 200 {array} models.Holiday
+// @Router /admin/holidays [get]
+func GetHolidays(c *gin.Context) {
+	var holidays []models.Holiday
+	if err := database.DB.Order("date ASC").Find(&holidays).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve holidays"})
+		return
+	}
+	c.JSON(http.StatusOK, holidays)
+}
+
+// CreateHoliday godoc
+// @Summary Add a calendar day
+// @Description Mark a day as a holiday, or as a working day when is_workday is true.
+// @Tags admin-sla
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param holiday body HolidayInput true "Holiday"
+// @Success 200 {object} models.Holiday
+// @Router /admin/holidays [post]
+func CreateHoliday(c *gin.Context) {
+	var input HolidayInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := time.Parse("2006-01-02", input.Date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Date must be in YYYY-MM-DD format"})
+		return
+	}
+
+	holiday := models.Holiday{Date: input.Date, Name: input.Name, IsWorkday: input.IsWorkday}
+	if err := database.DB.Create(&holiday).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create holiday"})
+		return
+	}
+	c.JSON(http.StatusOK, holiday)
+}
+
+// DeleteHoliday godoc
+// @Summary Delete a calendar day
+// @Description Remove a day from the holiday calendar.
+// @Tags admin-sla
+// @Security ApiKeyAuth
+// @Param id path int true "Holiday ID"
+// @Success 204
+// @Router /admin/holidays/{id} [delete]
+func DeleteHoliday(c *gin.Context) {
+	if err := database.DB.Delete(&models.Holiday{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete holiday"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/services"
+	"advice/sso"
+	"advice/utils"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ssoStateCookie keeps the signed OIDC state between the redirect to the provider and its callback
+const ssoStateCookie = "sso_state"
+
+// GetSSOProviders godoc
+// @Summary Get the SSO providers
+// @Description Tell which external identity providers admins can sign in with, next to the local password login.
+// @Tags admin
+// @Produce  json
+// @Success 200 {object} map[string]bool
+// @Router /admin/sso/providers [get]
+func GetSSOProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"oidc": sso.OIDC != nil, "cas": sso.CAS != nil})
+}
+
+// OIDCLogin godoc
+// @Summary Start an OIDC login
+// @Description Redirect the browser to the OpenID Connect provider. After signing in there, it comes back to the callback,
+// @Description which redirects to the frontend with the admin JWT in the URL fragment.
+// @Tags admin
+// @Success 302
+// @Router /admin/sso/oidc/login [get]
+func OIDCLogin(c *gin.Context) {
+	if sso.OIDC == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	state, nonce, verifier := sso.RandomString(), sso.RandomString(), sso.RandomString()
+	signed, err := utils.SignSSOState(state, nonce, verifier)
+	if err != nil {
+		redirectSSOError(c, "Failed to start SSO login")
+		return
+	}
+	authURL, err := sso.OIDC.AuthURL(state, nonce, verifier)
+	if err != nil {
+		log.Println("Failed to reach the OIDC provider:", err)
+		redirectSSOError(c, "The identity provider is unavailable")
+		return
+	}
+
+	setSSOStateCookie(c, signed, 600)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback godoc
+// @Summary Finish an OIDC login
+// @Description Redeem the authorization code, provision the admin on first login and redirect to the frontend with the JWT.
+// @Tags admin
+// @Param code query string true "Authorization code"
+// @Param state query string true "State sent with the authorization request"
+// @Success 302
+// @Router /admin/sso/oidc/callback [get]
+func OIDCCallback(c *gin.Context) {
+	if sso.OIDC == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	signed, _ := c.Cookie(ssoStateCookie)
+	setSSOStateCookie(c, "", -1)
+	if providerError := c.Query("error"); providerError != "" {
+		recordSSOAttempt(c, "oidc", "", false)
+		redirectSSOError(c, "The identity provider refused the login: "+providerError)
+		return
+	}
+
+	state, err := utils.ParseSSOState(signed)
+	if err != nil || subtle.ConstantTimeCompare([]byte(state.State), []byte(c.Query("state"))) != 1 {
+		recordSSOAttempt(c, "oidc", "", false)
+		redirectSSOError(c, "SSO login expired, please try again")
+		return
+	}
+
+	identity, err := sso.OIDC.Exchange(c.Query("code"), state.Verifier, state.Nonce)
+	if err != nil {
+		log.Println("OIDC login failed:", err)
+		recordSSOAttempt(c, "oidc", "", false)
+		redirectSSOError(c, "Failed to verify the identity provider's response")
+		return
+	}
+	finishSSOLogin(c, identity)
+}
+
+// CASLogin godoc
+// @Summary Start a CAS login
+// @Description Redirect the browser to the CAS login page, which comes back to the callback with a service ticket.
+// @Tags admin
+// @Success 302
+// @Router /admin/sso/cas/login [get]
+func CASLogin(c *gin.Context) {
+	if sso.CAS == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "CAS login is not configured"})
+		return
+	}
+	c.Redirect(http.StatusFound, sso.CAS.LoginURL())
+}
+
+// CASCallback godoc
+// @Summary Finish a CAS login
+// @Description Validate the service ticket, provision the admin on first login and redirect to the frontend with the JWT.
+// @Tags admin
+// @Param ticket query string true "Service ticket"
+// @Success 302
+// @Router /admin/sso/cas/callback [get]
+func CASCallback(c *gin.Context) {
+	if sso.CAS == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "CAS login is not configured"})
+		return
+	}
+
+	ticket := c.Query("ticket")
+	if ticket == "" {
+		recordSSOAttempt(c, "cas", "", false)
+		redirectSSOError(c, "Service ticket is required")
+		return
+	}
+	identity, err := sso.CAS.Validate(ticket)
+	if err != nil {
+		log.Println("CAS login failed:", err)
+		recordSSOAttempt(c, "cas", "", false)
+		redirectSSOError(c, "Failed to verify the service ticket")
+		return
+	}
+	finishSSOLogin(c, identity)
+}
+
+type LinkIdentityInput struct {
+	Provider string `json:"provider" binding:"required"` // "oidc" or "cas"
+	Subject  string `json:"subject" binding:"required"`  // OIDC sub claim or CAS login name
+}
+
+// LinkAdminIdentity godoc
+// @Summary Link an SSO account to an admin
+// @Description Let an external account sign in as an existing admin, such as a super admin, which is never linked automatically.
+// @Description The subject of a refused login is logged by the server.
+// @Tags admin-users
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Admin ID"
+// @Param identity body LinkIdentityInput true "External account"
+// @Success 200 {object} models.AdminIdentity
+// @Router /admin/users/{id}/identities [post]
+func LinkAdminIdentity(c *gin.Context) {
+	var input LinkIdentityInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Provider != "oidc" && input.Provider != "cas" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provider must be oidc or cas"})
+		return
+	}
+
+	var admin models.AdminUser
+	if err := database.DB.First(&admin, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Admin user not found"})
+		return
+	}
+	var count int64
+	database.DB.Model(&models.AdminIdentity{}).Where("provider = ? AND subject = ?", input.Provider, input.Subject).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "The SSO account is already linked to an admin"})
+		return
+	}
+
+	link := models.AdminIdentity{AdminID: admin.ID, Provider: input.Provider, Subject: input.Subject}
+	if err := database.DB.Create(&link).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link SSO account"})
+		return
+	}
+	c.JSON(http.StatusOK, link)
+}
+
+// UnlinkAdminIdentity godoc
+// @Summary Unlink an SSO account from an admin
+// @Description Stop an external account from signing in as the admin.
+// @Tags admin-users
+// @Security ApiKeyAuth
+// @Param id path int true "Admin ID"
+// @Param identity_id path int true "Linked account ID"
+// @Success 204
+// @Router /admin/users/{id}/identities/{identity_id} [delete]
+func UnlinkAdminIdentity(c *gin.Context) {
+	result := database.DB.Where("id = ? AND admin_id = ?", c.Param("identity_id"), c.Param("id")).Delete(&models.AdminIdentity{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink SSO account"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Linked SSO account not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// finishSSOLogin signs in the admin of an external identity and hands the JWT to the frontend
+func finishSSOLogin(c *gin.Context, identity *sso.Identity) {
+	admin, err := services.ProvisionSSOAdmin(identity)
+	if err != nil {
+		username := identity.Username
+		if username == "" {
+			username = identity.Subject
+		}
+		recordSSOAttempt(c, identity.Provider, username, false)
+		switch {
+		case errors.Is(err, services.ErrSSONoRole), errors.Is(err, services.ErrSSONoDepartment):
+			redirectSSOError(c, "Your account is not allowed to access the admin console")
+		case errors.Is(err, services.ErrSSOUsernameTaken):
+			log.Printf("SSO account %s of %s has the username of a local admin, a super admin can link it to that admin", identity.Subject, identity.Provider)
+			redirectSSOError(c, "An admin with the same username already exists, ask a super admin to resolve it")
+		default:
+			log.Println("Failed to provision SSO admin:", err)
+			redirectSSOError(c, "Failed to sign in")
+		}
+		return
+	}
+
+	var deptID uint
+	if admin.DepartmentID != nil {
+		deptID = *admin.DepartmentID
+	}
+	token, err := utils.GenerateJWT(admin.ID, admin.Username, admin.Role, deptID, admin.CanViewAll)
+	if err != nil {
+		recordSSOAttempt(c, identity.Provider, admin.Username, false)
+		redirectSSOError(c, "Failed to generate token")
+		return
+	}
+
+	recordSSOAttempt(c, identity.Provider, admin.Username, true)
+	c.Redirect(http.StatusFound, sso.FrontendURL+"#"+url.Values{"token": {token}}.Encode())
+}
+
+func recordSSOAttempt(c *gin.Context, method, username string, success bool) {
+	attempt := models.LoginAttempt{Username: username, IP: c.ClientIP(), UserAgent: c.Request.UserAgent(), Method: method, Success: success}
+	if err := database.DB.Create(&attempt).Error; err != nil {
+		log.Println("Failed to record login attempt:", err)
+	}
+}
+
+func redirectSSOError(c *gin.Context, message string) {
+	c.Redirect(http.StatusFound, sso.FrontendURL+"#"+url.Values{"error": {message}}.Encode())
+}
+
+func setSSOStateCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoStateCookie, value, maxAge, "/api/v1/admin/sso", "", c.Request.TLS != nil, true)
+}
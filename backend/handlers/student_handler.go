@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/utils"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// minStudentPasswordLength applies to passwords chosen by students and given in rosters
+const minStudentPasswordLength = 8
+
+type StudentLoginInput struct {
+	StudentNumber string `json:"student_number" binding:"required"`
+	Password      string `json:"password" binding:"required"`
+}
+
+type ChangeStudentPasswordInput struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+type UpdateStudentInput struct {
+	Name     string `json:"name"`
+	Class    string `json:"class"`
+	Password string `json:"password"` // Reset the password when set
+	IsActive *bool  `json:"is_active"`
+}
+
+// RosterImportError reports a roster line that was not imported
+type RosterImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// GeneratedPassword is the initial password of an imported student, to hand out to the student
+type GeneratedPassword struct {
+	StudentNumber string `json:"student_number"`
+	Name          string `json:"name"`
+	Password      string `json:"password"`
+}
+
+// rosterColumns maps the accepted roster headers to fields
+var rosterColumns = map[string]string{
+	"student_number": "student_number", "学号": "student_number",
+	"name": "name", "姓名": "name",
+	"class": "class", "班级": "class",
+	"password": "password", "密码": "password",
+}
+
+// currentStudent loads the account of the logged-in student, if any.
+// It responds with an error and returns false when the account can no longer be used.
+func currentStudent(c *gin.Context) (*models.Student, bool) {
+	claims, exists := c.Get("student_claims")
+	if !exists {
+		return nil, true
+	}
+	var student models.Student
+	if err := database.DB.First(&student, claims.(*utils.Claims).UserID).Error; err != nil || !student.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "学生账号不可用，请重新登录"})
+		return nil, false
+	}
+	return &student, true
+}
+
+// StudentLogin godoc
+// @Summary Student login
+// @Description Authenticate a student account imported from the roster and return a JWT, used to submit verified suggestions.
+// @Tags students
+// @Accept  json
+// @Produce  json
+// @Param credentials body StudentLoginInput true "Login Credentials"
+// @Success 200 {object} map[string]interface{}
+// @Router /student/login [post]
+func StudentLogin(c *gin.Context) {
+	var input StudentLoginInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	studentNumber := strings.TrimSpace(input.StudentNumber)
+	attempt := models.LoginAttempt{Username: studentNumber, IP: c.ClientIP(), UserAgent: c.Request.UserAgent(), Method: "student"}
+	defer func() {
+		if err := database.DB.Create(&attempt).Error; err != nil {
+			log.Println("Failed to record login attempt:", err)
+		}
+	}()
+
+	var student models.Student
+	if err := database.DB.Where("student_number = ?", studentNumber).First(&student).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+	if !utils.CheckPasswordHash(input.Password, student.PasswordHash) || !student.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	token, err := utils.GenerateJWT(student.ID, student.StudentNumber, "student", 0, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	attempt.Success = true
+	c.JSON(http.StatusOK, gin.H{"token": token, "student": student})
+}
+
+// GetStudentProfile godoc
+// @Summary Get the logged-in student
+// @Description Get the roster entry of the logged-in student, as shown to admins when the student shares their identity.
+// @Tags students
+// @Security ApiKeyAuth
+// @Produce  json
+// @Success 200 {object} models.Student
+// @Router /student/me [get]
+func GetStudentProfile(c *gin.Context) {
+	student, ok := currentStudent(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, student)
+}
+
+// ChangeStudentPassword godoc
+// @Summary Change the student's password
+// @Description Replace the initial password of the logged-in student.
+// @Tags students
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param passwords body ChangeStudentPasswordInput true "Old and new password"
+// @Success 200 {object} map[string]string
+// @Router /student/password [put]
+func ChangeStudentPassword(c *gin.Context) {
+	var input ChangeStudentPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.NewPassword) < minStudentPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("密码至少需要%d个字符", minStudentPasswordLength)})
+		return
+	}
+
+	student, ok := currentStudent(c)
+	if !ok {
+		return
+	}
+	if !utils.CheckPasswordHash(input.OldPassword, student.PasswordHash) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "原密码不正确"})
+		return
+	}
+
+	hash, err := utils.HashStudentPassword(input.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	if err := database.DB.Model(student).Update("password_hash", hash).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated"})
+}
+
+// GetStudents godoc
+// @Summary Get student accounts
+// @Description Get the imported student accounts, optionally filtered by student number, name or class.
+// @Tags admin-students
+// @Security ApiKeyAuth
+// @Produce  json
+// @Param keyword query string false "Student number, name or class contains"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/students [get]
+func GetStudents(c *gin.Context) {
+	page, pageSize := paginate(c)
+
+	query := database.DB.Model(&models.Student{})
+	if keyword := c.Query("keyword"); keyword != "" {
+		pattern := likePattern(keyword)
+		query = query.Where(`student_number LIKE ? ESCAPE '\' OR name LIKE ? ESCAPE '\' OR class LIKE ? ESCAPE '\'`, pattern, pattern, pattern)
+	}
+
+	var total int64
+	students := []models.Student{}
+	query.Count(&total)
+	if err := query.Order("student_number ASC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&students).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve students"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"data":      students,
+	})
+}
+
+// ImportStudents godoc
+// @Summary Import the student roster
+// @Description Create or update student accounts from a CSV roster with the columns student_number (学号), name (姓名), class (班级)
+// @Description and optionally password (密码). New students without a password get a generated one, returned once in the response.
+// @Description Existing students keep their password unless the roster sets one.
+// @Tags admin-students
+// @Security ApiKeyAuth
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param file formData file true "CSV roster, UTF-8 with a header row"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/students/import [post]
+func ImportStudents(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Roster file is required"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read roster file"})
+		return
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	headerRow, err := reader.Read()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Roster file is empty or not valid CSV"})
+		return
+	}
+	columns := map[string]int{}
+	for i, name := range headerRow {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "\ufeff") // Spreadsheet exports may start with a BOM
+		if field, ok := rosterColumns[strings.ToLower(name)]; ok {
+			columns[field] = i
+		}
+	}
+	if _, ok := columns["student_number"]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Roster must have a student_number (学号) column"})
+		return
+	}
+	if _, ok := columns["name"]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Roster must have a name (姓名) column"})
+		return
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	created, updated := 0, 0
+	generated := []GeneratedPassword{}
+	failures := []RosterImportError{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			failures = append(failures, RosterImportError{Line: line, Error: err.Error()})
+			continue
+		}
+
+		number, name, password := field(record, "student_number"), field(record, "name"), field(record, "password")
+		if number == "" || name == "" {
+			failures = append(failures, RosterImportError{Line: line, Error: "student_number and name are required"})
+			continue
+		}
+		if password != "" && len(password) < minStudentPasswordLength {
+			failures = append(failures, RosterImportError{Line: line, Error: fmt.Sprintf("password must have at least %d characters", minStudentPasswordLength)})
+			continue
+		}
+
+		var student models.Student
+		err = database.DB.Where("student_number = ?", number).First(&student).Error
+		isNew := errors.Is(err, gorm.ErrRecordNotFound)
+		if err != nil && !isNew {
+			failures = append(failures, RosterImportError{Line: line, Error: "failed to load student"})
+			continue
+		}
+		if isNew {
+			student = models.Student{StudentNumber: number, IsActive: true}
+			if password == "" {
+				password = utils.GeneratePassword()
+				generated = append(generated, GeneratedPassword{StudentNumber: number, Name: name, Password: password})
+			}
+		}
+		student.Name = name
+		student.Class = field(record, "class")
+		if password != "" {
+			if student.PasswordHash, err = utils.HashStudentPassword(password); err != nil {
+				failures = append(failures, RosterImportError{Line: line, Error: "failed to hash password"})
+				continue
+			}
+		}
+		if err := database.DB.Save(&student).Error; err != nil {
+			failures = append(failures, RosterImportError{Line: line, Error: "failed to save student"})
+			continue
+		}
+		if isNew {
+			created++
+		} else {
+			updated++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"created":             created,
+		"updated":             updated,
+		"generated_passwords": generated,
+		"errors":              failures,
+	})
+}
+
+// UpdateStudent godoc
+// @Summary Update a student account
+// @Description Correct the name or class of a student, reset their password, or deactivate the account.
+// @Tags admin-students
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Student ID"
+// @Param student body UpdateStudentInput true "Student"
+// @Success 200 {object} models.Student
+// @Router /admin/students/{id} [put]
+func UpdateStudent(c *gin.Context) {
+	var input UpdateStudentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Password != "" && len(input.Password) < minStudentPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Password must have at least %d characters", minStudentPasswordLength)})
+		return
+	}
+
+	var student models.Student
+	if err := database.DB.First(&student, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return
+	}
+
+	if input.Name != "" {
+		student.Name = input.Name
+	}
+	if input.Class != "" {
+		student.Class = input.Class
+	}
+	if input.IsActive != nil {
+		student.IsActive = *input.IsActive
+	}
+	if input.Password != "" {
+		hash, err := utils.HashStudentPassword(input.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
+		student.PasswordHash = hash
+	}
+	if err := database.DB.Save(&student).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update student"})
+		return
+	}
+	c.JSON(http.StatusOK, student)
+}
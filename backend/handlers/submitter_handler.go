@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SubmitterSuggestion struct {
+	TrackingCode     string    `json:"tracking_code"`
+	Title            string    `json:"title"`
+	Status           string    `json:"status"`
+	DepartmentName   string    `json:"department_name"`
+	ReplyCount       int       `json:"reply_count"`
+	UnreadReplyCount int       `json:"unread_reply_count"` // Replies posted since the submitter last looked up the suggestion
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// GetSubmitterSuggestions godoc
+// @Summary Get the suggestions of a submitter key
+// @Description List the suggestions submitted with a submitter key, newest first, with their status and reply counts.
+// @Description Replies count as read once the suggestion is looked up by its tracking code. Replies to the canonical suggestion of a merged one are included.
+// @Tags suggestions
+// @Produce  json
+// @Param X-Submitter-Key header string true "Submitter key returned on submission"
+// @Success 200 {array} SubmitterSuggestion
+// @Router /submitter/suggestions [get]
+func GetSubmitterSuggestions(c *gin.Context) {
+	key := c.GetHeader("X-Submitter-Key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Submitter key is required"})
+		return
+	}
+
+	var suggestions []models.Suggestion
+	if err := database.DB.Preload("Department").Where("submitter_key_hash = ?", utils.HashSubmitterKey(key)).
+		Order("created_at DESC").Find(&suggestions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve suggestions"})
+		return
+	}
+	if len(suggestions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "提交者密钥无效"})
+		return
+	}
+
+	ids := make([]uint, 0, len(suggestions))
+	for _, s := range suggestions {
+		ids = append(ids, s.ID)
+		if s.CanonicalID != nil {
+			ids = append(ids, *s.CanonicalID)
+		}
+	}
+	var replies []models.Reply
+	if err := database.DB.Select("id", "suggestion_id", "created_at").Where("suggestion_id IN ?", ids).Find(&replies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve replies"})
+		return
+	}
+	replyTimes := map[uint][]time.Time{}
+	for _, r := range replies {
+		replyTimes[r.SuggestionID] = append(replyTimes[r.SuggestionID], r.CreatedAt)
+	}
+
+	results := make([]SubmitterSuggestion, 0, len(suggestions))
+	for _, s := range suggestions {
+		times := replyTimes[s.ID]
+		if s.CanonicalID != nil {
+			times = append(times, replyTimes[*s.CanonicalID]...)
+		}
+		unread := 0
+		for _, t := range times {
+			if s.SubmitterSeenAt == nil || t.After(*s.SubmitterSeenAt) {
+				unread++
+			}
+		}
+		results = append(results, SubmitterSuggestion{
+			TrackingCode:     s.TrackingCode,
+			Title:            s.Title,
+			Status:           s.Status,
+			DepartmentName:   s.Department.Name,
+			ReplyCount:       len(times),
+			UnreadReplyCount: unread,
+			CreatedAt:        s.CreatedAt,
+			UpdatedAt:        s.UpdatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, results)
+}
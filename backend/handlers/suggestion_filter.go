@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"advice/services"
+	"advice/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// suggestionSortColumns maps the sort query parameter to the expression suggestions are ordered by
+var suggestionSortColumns = map[string]string{
+	"created":  "created_at",
+	"updated":  "updated_at",
+	"upvotes":  "upvotes",
+	"priority": services.PriorityRankSQL,
+}
+
+// splitQueryValues splits a comma separated query parameter, dropping empty values
+func splitQueryValues(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// parseBoolQuery parses an optional true/false query parameter
+func parseBoolQuery(c *gin.Context, key string) (value bool, set bool, err error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return false, false, nil
+	}
+	value, err = strconv.ParseBool(raw)
+	if err != nil {
+		return false, false, fmt.Errorf("%s must be true or false", key)
+	}
+	return value, true, nil
+}
+
+// parseDateQuery parses an optional YYYY-MM-DD query parameter in local time
+func parseDateQuery(c *gin.Context, key string) (*time.Time, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be in YYYY-MM-DD format", key)
+	}
+	return &date, nil
+}
+
+// applySuggestionFilters applies the filter and sort query parameters of the admin suggestion list.
+//
+// All filters are optional and combined with AND:
+//
+//	status=待处理,处理中          one of the statuses; 已审核 matches every status except 待审核
+//	department_id=2             department (only for super admins and admins with CanViewAll)
+//	category=食堂,宿舍            one of the categories
+//	priority=high,urgent        one of the priorities
+//	assignee=me|unassigned|3    assigned to the current admin, to nobody, or to the given admin
+//	overdue=true                open and past an SLA deadline
+//	moderation=flag|mask|pii    flagged for review, with masked words, or containing personal information
+//	keyword=热水                  title or content contains the keyword
+//	is_public=true|false        public or private
+//	verified=true|false         submitted by a logged-in student or anonymously
+//	has_replies=true|false      with or without admin replies
+//	upvotes_min=5&upvotes_max=9 upvote range, both bounds inclusive
+//	submitter_class=高一(3)班      exact submitter class
+//	created_from=2025-09-01     created on or after the date
+//	created_to=2025-09-30       created on or before the date
+//
+// Results are sorted by sort=created|updated|upvotes|priority (default created) and
+// order=desc|asc (default desc), newest first as a tiebreaker.
+func applySuggestionFilters(c *gin.Context, query *gorm.DB, adminClaims *utils.Claims) (*gorm.DB, error) {
+	if statuses := splitQueryValues(c.Query("status")); len(statuses) > 0 {
+		if len(statuses) == 1 && statuses[0] == "已审核" {
+			query = query.Where("status <> ?", "待审核")
+		} else {
+			query = query.Where("status IN ?", statuses)
+		}
+	}
+	if departmentID := c.Query("department_id"); departmentID != "" {
+		// Super admins or admins with CanViewAll can filter by any department
+		if adminClaims.Role == "super_admin" || adminClaims.CanViewAll {
+			query = query.Where("department_id = ?", departmentID)
+		}
+	}
+	if categories := splitQueryValues(c.Query("category")); len(categories) > 0 {
+		query = query.Where("category IN ?", categories)
+	}
+	if priorities := splitQueryValues(c.Query("priority")); len(priorities) > 0 {
+		for _, p := range priorities {
+			if !services.IsValidPriority(p) {
+				return nil, errors.New("Invalid priority")
+			}
+		}
+		query = query.Where("priority IN ?", priorities)
+	}
+
+	switch assignee := c.Query("assignee"); assignee {
+	case "":
+	case "me":
+		query = query.Where("assignee_id = ?", adminClaims.UserID)
+	case "unassigned":
+		query = query.Where("assignee_id IS NULL")
+	default:
+		assigneeID, err := strconv.Atoi(assignee)
+		if err != nil {
+			return nil, errors.New("Invalid assignee filter")
+		}
+		query = query.Where("assignee_id = ?", assigneeID)
+	}
+	if c.Query("overdue") == "true" {
+		query = services.WhereOverdue(query, time.Now())
+	}
+
+	switch moderation := c.Query("moderation"); moderation {
+	case "":
+	case services.ModerationFlag, services.ModerationMask:
+		query = query.Where("moderation_action = ?", moderation)
+	case "pii":
+		query = query.Where("moderation_pii_types <> ''")
+	default:
+		return nil, errors.New("Invalid moderation filter")
+	}
+
+	if keyword := strings.TrimSpace(c.Query("keyword")); keyword != "" {
+		pattern := likePattern(keyword)
+		query = query.Where(`title LIKE ? ESCAPE '\' OR content LIKE ? ESCAPE '\'`, pattern, pattern)
+	}
+	if isPublic, set, err := parseBoolQuery(c, "is_public"); err != nil {
+		return nil, err
+	} else if set {
+		query = query.Where("is_public = ?", isPublic)
+	}
+	if verified, set, err := parseBoolQuery(c, "verified"); err != nil {
+		return nil, err
+	} else if set {
+		query = query.Where("is_verified = ?", verified)
+	}
+	if hasReplies, set, err := parseBoolQuery(c, "has_replies"); err != nil {
+		return nil, err
+	} else if set {
+		exists := "EXISTS (SELECT 1 FROM replies WHERE replies.suggestion_id = suggestions.id)"
+		if !hasReplies {
+			exists = "NOT " + exists
+		}
+		query = query.Where(exists)
+	}
+	for key, op := range map[string]string{"upvotes_min": ">=", "upvotes_max": "<="} {
+		if raw := c.Query(key); raw != "" {
+			upvotes, err := strconv.Atoi(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a number", key)
+			}
+			query = query.Where("upvotes "+op+" ?", upvotes)
+		}
+	}
+	if submitterClass := c.Query("submitter_class"); submitterClass != "" {
+		query = query.Where("submitter_class = ?", submitterClass)
+	}
+
+	createdFrom, err := parseDateQuery(c, "created_from")
+	if err != nil {
+		return nil, err
+	}
+	if createdFrom != nil {
+		query = query.Where("created_at >= ?", *createdFrom)
+	}
+	createdTo, err := parseDateQuery(c, "created_to")
+	if err != nil {
+		return nil, err
+	}
+	if createdTo != nil {
+		query = query.Where("created_at < ?", createdTo.AddDate(0, 0, 1))
+	}
+
+	// Sorting
+	sortColumn, ok := suggestionSortColumns[c.DefaultQuery("sort", "created")]
+	if !ok {
+		return nil, errors.New("Invalid sort field")
+	}
+	direction := strings.ToUpper(c.DefaultQuery("order", "desc"))
+	if direction != "ASC" && direction != "DESC" {
+		return nil, errors.New("Invalid sort order")
+	}
+	query = query.Order(sortColumn + " " + direction)
+	if sortColumn != "created_at" {
+		query = query.Order("created_at DESC")
+	}
+
+	return query, nil
+}
+
+// likeEscaper escapes the LIKE wildcards of a keyword, for use with ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// likePattern returns a LIKE pattern matching the keyword literally anywhere in the value
+func likePattern(keyword string) string {
+	return "%" + likeEscaper.Replace(keyword) + "%"
+}
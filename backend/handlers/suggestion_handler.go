@@ -3,11 +3,15 @@ package handlers
 import (
 	"advice/database"
 	"advice/models"
+	"advice/services"
 	"advice/utils"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type SuggestionInput struct {
@@ -17,7 +21,20 @@ type SuggestionInput struct {
 	DepartmentID   uint   `json:"department_id"`
 	SubmitterName  string `json:"submitter_name"`
 	SubmitterClass string `json:"submitter_class"`
-	IsPublic       bool   `json:"is_public"`
+	// For logged-in students: share the name, class and student number of the roster with admins.
+	// Otherwise the suggestion is verified but anonymous, and the submitter name and class are ignored.
+	ShowIdentity  bool `json:"show_identity"`
+	IsPublic      bool `json:"is_public"`
+	IsSafetyIssue bool `json:"is_safety_issue"`
+	// Optional address for status and reply notifications, stored encrypted and never shown
+	ContactEmail string `json:"contact_email"`
+	Language     string `json:"language"` // Of the notifications: "zh" (default), "en"
+	// Key returned by an earlier submission, to list this suggestion along with the earlier ones.
+	// A new key is returned when empty.
+	SubmitterKey string `json:"submitter_key"`
+	// Solution of a challenge from GET /challenge, unless challenges are turned off
+	ChallengeToken    string `json:"challenge_token"`
+	ChallengeSolution string `json:"challenge_solution"`
 }
 
 // SubmitSuggestion godoc
@@ -27,7 +44,8 @@ type SuggestionInput struct {
 // @Accept  json
 // @Produce  json
 // @Param suggestion body SuggestionInput true "Suggestion Submission"
-// @Success 200 {object} map[string]string
+// @Param Authorization header string false "Bearer token of a logged-in student, for a verified suggestion"
+// @Success 200 {object} map[string]string "tracking_code and submitter_key"
 // @Router /suggestions [post]
 func SubmitSuggestion(c *gin.Context) {
 	var input SuggestionInput
@@ -44,61 +62,271 @@ func SubmitSuggestion(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "建议标题不能超过100个字符"})
 		return
 	}
+	if input.ContactEmail != "" {
+		email, err := services.NormalizeContactEmail(input.ContactEmail)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "联系邮箱格式不正确"})
+			return
+		}
+		input.ContactEmail = email
+	}
+	if input.Language == "" {
+		input.Language = "zh"
+	}
+	if !services.IsValidContactLanguage(input.Language) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid language"})
+		return
+	}
 
-	var suggestion models.Suggestion
-	if input.DepartmentID == 0 {
-		// 0 represents all departments
-		suggestion.DepartmentID = nil
+	submitterKey := input.SubmitterKey
+	if submitterKey == "" {
+		submitterKey = utils.NewSubmitterKey()
 	} else {
-		// Validate DepartmentID exists
-		var department models.Department
-		if err := database.DB.First(&department, input.DepartmentID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		var bound int64
+		database.DB.Model(&models.Suggestion{}).Where("submitter_key_hash = ?", utils.HashSubmitterKey(submitterKey)).Count(&bound)
+		if bound == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "提交者密钥无效"})
 			return
 		}
-		suggestion.DepartmentID = &input.DepartmentID
 	}
 
+	// Logged-in students cannot claim someone else's name, so their suggestions are verified
+	student, ok := currentStudent(c)
+	if !ok {
+		return
+	}
+	studentNumber := ""
+	if student != nil && input.ShowIdentity {
+		input.SubmitterName, input.SubmitterClass, studentNumber = student.Name, student.Class, student.StudentNumber
+	} else if student != nil {
+		input.SubmitterName, input.SubmitterClass = "", ""
+	}
+
+	check := services.Moderate(&input.Title, &input.Content, &input.SubmitterName, &input.SubmitterClass)
+	if check.Blocked {
+		moderationBlockedResponse(c)
+		return
+	}
+
+	var suggestion models.Suggestion
 	suggestion.Title = input.Title
 	suggestion.Content = input.Content
-	suggestion.Category = input.Category
 	suggestion.SubmitterName = input.SubmitterName
 	suggestion.SubmitterClass = input.SubmitterClass
+	suggestion.StudentNumber = studentNumber
+	suggestion.IsVerified = student != nil
+	suggestion.SubmitterKeyHash = utils.HashSubmitterKey(submitterKey)
 	suggestion.Status = "待审核"
 	suggestion.TrackingCode = utils.GenerateTrackingCode(6)
 	suggestion.IsPublic = input.IsPublic
+	suggestion.Priority = "normal"
+	suggestion.Moderation = check.Result
+	if input.IsSafetyIssue {
+		// Safety issues are handled before anything else
+		suggestion.IsSafetyIssue = true
+		suggestion.Priority = "urgent"
+	}
+	if !setCategoryAndDepartment(c, &suggestion, input.Category, input.DepartmentID) {
+		return
+	}
+	// The challenge can only be used once, so it is checked after everything the student may have to correct
+	if !verifyChallenge(c, input.ChallengeToken, input.ChallengeSolution) {
+		return
+	}
+	suggestion.CreatedAt = time.Now()
+	services.ApplySLA(&suggestion)
 
-	if err := database.DB.Create(&suggestion).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&suggestion).Error; err != nil {
+			return err
+		}
+		if input.ContactEmail != "" {
+			return services.SaveContact(tx, suggestion.ID, input.ContactEmail, input.Language)
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create suggestion"})
 		return
 	}
+	services.IndexSuggestion(suggestion.ID)
+	services.RecordDuplicateCandidates(&suggestion)
 
-	c.JSON(http.StatusOK, gin.H{"tracking_code": suggestion.TrackingCode})
+	if suggestion.IsSafetyIssue {
+		services.NotifySuperAdmins(services.NotificationNewSuggestion, suggestion.ID, "安全隐患建议",
+			fmt.Sprintf("收到标记为安全隐患的建议 #%d「%s」，请立即处理", suggestion.ID, suggestion.Title))
+	}
+	services.NotifyResponsibleAdmins(&suggestion, services.NotificationNewSuggestion, "本部门收到新建议",
+		fmt.Sprintf("建议 #%d「%s」已提交至本部门，等待审核", suggestion.ID, suggestion.Title))
+	services.PublishSuggestionEvent(services.EventSuggestionCreated, &suggestion)
+
+	c.JSON(http.StatusOK, gin.H{"tracking_code": suggestion.TrackingCode, "submitter_key": submitterKey})
+}
+
+// setCategoryAndDepartment validates the category and department chosen by the student and sets them on the suggestion,
+// responding with an error when one is invalid.
+// Suggestions for all departments are routed by the routing rules, then by the category's default department.
+func setCategoryAndDepartment(c *gin.Context, suggestion *models.Suggestion, categoryName string, departmentID uint) bool {
+	var category models.Category
+	if categoryName != "" {
+		if err := database.DB.Where("name = ? AND is_active = ?", categoryName, true).First(&category).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category"})
+			return false
+		}
+	}
+
+	if departmentID == 0 {
+		// 0 represents all departments
+		suggestion.DepartmentID = nil
+	} else {
+		// Validate DepartmentID exists
+		var department models.Department
+		if err := database.DB.First(&department, departmentID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+			return false
+		}
+		suggestion.DepartmentID = &departmentID
+	}
+
+	suggestion.Category = categoryName
+	suggestion.RoutedByRuleID = nil
+	if suggestion.DepartmentID == nil && !services.RouteSuggestion(suggestion) {
+		suggestion.DepartmentID = category.DefaultDepartmentID
+	}
+	return true
+}
+
+type SimilarSuggestionInput struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+type SimilarSuggestion struct {
+	ID             uint    `json:"id"`
+	Title          string  `json:"title"`
+	Status         string  `json:"status"`
+	DepartmentName string  `json:"department_name"`
+	Upvotes        int     `json:"upvotes"`
+	Score          float64 `json:"score"`
+}
+
+// FindSimilarSuggestions godoc
+// @Summary Find similar public suggestions
+// @Description Before submitting, find public, approved suggestions similar to a draft that the student could upvote instead.
+// @Tags suggestions
+// @Accept  json
+// @Produce  json
+// @Param draft body SimilarSuggestionInput true "Draft Suggestion"
+// @Success 200 {array} SimilarSuggestion
+// @Router /suggestions/similar [post]
+func FindSimilarSuggestions(c *gin.Context) {
+	var input SimilarSuggestionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Content) > 3000 || len(input.Title) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "建议标题或内容过长"})
+		return
+	}
+
+	hits := services.FindPublicSimilar(input.Title, input.Content)
+	results := []SimilarSuggestion{}
+	if len(hits) == 0 {
+		c.JSON(http.StatusOK, results)
+		return
+	}
+
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.SuggestionID
+	}
+	var suggestions []models.Suggestion
+	if err := database.DB.Preload("Department").Where("id IN ?", ids).Find(&suggestions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve suggestions"})
+		return
+	}
+	byID := make(map[uint]models.Suggestion, len(suggestions))
+	for _, s := range suggestions {
+		byID[s.ID] = s
+	}
+
+	for _, hit := range hits {
+		s, ok := byID[hit.SuggestionID]
+		if !ok {
+			continue
+		}
+		results = append(results, SimilarSuggestion{
+			ID:             s.ID,
+			Title:          services.MaskPII(s.Title),
+			Status:         s.Status,
+			DepartmentName: s.Department.Name,
+			Upvotes:        s.Upvotes,
+			Score:          hit.Score,
+		})
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// CanonicalProgress is the progress of the canonical suggestion shared with the submitter of a merged duplicate
+type CanonicalProgress struct {
+	Status         string
+	DepartmentName string
+	Replies        []models.Reply
+}
+
+// TrackedSuggestion is a suggestion as seen by the holder of its tracking code
+type TrackedSuggestion struct {
+	models.Suggestion
+	Canonical *CanonicalProgress `json:",omitempty"` // Set when the suggestion was merged as a duplicate
 }
 
 // GetSuggestionByTrackingCode godoc
 // @Summary Get suggestion by tracking code
-// @Description Get details of a suggestion using its tracking code.
+// @Description Get details of a suggestion using its tracking code. A suggestion merged as a duplicate includes the status, department and replies of its canonical suggestion.
 // @Tags suggestions
 // @Produce  json
 // @Param   tracking_code     path    string     true        "Suggestion Tracking Code"
-// @Success 200 {object} models.Suggestion
+// @Success 200 {object} TrackedSuggestion
 // @Router /suggestions/{tracking_code} [get]
 func GetSuggestionByTrackingCode(c *gin.Context) {
 	trackingCode := c.Param("tracking_code")
 
 	var suggestion models.Suggestion
-	if err := database.DB.Preload("Department").Preload("Replies").Preload("Replies.Replier").Where("tracking_code = ?", trackingCode).First(&suggestion).Error; err != nil {
+	if err := database.DB.Preload("Department").Preload("Replies").Preload("Replies.Replier").
+		Preload("Attachments", "reply_id IS NULL").Preload("Replies.Attachments").Preload("Ratings").
+		Where("tracking_code = ?", trackingCode).First(&suggestion).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestion not found"})
 		return
 	}
+	// Replies shown here are no longer unread in the submitter's suggestion list
+	database.DB.Model(&suggestion).UpdateColumn("submitter_seen_at", time.Now())
 
-	// Do not show replier's password hash
+	// Do not show replier's password hash, or the matched words of the moderation word lists
 	for i := range suggestion.Replies {
 		suggestion.Replies[i].Replier.PasswordHash = ""
 	}
+	suggestion.Moderation = models.ModerationResult{}
+	result := TrackedSuggestion{Suggestion: suggestion}
+
+	// Only the progress of the canonical suggestion is shared, nothing about its content or submitter
+	if suggestion.CanonicalID != nil {
+		var canonical models.Suggestion
+		if err := database.DB.Preload("Department").Preload("Replies").Preload("Replies.Replier").Preload("Replies.Attachments").
+			First(&canonical, *suggestion.CanonicalID).Error; err == nil {
+			for i := range canonical.Replies {
+				canonical.Replies[i].Replier.PasswordHash = ""
+			}
+			result.Canonical = &CanonicalProgress{
+				Status:         canonical.Status,
+				DepartmentName: canonical.Department.Name,
+				Replies:        canonical.Replies,
+			}
+		}
+	}
 
-	c.JSON(http.StatusOK, suggestion)
+	c.JSON(http.StatusOK, result)
 }
 
 // GetPublicSuggestions godoc
@@ -133,8 +361,11 @@ func GetPublicSuggestions(c *gin.Context) {
 		Preload("Department").
 		Preload("Replies").
 		Preload("Replies.Replier").
+		Preload("Attachments", "reply_id IS NULL AND is_approved = ?", true).
+		Preload("Replies.Attachments").
 		Where("is_public = ?", true).
-		Where("status NOT IN (?)", []string{"待审核", "审核不通过"}).
+		Where("status NOT IN (?)", []string{"待审核", "审核不通过", "已撤回"}).
+		Where("canonical_id IS NULL").
 		Order("created_at DESC")
 
 	// Filtering
@@ -151,45 +382,39 @@ func GetPublicSuggestions(c *gin.Context) {
 		return
 	}
 
-	// Do not show replier's password hash
+	// Do not show replier's password hash or personal information
 	for i := range suggestions {
+		maskPersonalInfo(&suggestions[i])
 		for j := range suggestions[i].Replies {
 			suggestions[i].Replies[j].Replier.PasswordHash = ""
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"total":     total,
-		"page":      page,
-		"page_size": pageSize,
-		"data":      suggestions,
-	})
-}
-
-// UpvoteSuggestion godoc
-// @Summary Upvote a suggestion
-// @Description Increment the upvote count for a suggestion.
-// @Tags suggestions
-// @Produce  json
-// @Param   id     path    int     true        "Suggestion ID"
-// @Success 200 {object} map[string]int
-// @Router /suggestions/{id}/upvote [post]
-func UpvoteSuggestion(c *gin.Context) {
-	suggestionID := c.Param("id")
-
-	var suggestion models.Suggestion
-	if err := database.DB.First(&suggestion, suggestionID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestion not found"})
-		return
-	}
+	// Let the page show which suggestions the current voter has already upvoted or reacted to
+	votedIDs := []uint{}
+	myReactions := map[uint]string{}
+	if id, ok := currentVoterID(c); ok && len(suggestions) > 0 {
+		ids := make([]uint, len(suggestions))
+		for i, s := range suggestions {
+			ids[i] = s.ID
+		}
+		database.DB.Model(&models.Vote{}).Where("voter_id = ? AND suggestion_id IN ?", id, ids).Pluck("suggestion_id", &votedIDs)
 
-	suggestion.Upvotes++
-	if err := database.DB.Save(&suggestion).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upvote suggestion"})
-		return
+		var reactions []models.Reaction
+		database.DB.Where("voter_id = ? AND suggestion_id IN ?", id, ids).Find(&reactions)
+		for _, r := range reactions {
+			myReactions[r.SuggestionID] = r.Type
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"upvotes": suggestion.Upvotes})
+	c.JSON(http.StatusOK, gin.H{
+		"total":        total,
+		"page":         page,
+		"page_size":    pageSize,
+		"data":         suggestions,
+		"voted_ids":    votedIDs,
+		"my_reactions": myReactions,
+	})
 }
 
 // DeleteSuggestions godoc
@@ -218,6 +443,54 @@ func DeleteSuggestions(c *gin.Context) {
 		return
 	}
 
+	// Kept to tell the admins who could see them that they are gone
+	var deleted []models.Suggestion
+	database.DB.Select("id", "department_id").Where("id IN ?", requestBody.IDs).Find(&deleted)
+
+	// Unlink duplicates and duplicate candidates of the deleted suggestions
+	if err := database.DB.Model(&models.Suggestion{}).Where("canonical_id IN ?", requestBody.IDs).Update("canonical_id", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink merged duplicates"})
+		return
+	}
+	if err := database.DB.Where("suggestion_id IN ? OR candidate_id IN ?", requestBody.IDs, requestBody.IDs).Delete(&models.DuplicateCandidate{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete duplicate candidates"})
+		return
+	}
+
+	if err := database.DB.Where("suggestion_id IN ?", requestBody.IDs).Delete(&models.Vote{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated votes"})
+		return
+	}
+	if err := database.DB.Where("suggestion_id IN ?", requestBody.IDs).Delete(&models.Reaction{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated reactions"})
+		return
+	}
+	if err := database.DB.Where("suggestion_id IN ?", requestBody.IDs).Delete(&models.Comment{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated comments"})
+		return
+	}
+	if err := database.DB.Where("suggestion_id IN ?", requestBody.IDs).Delete(&models.SuggestionContact{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated contacts"})
+		return
+	}
+	if err := database.DB.Where("suggestion_id IN ?", requestBody.IDs).Delete(&models.Notification{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated notifications"})
+		return
+	}
+	if err := database.DB.Where("suggestion_id IN ?", requestBody.IDs).Delete(&models.SatisfactionRating{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated ratings"})
+		return
+	}
+	if err := database.DB.Where("suggestion_id IN ?", requestBody.IDs).Delete(&models.SuggestionRevision{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated revisions"})
+		return
+	}
+
+	if err := services.DeleteAttachments(requestBody.IDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated attachments"})
+		return
+	}
+
 	// Also delete associated replies
 	if err := database.DB.Where("suggestion_id IN ?", requestBody.IDs).Delete(&models.Reply{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated replies"})
@@ -228,6 +501,10 @@ func DeleteSuggestions(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete suggestions"})
 		return
 	}
+	services.RemoveFromSearchIndex(requestBody.IDs)
+	for i := range deleted {
+		services.PublishSuggestionEvent(services.EventSuggestionDeleted, &deleted[i])
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Suggestions and associated replies deleted successfully"})
 }
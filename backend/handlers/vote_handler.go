@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	voterCookieName = "voter_id"
+	voterCookieAge  = 365 * 24 * 60 * 60
+
+	// Students behind a shared network, such as a dormitory, vote from the same IP,
+	// so several voters per IP are allowed on each suggestion
+	maxVotesPerIP = 5
+)
+
+var (
+	errAlreadyVoted    = errors.New("already voted")
+	errIPVoteLimit     = errors.New("too many votes from this IP")
+	errNotVotedYet     = errors.New("not voted")
+	unapprovedStatuses = []string{"待审核", "审核不通过", "已撤回"}
+)
+
+// currentVoterID returns the voter ID from a valid voter cookie
+func currentVoterID(c *gin.Context) (string, bool) {
+	token, err := c.Cookie(voterCookieName)
+	if err != nil {
+		return "", false
+	}
+	return utils.ParseVoterToken(token)
+}
+
+// ensureVoterID returns the current voter ID, issuing a new voter cookie if there is none
+func ensureVoterID(c *gin.Context) string {
+	if id, ok := currentVoterID(c); ok {
+		return id
+	}
+	token := utils.NewVoterToken()
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(voterCookieName, token, voterCookieAge, "/", "", c.Request.TLS != nil, true)
+	id, _ := utils.ParseVoterToken(token)
+	return id
+}
+
+// getVotableSuggestion loads a public, approved suggestion, responding with 404 for any other suggestion
+func getVotableSuggestion(c *gin.Context, id string) (*models.Suggestion, bool) {
+	var suggestion models.Suggestion
+	err := database.DB.
+		Where("is_public = ?", true).
+		Where("status NOT IN ?", unapprovedStatuses).
+		Where("canonical_id IS NULL").
+		First(&suggestion, id).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestion not found"})
+		return nil, false
+	}
+	return &suggestion, true
+}
+
+// respondWithUpvotes sends the current upvote count of a suggestion
+func respondWithUpvotes(c *gin.Context, suggestionID uint, voted bool) {
+	var upvotes int
+	database.DB.Model(&models.Suggestion{}).Where("id = ?", suggestionID).Pluck("upvotes", &upvotes)
+	c.JSON(http.StatusOK, gin.H{"upvotes": upvotes, "voted": voted})
+}
+
+// UpvoteSuggestion godoc
+// @Summary Upvote a suggestion
+// @Description Upvote a public, approved suggestion. Each voter, identified by a signed cookie, may upvote a suggestion once.
+// @Tags suggestions
+// @Produce  json
+// @Param   id     path    int     true        "Suggestion ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 409 {object} map[string]string
+// @Router /suggestions/{id}/upvote [post]
+func UpvoteSuggestion(c *gin.Context) {
+	suggestion, ok := getVotableSuggestion(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	vote := models.Vote{
+		SuggestionID: suggestion.ID,
+		VoterID:      ensureVoterID(c),
+		IPHash:       utils.HashIP(c.ClientIP()),
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var ipVotes int64
+		if err := tx.Model(&models.Vote{}).Where("suggestion_id = ? AND ip_hash = ?", vote.SuggestionID, vote.IPHash).Count(&ipVotes).Error; err != nil {
+			return err
+		}
+		if ipVotes >= maxVotesPerIP {
+			return errIPVoteLimit
+		}
+
+		// The unique index on suggestion and voter rejects concurrent duplicate votes
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&vote)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadyVoted
+		}
+		return tx.Model(&models.Suggestion{}).Where("id = ?", suggestion.ID).
+			Update("upvotes", gorm.Expr("upvotes + 1")).Error
+	})
+	switch {
+	case errors.Is(err, errAlreadyVoted):
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already upvoted this suggestion"})
+		return
+	case errors.Is(err, errIPVoteLimit):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many upvotes from your network for this suggestion"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upvote suggestion"})
+		return
+	}
+
+	respondWithUpvotes(c, suggestion.ID, true)
+}
+
+// RemoveUpvote godoc
+// @Summary Remove an upvote
+// @Description Withdraw the current voter's upvote of a suggestion.
+// @Tags suggestions
+// @Produce  json
+// @Param   id     path    int     true        "Suggestion ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /suggestions/{id}/upvote [delete]
+func RemoveUpvote(c *gin.Context) {
+	suggestion, ok := getVotableSuggestion(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	voterID, ok := currentVoterID(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You have not upvoted this suggestion"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("suggestion_id = ? AND voter_id = ?", suggestion.ID, voterID).Delete(&models.Vote{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNotVotedYet
+		}
+		return tx.Model(&models.Suggestion{}).Where("id = ? AND upvotes > 0", suggestion.ID).
+			Update("upvotes", gorm.Expr("upvotes - 1")).Error
+	})
+	switch {
+	case errors.Is(err, errNotVotedYet):
+		c.JSON(http.StatusNotFound, gin.H{"error": "You have not upvoted this suggestion"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove upvote"})
+		return
+	}
+
+	respondWithUpvotes(c, suggestion.ID, false)
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/services"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookInput struct {
+	Name         string   `json:"name" binding:"required"`
+	URL          string   `json:"url" binding:"required"`
+	EventTypes   []string `json:"event_types"`   // Every event when empty
+	DepartmentID *uint    `json:"department_id"` // Every department when empty
+	Secret       string   `json:"secret"`        // Generated on creation and kept on update when empty
+	IsActive     *bool    `json:"is_active"`
+}
+
+// WebhookResponse is a webhook as returned to admins. The secret itself is only returned by the request that sets it,
+// afterwards its last characters help tell which secret a receiver was configured with.
+type WebhookResponse struct {
+	models.Webhook
+	Secret     string `json:",omitempty"`
+	SecretHint string
+}
+
+// newWebhookResponse returns a webhook with a hint of its secret, and the secret itself when withSecret is set
+func newWebhookResponse(webhook models.Webhook, withSecret bool) WebhookResponse {
+	response := WebhookResponse{Webhook: webhook, SecretHint: "****"}
+	if len(webhook.Secret) >= 12 {
+		response.SecretHint += webhook.Secret[len(webhook.Secret)-4:]
+	}
+	if withSecret {
+		response.Secret = webhook.Secret
+	}
+	return response
+}
+
+// bindWebhookInput binds and validates a webhook request body
+func bindWebhookInput(c *gin.Context) (*WebhookInput, bool) {
+	var input WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err := services.ValidateWebhookURL(input.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL must be an absolute http or https URL"})
+		return nil, false
+	}
+	for _, t := range input.EventTypes {
+		if !services.IsValidEventType(t) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event type: " + t})
+			return nil, false
+		}
+	}
+	if input.DepartmentID != nil {
+		var department models.Department
+		if err := database.DB.First(&department, *input.DepartmentID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+			return nil, false
+		}
+	}
+	return &input, true
+}
+
+// GetWebhooks godoc
+// @Summary Get all webhooks
+// @Description Get the webhooks that receive suggestion events.
+// @Tags admin-webhooks
+// @Security ApiKeyAuth
+// @Produce  json
+// @Success 200 {array} WebhookResponse
+// @Router /admin/webhooks [get]
+func GetWebhooks(c *gin.Context) {
+	var webhooks []models.Webhook
+	if err := database.DB.Order("id ASC").Find(&webhooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhooks"})
+		return
+	}
+	response := make([]WebhookResponse, len(webhooks))
+	for i, webhook := range webhooks {
+		response[i] = newWebhookResponse(webhook, false)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateWebhook godoc
+// @Summary Create a webhook
+// @Description Subscribe a URL to suggestion events. Each delivery is a JSON POST signed in X-Webhook-Signature
+// @Description with sha256=HMAC-SHA256(secret, X-Webhook-Timestamp + "." + body), retried with exponential backoff until a 2xx response.
+// @Description The secret is only returned in this response, save it to verify the signatures.
+// @Tags admin-webhooks
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param webhook body WebhookInput true "Webhook"
+// @Success 200 {object} WebhookResponse
+// @Router /admin/webhooks [post]
+func CreateWebhook(c *gin.Context) {
+	input, ok := bindWebhookInput(c)
+	if !ok {
+		return
+	}
+	if input.Secret == "" {
+		input.Secret = services.NewWebhookSecret()
+	}
+
+	webhook := models.Webhook{
+		Name:         input.Name,
+		URL:          input.URL,
+		EventTypes:   strings.Join(input.EventTypes, ","),
+		DepartmentID: input.DepartmentID,
+		Secret:       input.Secret,
+		IsActive:     input.IsActive == nil || *input.IsActive,
+	}
+	if err := database.DB.Create(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, newWebhookResponse(webhook, true))
+}
+
+// UpdateWebhook godoc
+// @Summary Update a webhook
+// @Description Update an existing webhook. Queued deliveries are sent with the new URL and secret.
+// @Tags admin-webhooks
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Webhook ID"
+// @Param webhook body WebhookInput true "Webhook"
+// @Success 200 {object} WebhookResponse
+// @Router /admin/webhooks/{id} [put]
+func UpdateWebhook(c *gin.Context) {
+	webhookID := c.Param("id")
+	input, ok := bindWebhookInput(c)
+	if !ok {
+		return
+	}
+
+	var webhook models.Webhook
+	if err := database.DB.First(&webhook, webhookID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	webhook.Name = input.Name
+	webhook.URL = input.URL
+	webhook.EventTypes = strings.Join(input.EventTypes, ",")
+	webhook.DepartmentID = input.DepartmentID
+	if input.Secret != "" {
+		webhook.Secret = input.Secret
+	}
+	if input.IsActive != nil {
+		webhook.IsActive = *input.IsActive
+	}
+	if err := database.DB.Save(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, newWebhookResponse(webhook, false))
+}
+
+// RotateWebhookSecret godoc
+// @Summary Rotate the secret of a webhook
+// @Description Replace the signing secret of a webhook with a new random one, returned only in this response.
+// @Description Queued deliveries are signed with the new secret.
+// @Tags admin-webhooks
+// @Security ApiKeyAuth
+// @Produce  json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} WebhookResponse
+// @Router /admin/webhooks/{id}/rotate-secret [post]
+func RotateWebhookSecret(c *gin.Context) {
+	var webhook models.Webhook
+	if err := database.DB.First(&webhook, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	webhook.Secret = services.NewWebhookSecret()
+	if err := database.DB.Model(&webhook).Update("secret", webhook.Secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate secret"})
+		return
+	}
+	c.JSON(http.StatusOK, newWebhookResponse(webhook, true))
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook
+// @Description Remove a webhook and its delivery log.
+// @Tags admin-webhooks
+// @Security ApiKeyAuth
+// @Param id path int true "Webhook ID"
+// @Success 204
+// @Router /admin/webhooks/{id} [delete]
+func DeleteWebhook(c *gin.Context) {
+	webhookID := c.Param("id")
+	if err := database.DB.Where("webhook_id = ?", webhookID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook deliveries"})
+		return
+	}
+	if err := database.DB.Delete(&models.Webhook{}, webhookID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// TestWebhook godoc
+// @Summary Send a test event to a webhook
+// @Description Queue a "ping" event for a webhook, delivered like any other event.
+// @Tags admin-webhooks
+// @Security ApiKeyAuth
+// @Produce  json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} models.WebhookDelivery
+// @Router /admin/webhooks/{id}/test [post]
+func TestWebhook(c *gin.Context) {
+	var webhook models.Webhook
+	if err := database.DB.First(&webhook, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	delivery, err := services.EnqueueWebhookDelivery(webhook.ID, services.WebhookPayload{
+		EventID:    "ping",
+		Event:      "ping",
+		OccurredAt: time.Now(),
+		Data:       gin.H{"webhook_id": webhook.ID},
+	}, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue test event"})
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// GetWebhookDeliveries godoc
+// @Summary Get the delivery log of a webhook
+// @Description Get the queued and attempted deliveries of a webhook, newest first.
+// @Tags admin-webhooks
+// @Security ApiKeyAuth
+// @Produce  json
+// @Param id path int true "Webhook ID"
+// @Param status query string false "Filter by status (pending, succeeded, failed)"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/webhooks/{id}/deliveries [get]
+func GetWebhookDeliveries(c *gin.Context) {
+	page, pageSize := paginate(c)
+
+	query := database.DB.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", c.Param("id"))
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	deliveries := []models.WebhookDelivery{}
+	query.Count(&total)
+	if err := query.Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhook deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"data":      deliveries,
+	})
+}
+
+// ReplayWebhookDelivery godoc
+// @Summary Replay a webhook delivery
+// @Description Queue the payload of a past delivery again, as a new delivery signed at sending time.
+// @Tags admin-webhooks
+// @Security ApiKeyAuth
+// @Produce  json
+// @Param id path int true "Webhook ID"
+// @Param delivery_id path int true "Delivery ID"
+// @Success 200 {object} models.WebhookDelivery
+// @Router /admin/webhooks/{id}/deliveries/{delivery_id}/replay [post]
+func ReplayWebhookDelivery(c *gin.Context) {
+	var delivery models.WebhookDelivery
+	if err := database.DB.Where("id = ? AND webhook_id = ?", c.Param("delivery_id"), c.Param("id")).First(&delivery).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+
+	replay, err := services.ReplayWebhookDelivery(&delivery)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay delivery"})
+		return
+	}
+	c.JSON(http.StatusOK, replay)
+}
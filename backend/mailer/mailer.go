@@ -0,0 +1,106 @@
+package mailer
+
+import (
+	"advice/utils"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	Headers map[string]string // Extra headers such as List-Unsubscribe
+}
+
+// Sender delivers email messages
+type Sender interface {
+	Send(msg Message) error
+}
+
+// Default is the sender used for notifications, configured by Init
+var Default Sender = LogSender{}
+
+// Init configures the default sender from the environment. Without SMTP_HOST messages are only logged.
+//
+//	SMTP_HOST     SMTP server host
+//	SMTP_PORT     default 587
+//	SMTP_USERNAME user for PLAIN authentication, none when empty
+//	SMTP_PASSWORD password for PLAIN authentication
+//	SMTP_FROM     sender address, default SMTP_USERNAME
+//	SMTP_TLS      "true" for implicit TLS (usually port 465), otherwise STARTTLS is used when offered
+func Init() {
+	host := utils.Getenv("SMTP_HOST", "")
+	if host == "" {
+		log.Println("SMTP_HOST is not set, email notifications are only logged")
+		Default = LogSender{}
+		return
+	}
+	username := utils.Getenv("SMTP_USERNAME", "")
+	Default = &SMTPSender{
+		Host:     host,
+		Port:     utils.GetenvInt("SMTP_PORT", 587),
+		Username: username,
+		Password: utils.Getenv("SMTP_PASSWORD", ""),
+		From:     utils.Getenv("SMTP_FROM", username),
+		TLS:      utils.GetenvBool("SMTP_TLS", false),
+	}
+}
+
+// LogSender logs messages instead of sending them, for development without an SMTP server
+type LogSender struct{}
+
+func (LogSender) Send(msg Message) error {
+	log.Printf("[mail] to %s: %s", msg.To, msg.Subject)
+	return nil
+}
+
+// headerValue removes line breaks so that values cannot inject headers
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// build encodes a message as a UTF-8 MIME email from the given address
+func build(from string, msg Message) []byte {
+	id := make([]byte, 12)
+	rand.Read(id)
+	domain := "localhost"
+	if _, d, found := strings.Cut(from, "@"); found {
+		domain = d
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&buf, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", headerValue(msg.Subject)))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), headerValue(domain))
+	keys := make([]string, 0, len(msg.Headers))
+	for key := range msg.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%s: %s\r\n", headerValue(key), headerValue(msg.Headers[key]))
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	body := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(body) > 76 {
+		buf.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	buf.WriteString(body + "\r\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,114 @@
+// Package mailertest provides a stand-in SMTP server for tests.
+package mailertest
+
+import (
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// Message is an email received by the server
+type Message struct {
+	From string
+	To   []string
+	Data string // Headers and body as sent after DATA, with LF line endings
+}
+
+// Server accepts every message on a local port, optionally requiring PLAIN authentication
+type Server struct {
+	Host     string
+	Port     int
+	Username string // Required with Password when not empty
+	Password string
+
+	// Messages receives each accepted message
+	Messages chan Message
+
+	listener net.Listener
+}
+
+// NewServer starts a server that is closed when the test ends
+func NewServer(t *testing.T, username, password string) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	s := &Server{
+		Host:     addr.IP.String(),
+		Port:     addr.Port,
+		Username: username,
+		Password: password,
+		Messages: make(chan Message, 16),
+		listener: ln,
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP mailertest")
+
+	authenticated := s.Username == ""
+	var msg Message
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			text.PrintfLine("250-localhost")
+			text.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			mechanism, response, _ := strings.Cut(arg, " ")
+			credentials, _ := base64.StdEncoding.DecodeString(response)
+			if mechanism == "PLAIN" && string(credentials) == "\x00"+s.Username+"\x00"+s.Password {
+				authenticated = true
+				text.PrintfLine("235 2.7.0 Authentication successful")
+			} else {
+				text.PrintfLine("535 5.7.8 Authentication credentials invalid")
+			}
+		case "MAIL":
+			if !authenticated {
+				text.PrintfLine("530 5.7.0 Authentication required")
+				continue
+			}
+			msg = Message{From: strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")}
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			s.Messages <- msg
+			text.PrintfLine("250 OK: queued")
+		case "RSET", "NOOP":
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
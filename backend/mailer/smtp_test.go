@@ -0,0 +1,96 @@
+package mailer
+
+import (
+	"advice/mailer/mailertest"
+	"encoding/base64"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, server *mailertest.Server) mailertest.Message {
+	select {
+	case msg := <-server.Messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message was received")
+		return mailertest.Message{}
+	}
+}
+
+func TestSMTPSenderSend(t *testing.T) {
+	server := mailertest.NewServer(t, "notify@example.edu", "s3cret")
+	sender := &SMTPSender{Host: server.Host, Port: server.Port, Username: "notify@example.edu", Password: "s3cret", From: "notify@example.edu"}
+
+	body := strings.Repeat("您的建议状态已更新为：已解决。", 10)
+	err := sender.Send(Message{
+		To:      "student@example.com",
+		Subject: "您的建议「食堂」状态已更新",
+		Body:    body,
+		Headers: map[string]string{"List-Unsubscribe": "<http://localhost/unsubscribe>\r\nBcc: victim@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	received := receive(t, server)
+	if received.From != "notify@example.edu" || len(received.To) != 1 || received.To[0] != "student@example.com" {
+		t.Errorf("envelope from %q to %v", received.From, received.To)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(received.Data))
+	if err != nil {
+		t.Fatalf("received message does not parse: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "您的建议「食堂」状态已更新" {
+		t.Errorf("Subject = %q, %v", subject, err)
+	}
+	if got := msg.Header.Get("Content-Transfer-Encoding"); got != "base64" {
+		t.Errorf("Content-Transfer-Encoding = %q, want base64", got)
+	}
+	if got := msg.Header.Get("Content-Type"); got != "text/plain; charset=UTF-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if msg.Header.Get("Message-Id") == "" || msg.Header.Get("Date") == "" {
+		t.Error("Message-ID or Date header is missing")
+	}
+	if got := msg.Header.Get("List-Unsubscribe"); got != "<http://localhost/unsubscribe>Bcc: victim@example.com" {
+		t.Errorf("List-Unsubscribe = %q, line breaks must be removed", got)
+	}
+	if msg.Header.Get("Bcc") != "" {
+		t.Error("a header was injected through a header value")
+	}
+
+	raw, _ := io.ReadAll(msg.Body)
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		if len(line) > 76 {
+			t.Errorf("body line of %d characters, want at most 76", len(line))
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(raw), "\n", ""))
+	if err != nil || string(decoded) != body {
+		t.Errorf("body decodes to %q, %v", decoded, err)
+	}
+}
+
+func TestSMTPSenderErrors(t *testing.T) {
+	server := mailertest.NewServer(t, "notify@example.edu", "s3cret")
+
+	wrongPassword := &SMTPSender{Host: server.Host, Port: server.Port, Username: "notify@example.edu", Password: "wrong", From: "notify@example.edu"}
+	if err := wrongPassword.Send(Message{To: "student@example.com", Subject: "s", Body: "b"}); err == nil || !strings.Contains(err.Error(), "535") {
+		t.Errorf("Send with a wrong password: err = %v, want 535", err)
+	}
+
+	noSender := &SMTPSender{Host: server.Host, Port: server.Port}
+	if err := noSender.Send(Message{To: "student@example.com"}); err == nil {
+		t.Error("Send without a sender address succeeded")
+	}
+	select {
+	case msg := <-server.Messages:
+		t.Errorf("message to %v was delivered", msg.To)
+	default:
+	}
+}
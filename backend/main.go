@@ -3,7 +3,11 @@ package main
 import (
 	"advice/database"
 	_ "advice/docs" // This is required for swag to find your docs
+	"advice/mailer"
 	"advice/router"
+	"advice/services"
+	"advice/sso"
+	"advice/storage"
 )
 
 // @title Student Suggestion API
@@ -18,6 +22,17 @@ func main() {
 	// Initialize Database
 	database.ConnectDatabase()
 	database.AutoMigrate()
+	services.RebuildSearchIndex()
+	services.LoadSimilarityIndex()
+	services.ReloadWordLists()
+	storage.Init()
+	mailer.Init()
+	sso.Init()
+
+	// Start background jobs
+	services.StartSLAScheduler()
+	services.StartDigestScheduler()
+	services.StartWebhookDispatcher()
 
 	// Initialize Router
 	r := router.SetupRouter()
@@ -32,6 +32,13 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Student tokens only work on student routes, and tokens of any other role are rejected
+		if claims.Role != "super_admin" && claims.Role != "department_admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This action requires an admin account"})
+			c.Abort()
+			return
+		}
+
 		// Set user claims in context for later use in handlers
 		c.Set("user_claims", claims)
 		c.Next()
@@ -57,3 +64,62 @@ func SuperAdminMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// EventTicketAuth authenticates EventSource clients, which cannot set headers, with a ticket from
+// POST /admin/events/ticket in the ticket query parameter. Other clients use the Authorization header as usual.
+func EventTicketAuth() gin.HandlerFunc {
+	authorize := AuthMiddleware()
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if ticket == "" {
+			authorize(c)
+			return
+		}
+		claims, err := utils.ParseEventTicket(ticket)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired ticket"})
+			c.Abort()
+			return
+		}
+		c.Set("user_claims", claims)
+		c.Next()
+	}
+}
+
+// StudentAuthMiddleware requires the JWT of a student account and sets its claims as "student_claims"
+func StudentAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := studentClaims(c)
+		if claims == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Student login is required"})
+			c.Abort()
+			return
+		}
+		c.Set("student_claims", claims)
+		c.Next()
+	}
+}
+
+// OptionalStudentAuth sets "student_claims" when the request carries a valid JWT of a student account.
+// Other requests, including those with an admin or expired token, go through anonymously.
+func OptionalStudentAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if claims := studentClaims(c); claims != nil {
+			c.Set("student_claims", claims)
+		}
+		c.Next()
+	}
+}
+
+// studentClaims returns the claims of a valid student bearer token, nil otherwise
+func studentClaims(c *gin.Context) *utils.Claims {
+	tokenString, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found {
+		return nil
+	}
+	claims, err := utils.ValidateJWT(tokenString)
+	if err != nil || claims.Role != "student" {
+		return nil
+	}
+	return claims
+}
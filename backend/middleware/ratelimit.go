@@ -1,51 +1,122 @@
 package middleware
 
 import (
+	"advice/ratelimit"
+	"advice/utils"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// KeyFunc identifies who a request is counted against
+type KeyFunc func(c *gin.Context) string
+
+// ByIP counts requests per client IP
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser counts requests per logged in admin, or per IP for anonymous requests
+func ByUser(c *gin.Context) string {
+	if claims, exists := c.Get("user_claims"); exists {
+		return fmt.Sprintf("user:%d", claims.(*utils.Claims).UserID)
+	}
+	return ByIP(c)
+}
+
+// ByTrackingCode counts requests per suggestion tracking code
+func ByTrackingCode(c *gin.Context) string {
+	return "code:" + strings.ToUpper(c.Param("tracking_code"))
+}
+
+// defaultRateLimits are the policies per route group, each can be overridden with RATE_LIMIT_<NAME>, e.g. RATE_LIMIT_LOGIN=10/5m
+var defaultRateLimits = map[string]string{
+	"submission":    "3/1m",
+	"lookup":        "30/1m",
+	"upvote":        "20/1m",
+	"comment":       "5/1m",
+	"upload":        "10/1m",
+	"login":         "5/1m",
+	"student_login": "10/1m", // Students often share a campus IP, and must not use up the quota of admin logins
+}
+
 var (
-	requests = make(map[string][]int64)
-	mu       sync.Mutex
+	limiters   = map[string]ratelimit.Limiter{}
+	limitersMu sync.Mutex
+	sharedOnce sync.Once
+	shared     ratelimit.Store
 )
 
-const (
-	limit  = 3
-	period = time.Minute
-)
+// rateLimitStore returns the counter store of sliding window limiters, chosen with RATE_LIMIT_STORE
+func rateLimitStore() ratelimit.Store {
+	sharedOnce.Do(func() {
+		switch backend := utils.Getenv("RATE_LIMIT_STORE", "memory"); backend {
+		case "memory":
+			shared = ratelimit.NewMemoryStore()
+		case "redis":
+			shared = ratelimit.NewRedisStore(utils.Getenv("RATE_LIMIT_REDIS_ADDR", "127.0.0.1:6379"), utils.Getenv("RATE_LIMIT_REDIS_PASSWORD", ""))
+		default:
+			log.Fatalf("Unknown rate limit store %q", backend)
+		}
+	})
+	return shared
+}
+
+// limiterFor returns the limiter of a named policy, shared by every route using the name.
+// RATE_LIMIT_ALGORITHM chooses sliding_window (default) or token_bucket.
+func limiterFor(name string) ratelimit.Limiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	if limiter, ok := limiters[name]; ok {
+		return limiter
+	}
+
+	spec := utils.Getenv("RATE_LIMIT_"+strings.ToUpper(name), defaultRateLimits[name])
+	policy, err := ratelimit.ParsePolicy(spec)
+	if err != nil {
+		log.Fatalf("Invalid rate limit for %s: %v", name, err)
+	}
+
+	var limiter ratelimit.Limiter
+	switch algorithm := utils.Getenv("RATE_LIMIT_ALGORITHM", "sliding_window"); algorithm {
+	case "sliding_window":
+		limiter = ratelimit.NewSlidingWindow(policy, rateLimitStore(), "ratelimit:"+name)
+	case "token_bucket":
+		limiter = ratelimit.NewTokenBucket(policy)
+	default:
+		log.Fatalf("Unknown rate limit algorithm %q", algorithm)
+	}
+	limiters[name] = limiter
+	return limiter
+}
+
+// ceilSeconds rounds a duration up to whole seconds for headers
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
 
-func RateLimiter() gin.HandlerFunc {
+// RateLimit limits the requests of the named policy, counting them per key
+func RateLimit(policy string, key KeyFunc) gin.HandlerFunc {
+	limiter := limiterFor(policy)
 	return func(c *gin.Context) {
-		mu.Lock()
-		defer mu.Unlock()
-
-		ip := c.ClientIP()
-		now := time.Now().Unix()
-
-		// Clean up old timestamps
-		if timestamps, exists := requests[ip]; exists {
-			var recentTimestamps []int64
-			for _, ts := range timestamps {
-				if now-ts < int64(period.Seconds()) {
-					recentTimestamps = append(recentTimestamps, ts)
-				}
-			}
-			requests[ip] = recentTimestamps
-		}
+		result := limiter.Allow(key(c))
 
-		// Check limit
-		if len(requests[ip]) >= limit {
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", ceilSeconds(result.Reset))
+		if !result.Allowed {
+			c.Header("Retry-After", ceilSeconds(result.RetryAfter))
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests. Please try again later."})
 			return
 		}
 
-		// Add new timestamp
-		requests[ip] = append(requests[ip], now)
-
 		c.Next()
 	}
 }
@@ -12,6 +12,19 @@ type AdminUser struct {
 	Department   Department `gorm:"foreignKey:DepartmentID"`
 	CanViewAll   bool       `gorm:"default:false"`
 	CreatedAt    time.Time
+	Identities   []AdminIdentity `gorm:"foreignKey:AdminID" json:",omitempty"` // External SSO accounts linked to the admin
+}
+
+// AdminIdentity links an account at an external identity provider to an admin
+type AdminIdentity struct {
+	ID          uint   `gorm:"primaryKey"`
+	AdminID     uint   `gorm:"index;not null"`
+	Provider    string `gorm:"uniqueIndex:idx_identity_subject;not null"` // "oidc" or "cas"
+	Subject     string `gorm:"uniqueIndex:idx_identity_subject;not null"` // Stable ID of the account at the provider
+	Username    string
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt *time.Time
 }
 
 // Suggestion represents a student's suggestion
@@ -20,17 +33,77 @@ type Suggestion struct {
 	TrackingCode   string `gorm:"unique;not null"`
 	Title          string `gorm:"not null"`
 	Content        string `gorm:"not null"`
-	Category       string
+	Category       string // Name of a Category
 	DepartmentID   *uint
 	Department     Department `gorm:"foreignKey:DepartmentID"`
 	SubmitterName  string
 	SubmitterClass string
-	Status         string `gorm:"not null;default:'待审核'"` // "待审核", "待处理", "处理中", "已解决", "已关闭", "审核不通过"
-	IsPublic       bool   `gorm:"default:false"`
-	Upvotes        int    `gorm:"default:0"`
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Replies        []Reply
+	StudentNumber  string           // Only set when a logged-in student shares their identity
+	Status         string           `gorm:"not null;default:'待审核'"` // "待审核", "待处理", "处理中", "已解决", "已关闭", "审核不通过", "已合并", "已撤回"
+	IsPublic       bool             `gorm:"default:false"`
+	Upvotes        int              `gorm:"default:0"`
+	MeTooCount     int              `gorm:"default:0"` // "me_too" reactions
+	DisagreeCount  int              `gorm:"default:0"` // "disagree" reactions
+	CommentCount   int              `gorm:"default:0"` // Approved public comments
+	Moderation     ModerationResult `gorm:"embedded;embeddedPrefix:moderation_"`
+	AssigneeID     *uint            // AdminUser ID responsible for handling the suggestion
+	Assignee       AdminUser        `gorm:"foreignKey:AssigneeID"`
+	Priority       string           `gorm:"not null;default:'normal'"` // "low", "normal", "high", "urgent"
+	IsSafetyIssue  bool             `gorm:"default:false"`             // Flagged by the student as a safety issue
+	IsVerified     bool             `gorm:"default:false"`             // Submitted by a logged-in student
+	RoutedByRuleID *uint            // RoutingRule that chose the department, if any
+	CanonicalID    *uint            // Set when merged as a duplicate into another suggestion
+	Canonical      *Suggestion      `gorm:"foreignKey:CanonicalID"`
+	// SLA tracking, due dates are computed from the matching SLAPolicy on submission
+	FirstReplyDueAt *time.Time
+	ResolutionDueAt *time.Time
+	FirstRepliedAt  *time.Time
+	IsOverdue       bool `gorm:"default:false"` // Open and past an SLA deadline, kept up to date by the SLA scheduler
+	// Each deadline is escalated and warned about once, set when it happens
+	FirstReplyEscalatedAt *time.Time
+	ResolutionEscalatedAt *time.Time
+	FirstReplyWarnedAt    *time.Time
+	ResolutionWarnedAt    *time.Time
+	// Resolution feedback, ResolvedAt, ResolvedByID and ReopenableUntil are cleared when the suggestion leaves "已解决"
+	ResolvedAt      *time.Time
+	ResolvedByID    *uint      // AdminUser ID
+	ReopenableUntil *time.Time // The student may reopen an unsatisfying resolution until then
+	ReopenCount     int        `gorm:"default:0"`
+	ReopenReason    string     // Given by the student at the last reopening
+	WithdrawnAt     *time.Time // Set when the student withdraws the suggestion
+	WithdrawReason  string
+	// Hash of the anonymous key bundling the suggestions of one submitter, never shown
+	SubmitterKeyHash string     `gorm:"index" json:"-"`
+	SubmitterSeenAt  *time.Time `json:"-"` // Last time the submitter looked at the suggestion, for unread replies
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Replies          []Reply
+	Attachments      []Attachment // Includes reply attachments unless preloaded with a condition
+	Ratings          []SatisfactionRating
+	Revisions        []SuggestionRevision `json:",omitempty"` // Only loaded for admins
+}
+
+// SuggestionRevision keeps the text of a suggestion as it was before the student edited it
+type SuggestionRevision struct {
+	ID           uint `gorm:"primaryKey"`
+	SuggestionID uint `gorm:"index;not null"`
+	Title        string
+	Content      string
+	Category     string
+	DepartmentID *uint
+	CreatedAt    time.Time // When the edit replaced this text
+}
+
+// Student is an account imported from the student roster, used to submit verified suggestions
+type Student struct {
+	ID            uint   `gorm:"primaryKey"`
+	StudentNumber string `gorm:"unique;not null"`
+	Name          string `gorm:"not null"`
+	Class         string
+	PasswordHash  string `gorm:"not null" json:"-"`
+	IsActive      bool   `gorm:"not null"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
 }
 
 // Department represents a school department
@@ -39,6 +112,33 @@ type Department struct {
 	Name string `gorm:"unique;not null"`
 }
 
+// Category represents a suggestion category managed by super admins
+type Category struct {
+	ID                  uint   `gorm:"primaryKey"`
+	Name                string `gorm:"unique;not null"`
+	Description         string
+	IsActive            bool       `gorm:"not null"`
+	SortOrder           int        `gorm:"default:0"`
+	DefaultDepartmentID *uint      // Department suggestions are routed to when the student picks none
+	DefaultDepartment   Department `gorm:"foreignKey:DefaultDepartmentID"`
+}
+
+// RoutingRule assigns a department to suggestions submitted without one.
+// Every condition that is set must match; active rules are tried in ascending Priority order.
+type RoutingRule struct {
+	ID           uint       `gorm:"primaryKey"`
+	Name         string     `gorm:"not null"`
+	Priority     int        `gorm:"not null;default:0"`
+	Category     string     // Exact category name
+	Keywords     string     // Comma separated, any keyword in the title or content matches
+	Pattern      string     // Regular expression matched against the title and content
+	DepartmentID uint       `gorm:"not null"`
+	Department   Department `gorm:"foreignKey:DepartmentID"`
+	IsActive     bool       `gorm:"not null"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
 // Reply represents an admin's reply to a suggestion
 type Reply struct {
 	ID           uint      `gorm:"primaryKey"`
@@ -47,4 +147,195 @@ type Reply struct {
 	ReplierID    uint      `gorm:"not null"` // AdminUser ID
 	Replier      AdminUser `gorm:"foreignKey:ReplierID"`
 	CreatedAt    time.Time
+	Attachments  []Attachment
+}
+
+// Attachment is a photo or document uploaded with a suggestion or an admin reply
+type Attachment struct {
+	ID           uint   `gorm:"primaryKey"`
+	SuggestionID uint   `gorm:"not null;index"`
+	ReplyID      *uint  `gorm:"index"` // Set for attachments of an admin reply
+	FileName     string `gorm:"not null"`
+	ContentType  string `gorm:"not null"`
+	Size         int64
+	HasThumbnail bool
+	StorageKey   string `gorm:"not null" json:"-"`
+	ThumbnailKey string `json:"-"`
+	UploaderID   *uint  // AdminUser ID, empty when uploaded by the student
+	IsApproved   bool   `gorm:"not null;default:false"` // Only approved attachments are shown publicly
+	CreatedAt    time.Time
+}
+
+// Vote is an upvote of a public suggestion by an anonymous voter
+type Vote struct {
+	ID           uint   `gorm:"primaryKey"`
+	SuggestionID uint   `gorm:"not null;uniqueIndex:idx_vote_voter"`
+	VoterID      string `gorm:"not null;uniqueIndex:idx_vote_voter"` // From the signed voter cookie
+	IPHash       string `gorm:"not null;index"`
+	CreatedAt    time.Time
+}
+
+// SatisfactionRating is the student's rating of a resolution, one per resolution of a suggestion
+type SatisfactionRating struct {
+	ID           uint      `gorm:"primaryKey"`
+	SuggestionID uint      `gorm:"not null;uniqueIndex:idx_rating_resolution"`
+	ResolvedAt   time.Time `gorm:"not null;uniqueIndex:idx_rating_resolution"` // Resolution being rated
+	Score        int       `gorm:"not null"`                                   // 1 to 5
+	Comment      string
+	AdminID      *uint `gorm:"index"` // Assignee at the time, or the admin who resolved the suggestion
+	DepartmentID *uint `gorm:"index"`
+	CreatedAt    time.Time
+}
+
+// Notification is an in-app notification of an admin
+type Notification struct {
+	ID           uint   `gorm:"primaryKey"`
+	AdminID      uint   `gorm:"not null;index"`
+	Type         string `gorm:"not null"` // "new_suggestion", "follow_up", "transfer", "sla_warning"
+	Title        string `gorm:"not null"`
+	Message      string
+	SuggestionID *uint `gorm:"index"`
+	ReadAt       *time.Time
+	EmailedAt    *time.Time // Set once sent by email, instantly or in a digest
+	CreatedAt    time.Time  `gorm:"index"`
+}
+
+// NotificationPreference holds how an admin wants to be notified. Admins without one get every in-app notification and no email.
+type NotificationPreference struct {
+	ID           uint   `gorm:"primaryKey"`
+	AdminID      uint   `gorm:"uniqueIndex;not null"`
+	MutedTypes   string // Comma separated notification types the admin does not receive
+	Email        string
+	EmailMode    string `gorm:"not null;default:'off'"` // "off", "instant", "daily"
+	LastDigestAt *time.Time
+	UpdatedAt    time.Time
+}
+
+// Webhook posts signed suggestion events to an external system, managed by super admins
+type Webhook struct {
+	ID           uint   `gorm:"primaryKey"`
+	Name         string `gorm:"not null"`
+	URL          string `gorm:"not null"`
+	EventTypes   string // Comma separated, every event when empty
+	DepartmentID *uint  // Only events of this department's suggestions, every department when empty
+	Secret       string `gorm:"not null" json:"-"` // Key of the HMAC signature of each delivery, only returned when it is set
+	IsActive     bool   `gorm:"not null"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// WebhookDelivery is a queued or attempted delivery of an event to a webhook
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey"`
+	WebhookID      uint       `gorm:"not null;index"`
+	EventType      string     `gorm:"not null"`
+	Payload        string     `gorm:"not null"`
+	Status         string     `gorm:"not null;index"` // "pending", "succeeded", "failed"
+	Attempts       int        `gorm:"not null"`
+	NextAttemptAt  *time.Time `gorm:"index"` // Set while pending
+	LastAttemptAt  *time.Time
+	ResponseStatus int
+	ResponseBody   string // Truncated
+	Error          string
+	ReplayOfID     *uint // Delivery this one replays
+	CreatedAt      time.Time
+}
+
+// SuggestionContact is the optional email address a student left for notifications.
+// It is kept apart from the suggestion so it is never returned with it.
+type SuggestionContact struct {
+	ID             uint   `gorm:"primaryKey"`
+	SuggestionID   uint   `gorm:"uniqueIndex;not null"`
+	EncryptedEmail string `gorm:"not null" json:"-"`     // AES-GCM, see utils.EncryptString
+	Language       string `gorm:"not null;default:'zh'"` // "zh", "en"
+	Unsubscribed   bool   `gorm:"not null"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// LoginAttempt records an admin login, successful or not
+type LoginAttempt struct {
+	ID        uint   `gorm:"primaryKey"`
+	Username  string `gorm:"index"`
+	IP        string `gorm:"index"` // Client IP resolved through the trusted proxies
+	UserAgent string
+	Method    string // "password", "oidc" or "cas" for admins, "student" for student accounts
+	Success   bool
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// SensitiveWordList is a list of words checked by content moderation, managed by super admins
+type SensitiveWordList struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"unique;not null"`
+	Action    string `gorm:"not null"` // "block", "mask", "flag"
+	Words     string // One word per line
+	IsActive  bool   `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ModerationResult is the outcome of content moderation, stored with the moderated content
+type ModerationResult struct {
+	Action       string // Strictest action applied: "", "mask", "flag"
+	MatchedWords string // Comma separated
+	PIITypes     string // Comma separated: "phone", "id_card", "student_number"
+}
+
+// Reaction is a public reaction other than an upvote, one per voter and suggestion
+type Reaction struct {
+	ID           uint   `gorm:"primaryKey"`
+	SuggestionID uint   `gorm:"not null;uniqueIndex:idx_reaction_voter"`
+	VoterID      string `gorm:"not null;uniqueIndex:idx_reaction_voter" json:"-"`
+	Type         string `gorm:"not null"` // "me_too", "disagree"
+	IPHash       string `gorm:"not null;index" json:"-"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Comment is a public comment on a suggestion, shown once approved by an admin
+type Comment struct {
+	ID           uint        `gorm:"primaryKey"`
+	SuggestionID uint        `gorm:"not null;index"`
+	Suggestion   *Suggestion `gorm:"foreignKey:SuggestionID" json:",omitempty"`
+	Nickname     string
+	Content      string           `gorm:"not null"`
+	Status       string           `gorm:"not null;default:'待审核'"` // "待审核", "已通过", "审核不通过"
+	Moderation   ModerationResult `gorm:"embedded;embeddedPrefix:moderation_"`
+	VoterID      string           `gorm:"index" json:"-"`
+	IPHash       string           `gorm:"index" json:"-"`
+	ModeratorID  *uint            // AdminUser ID
+	ModeratedAt  *time.Time
+	CreatedAt    time.Time
+}
+
+// DuplicateCandidate records an earlier suggestion that a new suggestion possibly duplicates
+type DuplicateCandidate struct {
+	ID           uint       `gorm:"primaryKey"`
+	SuggestionID uint       `gorm:"not null;index"`
+	CandidateID  uint       `gorm:"not null;index"`
+	Candidate    Suggestion `gorm:"foreignKey:CandidateID"`
+	Score        float64
+	CreatedAt    time.Time
+}
+
+// SLAPolicy defines the response deadlines, in working days, for a department and/or category.
+// Empty DepartmentID and Category act as wildcards; the most specific matching policy wins.
+type SLAPolicy struct {
+	ID             uint `gorm:"primaryKey"`
+	DepartmentID   *uint
+	Department     Department `gorm:"foreignKey:DepartmentID"`
+	Category       string
+	FirstReplyDays int `gorm:"not null"`
+	ResolutionDays int `gorm:"not null"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Holiday is an entry in the working-day calendar used for SLA calculation
+type Holiday struct {
+	ID        uint   `gorm:"primaryKey"`
+	Date      string `gorm:"unique;not null"` // "2006-01-02"
+	Name      string
+	IsWorkday bool `gorm:"default:false"` // true for a weekend day that is worked (调休)
 }
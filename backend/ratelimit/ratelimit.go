@@ -0,0 +1,70 @@
+// Package ratelimit provides request rate limiters with bounded memory.
+package ratelimit
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Result is the outcome of a rate limit check
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // Until the limit is fully available again
+	RetryAfter time.Duration // Until the next request may be allowed, when denied
+}
+
+// Limiter decides whether another request identified by key may proceed
+type Limiter interface {
+	Allow(key string) Result
+}
+
+// Policy allows Limit requests per Period
+type Policy struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParsePolicy parses a policy written as "<limit>/<period>", for example "3/1m" or "100/1h"
+func ParsePolicy(s string) (Policy, error) {
+	limit, period, found := strings.Cut(strings.TrimSpace(s), "/")
+	if !found {
+		return Policy{}, fmt.Errorf("rate limit %q must be <limit>/<period>", s)
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %q has an invalid limit", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %q has an invalid period", s)
+	}
+	return Policy{Limit: n, Period: d}, nil
+}
+
+const shardCount = 32
+
+// shardFor spreads keys over shards so that requests for different keys rarely wait on the same lock
+func shardFor(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % shardCount)
+}
+
+// evictEvery runs evict periodically until stop is closed
+func evictEvery(interval time.Duration, stop <-chan struct{}, evict func(now time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			evict(now)
+		case <-stop:
+			return
+		}
+	}
+}
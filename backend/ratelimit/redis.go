@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// incrScript increments a counter and sets its expiry when it is created, in one round trip
+const incrScript = `local v = redis.call('INCR', KEYS[1])
+if v == 1 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+return v`
+
+// incrUpToScript increments a counter unless it has reached ARGV[1], returning the counter
+// the increment led or would have led to
+const incrUpToScript = `local v = tonumber(redis.call('GET', KEYS[1]) or '0')
+if v >= tonumber(ARGV[1]) then return v + 1 end
+v = redis.call('INCR', KEYS[1])
+if v == 1 then redis.call('PEXPIRE', KEYS[1], ARGV[2]) end
+return v`
+
+const redisTimeout = time.Second
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// RedisStore keeps counters in Redis (or a compatible server), so that limits are shared
+// by every server behind a load balancer. It speaks the RESP protocol directly.
+type RedisStore struct {
+	addr     string
+	password string
+	pool     chan *redisConn
+}
+
+// NewRedisStore creates a store for the Redis server at addr, such as "127.0.0.1:6379"
+func NewRedisStore(addr, password string) *RedisStore {
+	return &RedisStore{addr: addr, password: password, pool: make(chan *redisConn, 8)}
+}
+
+// Incr increments the counter of key
+func (s *RedisStore) Incr(key string, ttl time.Duration) (int64, error) {
+	reply, err := s.do("EVAL", incrScript, "1", key, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return 0, err
+	}
+	value, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected redis reply %v", reply)
+	}
+	return value, nil
+}
+
+// IncrUpTo increments the counter of key unless it has reached max
+func (s *RedisStore) IncrUpTo(key string, max int64, ttl time.Duration) (int64, error) {
+	reply, err := s.do("EVAL", incrUpToScript, "1", key, strconv.FormatInt(max, 10), strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return 0, err
+	}
+	value, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected redis reply %v", reply)
+	}
+	return value, nil
+}
+
+// Get returns the counter of key
+func (s *RedisStore) Get(key string) (int64, error) {
+	reply, err := s.do("GET", key)
+	if err != nil || reply == nil {
+		return 0, err
+	}
+	return strconv.ParseInt(reply.(string), 10, 64)
+}
+
+func (s *RedisStore) connect() (*redisConn, error) {
+	select {
+	case c := <-s.pool:
+		return c, nil
+	default:
+	}
+
+	conn, err := net.DialTimeout("tcp", s.addr, redisTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+	if s.password != "" {
+		if _, err := c.command("AUTH", s.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (s *RedisStore) do(args ...string) (interface{}, error) {
+	c, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.command(args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// The connection is in an unknown state
+		c.conn.Close()
+		return nil, err
+	}
+
+	select {
+	case s.pool <- c:
+	default:
+		c.conn.Close()
+	}
+	return reply, err
+}
+
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// command sends a command and reads its reply: string, int64, nil or a redisError
+func (c *redisConn) command(args ...string) (interface{}, error) {
+	c.conn.SetDeadline(time.Now().Add(redisTimeout))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		return nil, err
+	}
+
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	default:
+		return nil, fmt.Errorf("redis: unsupported reply %q", line)
+	}
+}
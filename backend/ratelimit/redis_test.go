@@ -0,0 +1,238 @@
+package ratelimit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a stand-in Redis server that understands the few commands RedisStore sends
+type fakeRedis struct {
+	password string
+
+	mu       sync.Mutex
+	values   map[string]int64
+	ttls     map[string]string
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{password: password, values: map[string]int64{}, ttls: map[string]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, ln.Addr().String()
+}
+
+// readCommand reads a command sent as a RESP array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authenticated := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args[0])
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			if len(args) == 2 && args[1] == f.password {
+				authenticated = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "EVAL" && len(args) == 5 && args[2] == "1":
+			// Runs the increment script the way Redis would
+			if !strings.Contains(args[1], "INCR") || !strings.Contains(args[1], "PEXPIRE") {
+				reply = "-ERR unknown script\r\n"
+				break
+			}
+			f.values[args[3]]++
+			if f.values[args[3]] == 1 {
+				f.ttls[args[3]] = args[4]
+			}
+			reply = fmt.Sprintf(":%d\r\n", f.values[args[3]])
+		case args[0] == "EVAL" && len(args) == 6 && args[2] == "1":
+			// Runs the bounded increment script the way Redis would
+			if !strings.Contains(args[1], "GET") || !strings.Contains(args[1], "INCR") || !strings.Contains(args[1], "PEXPIRE") {
+				reply = "-ERR unknown script\r\n"
+				break
+			}
+			max, _ := strconv.ParseInt(args[4], 10, 64)
+			if f.values[args[3]] >= max {
+				reply = fmt.Sprintf(":%d\r\n", f.values[args[3]]+1)
+				break
+			}
+			f.values[args[3]]++
+			if f.values[args[3]] == 1 {
+				f.ttls[args[3]] = args[5]
+			}
+			reply = fmt.Sprintf(":%d\r\n", f.values[args[3]])
+		case args[0] == "GET" && len(args) == 2:
+			if v, ok := f.values[args[1]]; ok {
+				s := strconv.FormatInt(v, 10)
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+			} else {
+				reply = "$-1\r\n"
+			}
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+		conn.Write([]byte(reply))
+	}
+}
+
+func TestRedisStoreIncrAndGet(t *testing.T) {
+	fake, addr := newFakeRedis(t, "s3cret")
+	s := NewRedisStore(addr, "s3cret")
+
+	if v, err := s.Get("k"); err != nil || v != 0 {
+		t.Fatalf("Get of a missing key = %d, %v, want 0", v, err)
+	}
+	for want := int64(1); want <= 3; want++ {
+		if v, err := s.Incr("k", 90*time.Second); err != nil || v != want {
+			t.Fatalf("Incr = %d, %v, want %d", v, err, want)
+		}
+	}
+	if v, err := s.Get("k"); err != nil || v != 3 {
+		t.Fatalf("Get = %d, %v, want 3", v, err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.ttls["k"] != "90000" {
+		t.Errorf("expiry = %q ms, want 90000", fake.ttls["k"])
+	}
+	// Pooled connections authenticate once
+	auths := 0
+	for _, c := range fake.commands {
+		if c == "AUTH" {
+			auths++
+		}
+	}
+	if auths != 1 {
+		t.Errorf("sent AUTH %d times, want once", auths)
+	}
+}
+
+func TestRedisStoreIncrUpTo(t *testing.T) {
+	fake, addr := newFakeRedis(t, "")
+	s := NewRedisStore(addr, "")
+
+	for want := int64(1); want <= 2; want++ {
+		if v, err := s.IncrUpTo("k", 2, time.Minute); err != nil || v != want {
+			t.Fatalf("IncrUpTo = %d, %v, want %d", v, err, want)
+		}
+	}
+	if v, err := s.IncrUpTo("k", 2, time.Minute); err != nil || v != 3 {
+		t.Fatalf("IncrUpTo at the maximum = %d, %v, want 3", v, err)
+	}
+	if v, _ := s.Get("k"); v != 2 {
+		t.Errorf("counter = %d after an increment over the maximum, want 2", v)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.ttls["k"] != "60000" {
+		t.Errorf("expiry = %q ms, want 60000", fake.ttls["k"])
+	}
+}
+
+func TestRedisStoreErrors(t *testing.T) {
+	_, addr := newFakeRedis(t, "s3cret")
+	if _, err := NewRedisStore(addr, "wrong").Incr("k", time.Minute); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Incr with a wrong password: err = %v, want WRONGPASS", err)
+	}
+	if _, err := NewRedisStore(addr, "").Incr("k", time.Minute); err == nil || !strings.Contains(err.Error(), "NOAUTH") {
+		t.Errorf("Incr without a password: err = %v, want NOAUTH", err)
+	}
+}
+
+func TestSlidingWindowOverRedis(t *testing.T) {
+	_, addr := newFakeRedis(t, "")
+	sw := NewSlidingWindow(Policy{Limit: 2, Period: time.Hour}, NewRedisStore(addr, ""), "test")
+	for i := 0; i < 2; i++ {
+		if r := sw.Allow("key"); !r.Allowed {
+			t.Fatalf("request %d was denied", i+1)
+		}
+	}
+	if r := sw.Allow("key"); r.Allowed {
+		t.Error("request over the limit was allowed")
+	}
+}
+
+func TestSlidingWindowOverRedisConcurrent(t *testing.T) {
+	_, addr := newFakeRedis(t, "")
+	sw := NewSlidingWindow(Policy{Limit: 5, Period: time.Hour}, NewRedisStore(addr, ""), "test")
+	if allowed := allowConcurrently(sw, 40); allowed != 5 {
+		t.Errorf("%d of 40 concurrent requests were allowed, want 5", allowed)
+	}
+}
+
+func TestSlidingWindowOverRedisFailsOpen(t *testing.T) {
+	// Nothing listens on the port of a closed listener
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	sw := NewSlidingWindow(Policy{Limit: 1, Period: time.Minute}, NewRedisStore(addr, ""), "test")
+	for i := 0; i < 3; i++ {
+		if r := sw.Allow("key"); !r.Allowed {
+			t.Fatal("request denied while Redis is down")
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// SlidingWindow approximates a sliding window by weighting the count of the previous fixed window
+// by how much of it still overlaps the sliding window. Counters live in a Store, so several
+// servers can share limits; two counters per key keep memory bounded.
+type SlidingWindow struct {
+	policy Policy
+	store  Store
+	prefix string
+}
+
+// NewSlidingWindow creates a sliding window limiter keeping its counters in store under prefix
+func NewSlidingWindow(policy Policy, store Store, prefix string) *SlidingWindow {
+	return &SlidingWindow{policy: policy, store: store, prefix: prefix}
+}
+
+// Allow counts a request for key if the estimated count in the sliding window is below the limit
+func (sw *SlidingWindow) Allow(key string) Result {
+	now := time.Now()
+	period := sw.policy.Period
+	window := now.UnixNano() / int64(period)
+	elapsed := time.Duration(now.UnixNano() - window*int64(period))
+	untilNextWindow := period - elapsed
+	overlap := 1 - float64(elapsed)/float64(period)
+
+	counterKey := func(w int64) string {
+		return sw.prefix + ":" + key + ":" + strconv.FormatInt(w, 10)
+	}
+
+	previous, err := sw.store.Get(counterKey(window - 1))
+	if err != nil {
+		log.Println("Rate limit store unavailable, allowing request:", err)
+		return Result{Allowed: true, Limit: sw.policy.Limit, Remaining: sw.policy.Limit}
+	}
+
+	// The previous window no longer changes, so the room it leaves in the current window is known.
+	// The store checks and counts the request in one step, concurrent requests cannot overshoot the limit.
+	limit := float64(sw.policy.Limit)
+	room := int64(math.Floor(limit - float64(previous)*overlap))
+	count, err := sw.store.IncrUpTo(counterKey(window), room, 2*period)
+	if err != nil {
+		log.Println("Rate limit store unavailable, allowing request:", err)
+		return Result{Allowed: true, Limit: sw.policy.Limit, Remaining: sw.policy.Limit}
+	}
+
+	result := Result{Limit: sw.policy.Limit}
+	if count > room {
+		// The request was not counted
+		current := count - 1
+		if float64(current)+1 <= limit {
+			// The previous window's weight decreases until the request fits
+			needed := (limit - 1 - float64(current)) / float64(previous)
+			result.RetryAfter = time.Duration((overlap - needed) * float64(period))
+		} else {
+			// The current window becomes the previous one and has to fade enough
+			result.RetryAfter = untilNextWindow + time.Duration((1-(limit-1)/float64(current))*float64(period))
+		}
+		result.Reset = sw.reset(previous, current, untilNextWindow)
+		return result
+	}
+
+	result.Allowed = true
+	result.Remaining = max(int(limit-(float64(previous)*overlap+float64(count))), 0)
+	result.Reset = sw.reset(previous, count, untilNextWindow)
+	return result
+}
+
+// reset is the time until no counted request weighs on the sliding window anymore
+func (sw *SlidingWindow) reset(previous, current int64, untilNextWindow time.Duration) time.Duration {
+	switch {
+	case current > 0:
+		return untilNextWindow + sw.policy.Period
+	case previous > 0:
+		return untilNextWindow
+	default:
+		return 0
+	}
+}
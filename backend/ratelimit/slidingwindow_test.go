@@ -0,0 +1,169 @@
+package ratelimit
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingStore is a Store whose server is down
+type failingStore struct{}
+
+func (failingStore) Incr(key string, ttl time.Duration) (int64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func (failingStore) IncrUpTo(key string, max int64, ttl time.Duration) (int64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func (failingStore) Get(key string) (int64, error) { return 0, errors.New("connection refused") }
+
+func newTestStore(t *testing.T) *MemoryStore {
+	s := NewMemoryStore()
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func TestSlidingWindowLimit(t *testing.T) {
+	period := time.Hour
+	sw := NewSlidingWindow(Policy{Limit: 3, Period: period}, newTestStore(t), "test")
+
+	for i := 0; i < 3; i++ {
+		r := sw.Allow("1.2.3.4")
+		if !r.Allowed || r.Remaining != 2-i {
+			t.Fatalf("request %d: allowed %v, remaining %d, want allowed with %d remaining", i+1, r.Allowed, r.Remaining, 2-i)
+		}
+	}
+
+	r := sw.Allow("1.2.3.4")
+	if r.Allowed || r.Remaining != 0 {
+		t.Fatalf("request over the limit: allowed %v, remaining %d", r.Allowed, r.Remaining)
+	}
+	// The current window has to end, then fade to two thirds of its weight
+	untilNextWindow := period - time.Duration(time.Now().UnixNano()%int64(period))
+	want := untilNextWindow + period/3
+	if diff := r.RetryAfter - want; diff < -time.Second || diff > time.Second {
+		t.Errorf("RetryAfter = %v, want about %v", r.RetryAfter, want)
+	}
+	if r.Reset < r.RetryAfter || r.Reset > untilNextWindow+period+time.Second {
+		t.Errorf("Reset = %v, want between RetryAfter and %v", r.Reset, untilNextWindow+period)
+	}
+
+	if r := sw.Allow("5.6.7.8"); !r.Allowed {
+		t.Error("another key was limited")
+	}
+}
+
+func TestSlidingWindowWeighsPreviousWindow(t *testing.T) {
+	// Windows start at multiples of the period, so pick one that has only just started
+	now := time.Now()
+	period := time.Hour
+	for now.UnixNano()%int64(period) > int64(period)/5 {
+		period += 7 * time.Minute
+	}
+	store := newTestStore(t)
+	sw := NewSlidingWindow(Policy{Limit: 4, Period: period}, store, "test")
+
+	window := now.UnixNano() / int64(period)
+	for i := 0; i < 4; i++ {
+		store.Incr("test:key:"+strconv.FormatInt(window-1, 10), 2*period)
+	}
+	overlap := 1 - float64(now.UnixNano()-window*int64(period))/float64(period)
+
+	r := sw.Allow("key")
+	if r.Allowed {
+		t.Fatal("request allowed although the previous window was full")
+	}
+	// The previous window has to fade until three of its requests are left
+	want := time.Duration((overlap - 0.75) * float64(period))
+	if diff := r.RetryAfter - want; diff < -time.Second || diff > time.Second {
+		t.Errorf("RetryAfter = %v, want about %v", r.RetryAfter, want)
+	}
+	if r.Reset <= 0 || r.Reset > period {
+		t.Errorf("Reset = %v, want until the end of the current window", r.Reset)
+	}
+}
+
+// allowConcurrently sends n requests for one key at once and returns how many were allowed
+func allowConcurrently(sw *SlidingWindow, n int) int {
+	var wg sync.WaitGroup
+	var allowed atomic.Int64
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if sw.Allow("key").Allowed {
+				allowed.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return int(allowed.Load())
+}
+
+func TestSlidingWindowConcurrent(t *testing.T) {
+	sw := NewSlidingWindow(Policy{Limit: 10, Period: time.Hour}, newTestStore(t), "test")
+	if allowed := allowConcurrently(sw, 200); allowed != 10 {
+		t.Errorf("%d of 200 concurrent requests were allowed, want 10", allowed)
+	}
+}
+
+func TestSlidingWindowFailsOpen(t *testing.T) {
+	sw := NewSlidingWindow(Policy{Limit: 1, Period: time.Minute}, failingStore{}, "test")
+	for i := 0; i < 3; i++ {
+		if r := sw.Allow("key"); !r.Allowed {
+			t.Fatal("request denied while the store is unavailable")
+		}
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	s := newTestStore(t)
+	if v, _ := s.Incr("a", 20*time.Millisecond); v != 1 {
+		t.Fatalf("first Incr = %d, want 1", v)
+	}
+	if v, _ := s.Incr("a", 20*time.Millisecond); v != 2 {
+		t.Fatalf("second Incr = %d, want 2", v)
+	}
+	if v, _ := s.Get("a"); v != 2 {
+		t.Fatalf("Get = %d, want 2", v)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if v, _ := s.Get("a"); v != 0 {
+		t.Errorf("Get of an expired counter = %d, want 0", v)
+	}
+	s.Incr("b", time.Hour)
+	s.evict(time.Now())
+	if _, ok := s.shards[shardFor("a")].counters["a"]; ok {
+		t.Error("expired counter was not evicted")
+	}
+	if v, _ := s.Get("b"); v != 1 {
+		t.Errorf("live counter = %d after eviction, want 1", v)
+	}
+	if v, _ := s.Incr("a", time.Hour); v != 1 {
+		t.Errorf("Incr after expiry = %d, want 1", v)
+	}
+}
+
+func TestMemoryStoreIncrUpTo(t *testing.T) {
+	s := newTestStore(t)
+	for want := int64(1); want <= 2; want++ {
+		if v, _ := s.IncrUpTo("a", 2, time.Hour); v != want {
+			t.Fatalf("IncrUpTo = %d, want %d", v, want)
+		}
+	}
+	if v, _ := s.IncrUpTo("a", 2, time.Hour); v != 3 {
+		t.Fatalf("IncrUpTo at the maximum = %d, want 3", v)
+	}
+	if v, _ := s.Get("a"); v != 2 {
+		t.Errorf("counter = %d after an increment over the maximum, want 2", v)
+	}
+}
@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Store keeps expiring counters, possibly shared between servers
+type Store interface {
+	// Incr increments the counter of key, which expires ttl after it was created
+	Incr(key string, ttl time.Duration) (int64, error)
+	// IncrUpTo increments the counter of key unless it has reached max, checking and incrementing
+	// in one atomic step. It returns the counter the increment led or would have led to,
+	// so the increment happened when the result is at most max.
+	IncrUpTo(key string, max int64, ttl time.Duration) (int64, error)
+	// Get returns the counter of key, or 0 when it does not exist
+	Get(key string) (int64, error)
+}
+
+type counter struct {
+	value   int64
+	expires time.Time
+}
+
+type counterShard struct {
+	sync.Mutex
+	counters map[string]*counter
+}
+
+// MemoryStore is an in-process Store. It stands in for a shared store on a single server,
+// and expired counters are evicted in the background.
+type MemoryStore struct {
+	shards [shardCount]counterShard
+	stop   chan struct{}
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{stop: make(chan struct{})}
+	for i := range s.shards {
+		s.shards[i].counters = map[string]*counter{}
+	}
+	go evictEvery(time.Minute, s.stop, s.evict)
+	return s
+}
+
+// Incr increments the counter of key
+func (s *MemoryStore) Incr(key string, ttl time.Duration) (int64, error) {
+	shard := &s.shards[shardFor(key)]
+	shard.Lock()
+	defer shard.Unlock()
+
+	now := time.Now()
+	c, ok := shard.counters[key]
+	if !ok || now.After(c.expires) {
+		c = &counter{expires: now.Add(ttl)}
+		shard.counters[key] = c
+	}
+	c.value++
+	return c.value, nil
+}
+
+// IncrUpTo increments the counter of key unless it has reached max
+func (s *MemoryStore) IncrUpTo(key string, max int64, ttl time.Duration) (int64, error) {
+	shard := &s.shards[shardFor(key)]
+	shard.Lock()
+	defer shard.Unlock()
+
+	now := time.Now()
+	c, ok := shard.counters[key]
+	if !ok || now.After(c.expires) {
+		c = &counter{expires: now.Add(ttl)}
+		shard.counters[key] = c
+	}
+	if c.value >= max {
+		return c.value + 1, nil
+	}
+	c.value++
+	return c.value, nil
+}
+
+// Get returns the counter of key
+func (s *MemoryStore) Get(key string) (int64, error) {
+	shard := &s.shards[shardFor(key)]
+	shard.Lock()
+	defer shard.Unlock()
+
+	c, ok := shard.counters[key]
+	if !ok || time.Now().After(c.expires) {
+		return 0, nil
+	}
+	return c.value, nil
+}
+
+func (s *MemoryStore) evict(now time.Time) {
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.Lock()
+		for key, c := range shard.counters {
+			if now.After(c.expires) {
+				delete(shard.counters, key)
+			}
+		}
+		shard.Unlock()
+	}
+}
+
+// Stop ends background eviction
+func (s *MemoryStore) Stop() {
+	close(s.stop)
+}
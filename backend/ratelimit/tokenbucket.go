@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type bucketShard struct {
+	sync.Mutex
+	buckets map[string]*bucket
+}
+
+// TokenBucket allows bursts of up to Limit requests, refilled evenly over Period.
+// Buckets that have refilled completely are evicted in the background.
+type TokenBucket struct {
+	policy Policy
+	rate   float64 // Tokens per second
+	shards [shardCount]bucketShard
+	stop   chan struct{}
+}
+
+// NewTokenBucket creates an in-memory token bucket limiter
+func NewTokenBucket(policy Policy) *TokenBucket {
+	tb := &TokenBucket{
+		policy: policy,
+		rate:   float64(policy.Limit) / policy.Period.Seconds(),
+		stop:   make(chan struct{}),
+	}
+	for i := range tb.shards {
+		tb.shards[i].buckets = map[string]*bucket{}
+	}
+	go evictEvery(max(policy.Period, time.Minute), tb.stop, tb.evict)
+	return tb
+}
+
+// Allow takes a token from the bucket of key
+func (tb *TokenBucket) Allow(key string) Result {
+	shard := &tb.shards[shardFor(key)]
+	shard.Lock()
+	defer shard.Unlock()
+
+	now := time.Now()
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(tb.policy.Limit), last: now}
+		shard.buckets[key] = b
+	}
+	b.tokens = min(float64(tb.policy.Limit), b.tokens+now.Sub(b.last).Seconds()*tb.rate)
+	b.last = now
+
+	result := Result{Limit: tb.policy.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = tb.secondsToDuration((1 - b.tokens) / tb.rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = tb.secondsToDuration((float64(tb.policy.Limit) - b.tokens) / tb.rate)
+	return result
+}
+
+func (tb *TokenBucket) secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// evict drops the buckets that are full again, as a new bucket behaves the same
+func (tb *TokenBucket) evict(now time.Time) {
+	for i := range tb.shards {
+		shard := &tb.shards[i]
+		shard.Lock()
+		for key, b := range shard.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*tb.rate >= float64(tb.policy.Limit) {
+				delete(shard.buckets, key)
+			}
+		}
+		shard.Unlock()
+	}
+}
+
+// Stop ends background eviction
+func (tb *TokenBucket) Stop() {
+	close(tb.stop)
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketRefill(t *testing.T) {
+	period := 200 * time.Millisecond
+	tb := NewTokenBucket(Policy{Limit: 2, Period: period})
+	t.Cleanup(tb.Stop)
+
+	for i := 0; i < 2; i++ {
+		if r := tb.Allow("key"); !r.Allowed {
+			t.Fatalf("request %d of the burst was denied", i+1)
+		}
+	}
+	r := tb.Allow("key")
+	if r.Allowed {
+		t.Fatal("request over the burst was allowed")
+	}
+	// One token comes back every half period
+	if r.RetryAfter <= 0 || r.RetryAfter > period/2 {
+		t.Errorf("RetryAfter = %v, want at most %v", r.RetryAfter, period/2)
+	}
+	if r.Reset < r.RetryAfter || r.Reset > period {
+		t.Errorf("Reset = %v, want between RetryAfter and %v", r.Reset, period)
+	}
+
+	time.Sleep(r.RetryAfter + 10*time.Millisecond)
+	if r := tb.Allow("key"); !r.Allowed {
+		t.Error("request denied after a token was refilled")
+	}
+	if r := tb.Allow("key"); r.Allowed {
+		t.Error("more than one token was refilled")
+	}
+}
+
+func TestTokenBucketEviction(t *testing.T) {
+	tb := NewTokenBucket(Policy{Limit: 5, Period: time.Minute})
+	t.Cleanup(tb.Stop)
+	tb.Allow("key")
+	shard := &tb.shards[shardFor("key")]
+
+	tb.evict(time.Now())
+	if _, ok := shard.buckets["key"]; !ok {
+		t.Fatal("a bucket that is not full yet was evicted")
+	}
+
+	tb.evict(time.Now().Add(time.Minute))
+	if _, ok := shard.buckets["key"]; ok {
+		t.Fatal("a refilled bucket was not evicted")
+	}
+	if r := tb.Allow("key"); !r.Allowed || r.Remaining != 4 {
+		t.Errorf("after eviction: allowed %v, remaining %d, want a full bucket", r.Allowed, r.Remaining)
+	}
+}
@@ -3,6 +3,8 @@ package router
 import (
 	"advice/handlers"
 	"advice/middleware"
+	"advice/utils"
+	"log"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -13,11 +15,22 @@ import (
 func SetupRouter() *gin.Engine {
 	r := gin.Default()
 
+	// Client IPs used by rate limiting, request logs and login attempts are only taken from
+	// forwarding headers when the request comes through a trusted proxy
+	r.RemoteIPHeaders = utils.GetenvList("CLIENT_IP_HEADERS", []string{"X-Forwarded-For", "X-Real-IP"})
+	trustedProxies := utils.GetenvList("TRUSTED_PROXIES", []string{"127.0.0.1", "::1"})
+	if len(trustedProxies) == 1 && trustedProxies[0] == "none" {
+		trustedProxies = nil
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+
 	// CORS Middleware
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"http://localhost:5173"} // Adjust for your frontend URL
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "X-Submitter-Key"}
 	r.Use(cors.New(config))
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -26,27 +39,94 @@ func SetupRouter() *gin.Engine {
 	api := r.Group("/api/v1")
 	{
 		// Student facing routes
-		api.GET("/departments", handlers.GetDepartments)                              // Public endpoint for departments
-		api.POST("/suggestions", middleware.RateLimiter(), handlers.SubmitSuggestion) // Submit a new suggestion
-		api.GET("/suggestions/:tracking_code", handlers.GetSuggestionByTrackingCode)  // Get suggestion status by tracking code
-		api.GET("/suggestions", handlers.GetPublicSuggestions)                        // Get all public suggestions
-		api.POST("/suggestions/:id/upvote", handlers.UpvoteSuggestion)                // Upvote a suggestion
+		voteLimit := middleware.RateLimit("upvote", middleware.ByIP) // Shared by upvotes and reactions
+		lookupLimit := middleware.RateLimit("lookup", middleware.ByIP)
+		submissionLimit := middleware.RateLimit("submission", middleware.ByIP)
+
+		api.GET("/departments", handlers.GetDepartments)                                                                // Public endpoint for departments
+		api.GET("/categories", handlers.GetCategories)                                                                  // Public endpoint for active categories
+		api.GET("/challenge", handlers.GetChallenge)                                                                    // Get a challenge to solve before submitting
+		api.POST("/suggestions", submissionLimit, middleware.OptionalStudentAuth(), handlers.SubmitSuggestion)          // Submit a new suggestion, verified when a student is logged in
+		api.GET("/suggestions/:tracking_code", lookupLimit, handlers.GetSuggestionByTrackingCode)                       // Get suggestion status by tracking code
+		api.GET("/submitter/suggestions", lookupLimit, handlers.GetSubmitterSuggestions)                                // List the suggestions of a submitter key
+		api.GET("/suggestions", handlers.GetPublicSuggestions)                                                          // Get all public suggestions
+		api.GET("/suggestions/search", handlers.SearchPublicSuggestions)                                                // Search public suggestions
+		api.POST("/suggestions/similar", handlers.FindSimilarSuggestions)                                               // Find public suggestions similar to a draft
+		api.POST("/suggestions/:id/upvote", voteLimit, handlers.UpvoteSuggestion)                                       // Upvote a suggestion
+		api.DELETE("/suggestions/:id/upvote", voteLimit, handlers.RemoveUpvote)                                         // Withdraw an upvote
+		api.POST("/suggestions/:id/reactions", voteLimit, handlers.ReactToSuggestion)                                   // React with "me too" or "disagree"
+		api.DELETE("/suggestions/:id/reactions", voteLimit, handlers.RemoveReaction)                                    // Withdraw a reaction
+		api.GET("/comments", handlers.GetSuggestionComments)                                                            // Get approved comments of a public suggestion
+		api.POST("/suggestions/:id/comments", middleware.RateLimit("comment", middleware.ByIP), handlers.SubmitComment) // Post a comment for review
+		api.GET("/attachments/:id", handlers.GetPublicAttachment)                                                       // Download an attachment of a public suggestion
+
+		// Routes for the holder of a tracking code
+		tracking := api.Group("/tracking/:tracking_code")
+		{
+			tracking.PUT("", lookupLimit, handlers.EditSuggestion)
+			tracking.POST("/withdraw", lookupLimit, handlers.WithdrawSuggestion)
+			tracking.POST("/attachments", middleware.RateLimit("upload", middleware.ByTrackingCode), handlers.UploadSuggestionAttachments)
+			tracking.GET("/attachments/:attachment_id", lookupLimit, handlers.GetTrackedAttachment)
+			tracking.POST("/rating", lookupLimit, handlers.RateSuggestion)
+			tracking.POST("/reopen", lookupLimit, handlers.ReopenSuggestion)
+			tracking.GET("/unsubscribe", lookupLimit, handlers.ConfirmUnsubscribe)
+			tracking.POST("/unsubscribe", lookupLimit, handlers.Unsubscribe) // Confirmed or one-click unsubscribe from mail clients
+		}
+
+		// Optional student accounts, for verified suggestions
+		api.POST("/student/login", middleware.RateLimit("student_login", middleware.ByIP), handlers.StudentLogin)
+		student := api.Group("/student")
+		student.Use(middleware.StudentAuthMiddleware())
+		{
+			student.GET("/me", handlers.GetStudentProfile)
+			student.PUT("/password", handlers.ChangeStudentPassword)
+		}
 
 		// Admin routes
 		admin := api.Group("/admin")
 		{
-			admin.POST("/login", handlers.Login)
+			admin.POST("/login", middleware.RateLimit("login", middleware.ByIP), handlers.Login)
+			admin.GET("/sso/providers", handlers.GetSSOProviders)
+			admin.GET("/sso/oidc/login", handlers.OIDCLogin)
+			admin.GET("/sso/oidc/callback", middleware.RateLimit("login", middleware.ByIP), handlers.OIDCCallback)
+			admin.GET("/sso/cas/login", handlers.CASLogin)
+			admin.GET("/sso/cas/callback", middleware.RateLimit("login", middleware.ByIP), handlers.CASCallback)
+			admin.GET("/events", middleware.EventTicketAuth(), handlers.StreamEvents)
 
 			authed := admin.Group("/")
 			authed.Use(middleware.AuthMiddleware())
 			{
 				authed.GET("/dashboard/stats", handlers.GetDashboardStats)
+				authed.POST("/events/ticket", handlers.CreateEventTicket)
 				authed.GET("/suggestions", handlers.GetAllSuggestions)
+				authed.GET("/search", handlers.SearchSuggestions)
 				authed.GET("/suggestions/:id", handlers.GetSuggestionByID)
 				authed.PUT("/suggestions/:id/status", handlers.UpdateSuggestionStatus)
+				authed.PUT("/suggestions/:id/priority", handlers.UpdateSuggestionPriority)
 				authed.POST("/suggestions/:id/replies", handlers.AddReply)
+				authed.POST("/suggestions/:id/replies/:reply_id/attachments", middleware.RateLimit("upload", middleware.ByUser), handlers.UploadReplyAttachments)
+				authed.GET("/attachments/:id", handlers.GetAdminAttachment)
+				authed.POST("/attachments/:id/approve", handlers.ApproveAttachment)
+				authed.PUT("/suggestions/:id/assignee", handlers.AssignSuggestion)
+				authed.DELETE("/suggestions/:id/assignee", handlers.UnassignSuggestion)
+				authed.POST("/suggestions/:id/claim", handlers.ClaimSuggestion)
+				authed.PUT("/suggestions/:id/department", handlers.TransferSuggestion)
+				authed.GET("/suggestions/:id/duplicates", handlers.GetDuplicateCandidates)
+				authed.POST("/suggestions/:id/merge", handlers.MergeSuggestion)
 				authed.DELETE("/suggestions", handlers.DeleteSuggestions)
 
+				// Public comment moderation
+				authed.GET("/comments", handlers.GetAllComments)
+				authed.PUT("/comments/:id/status", handlers.ModerateComment)
+				authed.DELETE("/comments/:id", handlers.DeleteComment)
+
+				// Notification center of the current admin
+				authed.GET("/notifications", handlers.GetNotifications)
+				authed.PUT("/notifications/:id", handlers.MarkNotification)
+				authed.POST("/notifications/read-all", handlers.MarkAllNotificationsRead)
+				authed.GET("/notifications/preferences", handlers.GetNotificationPreferences)
+				authed.PUT("/notifications/preferences", handlers.UpdateNotificationPreferences)
+
 				super := authed.Group("/")
 				super.Use(middleware.SuperAdminMiddleware())
 				{
@@ -55,12 +135,60 @@ func SetupRouter() *gin.Engine {
 					super.POST("/users", handlers.CreateAdmin)
 					super.PUT("/users/:id", handlers.UpdateAdmin)
 					super.DELETE("/users/:id", handlers.DeleteAdmin)
+					super.POST("/users/:id/identities", handlers.LinkAdminIdentity)
+					super.DELETE("/users/:id/identities/:identity_id", handlers.UnlinkAdminIdentity)
+					super.GET("/workload", handlers.GetAdminWorkload)
+					super.GET("/login-attempts", handlers.GetLoginAttempts)
+
+					// Student accounts
+					super.GET("/students", handlers.GetStudents)
+					super.POST("/students/import", handlers.ImportStudents)
+					super.PUT("/students/:id", handlers.UpdateStudent)
 
 					// Department Management
 					super.GET("/departments", handlers.GetDepartments)
 					super.POST("/departments", handlers.CreateDepartment)
 					super.PUT("/departments/:id", handlers.UpdateDepartment)
 					super.DELETE("/departments/:id", handlers.DeleteDepartment)
+
+					// Category Management
+					super.GET("/categories", handlers.GetAllCategories)
+					super.POST("/categories", handlers.CreateCategory)
+					super.PUT("/categories/:id", handlers.UpdateCategory)
+					super.DELETE("/categories/:id", handlers.DeleteCategory)
+
+					// Routing Rule Management
+					super.GET("/routing-rules", handlers.GetRoutingRules)
+					super.POST("/routing-rules", handlers.CreateRoutingRule)
+					super.POST("/routing-rules/test", handlers.TestRoutingRules)
+					super.PUT("/routing-rules/:id", handlers.UpdateRoutingRule)
+					super.DELETE("/routing-rules/:id", handlers.DeleteRoutingRule)
+
+					// SLA Management
+					super.GET("/sla-policies", handlers.GetSLAPolicies)
+					super.POST("/sla-policies", handlers.CreateSLAPolicy)
+					super.PUT("/sla-policies/:id", handlers.UpdateSLAPolicy)
+					super.DELETE("/sla-policies/:id", handlers.DeleteSLAPolicy)
+					super.GET("/holidays", handlers.GetHolidays)
+					super.POST("/holidays", handlers.CreateHoliday)
+					super.DELETE("/holidays/:id", handlers.DeleteHoliday)
+
+					// Content Moderation
+					super.GET("/word-lists", handlers.GetWordLists)
+					super.POST("/word-lists", handlers.CreateWordList)
+					super.POST("/word-lists/test", handlers.TestModeration)
+					super.PUT("/word-lists/:id", handlers.UpdateWordList)
+					super.DELETE("/word-lists/:id", handlers.DeleteWordList)
+
+					// Outbound Webhooks
+					super.GET("/webhooks", handlers.GetWebhooks)
+					super.POST("/webhooks", handlers.CreateWebhook)
+					super.PUT("/webhooks/:id", handlers.UpdateWebhook)
+					super.DELETE("/webhooks/:id", handlers.DeleteWebhook)
+					super.POST("/webhooks/:id/test", handlers.TestWebhook)
+					super.POST("/webhooks/:id/rotate-secret", handlers.RotateWebhookSecret)
+					super.GET("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries)
+					super.POST("/webhooks/:id/deliveries/:delivery_id/replay", handlers.ReplayWebhookDelivery)
 				}
 			}
 		}
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/storage"
+	"advice/utils"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	_ "image/png" // Register the PNG decoder used by makeThumbnail
+	"io"
+	"log"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidAttachment is wrapped by errors caused by the uploaded file itself
+var ErrInvalidAttachment = errors.New("invalid attachment")
+
+const (
+	// MaxAttachmentsPerItem limits the attachments of a suggestion or reply
+	MaxAttachmentsPerItem = 5
+	thumbnailSize         = 320
+	maxImagePixels        = 40_000_000
+)
+
+// MaxAttachmentSize is the largest accepted file in bytes, configured by ATTACHMENT_MAX_SIZE_MB
+var MaxAttachmentSize = int64(utils.GetenvInt("ATTACHMENT_MAX_SIZE_MB", 10)) << 20
+
+type fileType struct {
+	contentType string
+	extensions  []string
+	isImage     bool
+}
+
+var (
+	jpegType = fileType{"image/jpeg", []string{".jpg", ".jpeg"}, true}
+	pngType  = fileType{"image/png", []string{".png"}, true}
+	gifType  = fileType{"image/gif", []string{".gif"}, true}
+	pdfType  = fileType{"application/pdf", []string{".pdf"}, false}
+)
+
+// officeTypes share a container format, so the extension decides the exact type
+var officeTypes = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".doc":  "application/msword",
+	".xls":  "application/vnd.ms-excel",
+	".ppt":  "application/vnd.ms-powerpoint",
+}
+
+// detectFileType identifies an allowed file type from its magic bytes and checks the extension agrees
+func detectFileType(data []byte, fileName string) (fileType, error) {
+	ext := strings.ToLower(filepath.Ext(fileName))
+
+	var detected fileType
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		detected = jpegType
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		detected = pngType
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		detected = gifType
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		detected = pdfType
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")) && (ext == ".docx" || ext == ".xlsx" || ext == ".pptx"):
+		detected = fileType{officeTypes[ext], []string{ext}, false}
+	case bytes.HasPrefix(data, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}) && (ext == ".doc" || ext == ".xls" || ext == ".ppt"):
+		detected = fileType{officeTypes[ext], []string{ext}, false}
+	default:
+		return fileType{}, fmt.Errorf("%w: unsupported file type", ErrInvalidAttachment)
+	}
+
+	for _, allowed := range detected.extensions {
+		if ext == allowed {
+			return detected, nil
+		}
+	}
+	return fileType{}, fmt.Errorf("%w: file extension does not match its content", ErrInvalidAttachment)
+}
+
+// stripJPEGMetadata removes EXIF, XMP and other application segments and comments from a JPEG
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2]) // SOI
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, errors.New("malformed JPEG segment")
+		}
+		marker := data[i+1]
+		if marker == 0xDA {
+			// Start of scan, the compressed image data follows
+			out.Write(data[i:])
+			return out.Bytes(), nil
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, errors.New("malformed JPEG segment")
+		}
+		// Keep JFIF (APP0) and Adobe (APP14, affects colors), drop other APPn segments and comments
+		isMetadata := (marker >= 0xE1 && marker <= 0xEF && marker != 0xEE) || marker == 0xFE
+		if !isMetadata {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return nil, errors.New("JPEG has no image data")
+}
+
+// stripPNGMetadata removes text, EXIF and timestamp chunks from a PNG
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:8]) // Signature
+
+	i := 8
+	for i+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errors.New("malformed PNG chunk")
+		}
+		switch string(data[i+4 : i+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), nil
+}
+
+// stripGIFMetadata re-encodes a GIF, dropping comment and application extensions
+func stripGIFMetadata(data []byte) ([]byte, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := gif.EncodeAll(&out, g); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// stripImageMetadata removes metadata such as GPS location and camera details from an image
+func stripImageMetadata(data []byte, ft fileType) ([]byte, error) {
+	switch ft.contentType {
+	case jpegType.contentType:
+		return stripJPEGMetadata(data)
+	case pngType.contentType:
+		return stripPNGMetadata(data)
+	case gifType.contentType:
+		return stripGIFMetadata(data)
+	}
+	return data, nil
+}
+
+// makeThumbnail scales an image down to fit a thumbnailSize square and encodes it as JPEG
+func makeThumbnail(data []byte) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, fmt.Errorf("%w: image dimensions are too large", ErrInvalidAttachment)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return nil, errors.New("empty image")
+	}
+	tw, th := w, h
+	if w > thumbnailSize || h > thumbnailSize {
+		if w >= h {
+			tw, th = thumbnailSize, max(1, h*thumbnailSize/w)
+		} else {
+			tw, th = max(1, w*thumbnailSize/h), thumbnailSize
+		}
+	}
+
+	// Box filter: average the source pixels covered by each thumbnail pixel
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+max((x+1)*w/tw, x*w/tw+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func randomKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// SaveAttachment validates an uploaded file, strips image metadata, stores it with a thumbnail
+// for images, and records it for the suggestion or, when replyID is set, for the reply
+func SaveAttachment(header *multipart.FileHeader, suggestionID uint, replyID *uint, uploaderID *uint) (*models.Attachment, error) {
+	if header.Size > MaxAttachmentSize {
+		return nil, fmt.Errorf("%w: %s exceeds the %d MB limit", ErrInvalidAttachment, header.Filename, MaxAttachmentSize>>20)
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, MaxAttachmentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > MaxAttachmentSize {
+		return nil, fmt.Errorf("%w: %s exceeds the %d MB limit", ErrInvalidAttachment, header.Filename, MaxAttachmentSize>>20)
+	}
+
+	ft, err := detectFileType(data, header.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var thumbnail []byte
+	if ft.isImage {
+		if data, err = stripImageMetadata(data, ft); err != nil {
+			return nil, fmt.Errorf("%w: %s is not a valid image", ErrInvalidAttachment, header.Filename)
+		}
+		if thumbnail, err = makeThumbnail(data); err != nil {
+			if errors.Is(err, ErrInvalidAttachment) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %s is not a valid image", ErrInvalidAttachment, header.Filename)
+		}
+	}
+
+	base := fmt.Sprintf("attachments/%d/%s", suggestionID, randomKey())
+	attachment := models.Attachment{
+		SuggestionID: suggestionID,
+		ReplyID:      replyID,
+		FileName:     filepath.Base(header.Filename),
+		ContentType:  ft.contentType,
+		Size:         int64(len(data)),
+		StorageKey:   base + ft.extensions[0],
+		UploaderID:   uploaderID,
+		// Files uploaded by admins need no review
+		IsApproved: uploaderID != nil,
+	}
+	if err := storage.Default.Put(attachment.StorageKey, bytes.NewReader(data), attachment.Size, ft.contentType); err != nil {
+		return nil, err
+	}
+	if thumbnail != nil {
+		attachment.ThumbnailKey = base + "_thumb.jpg"
+		if err := storage.Default.Put(attachment.ThumbnailKey, bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/jpeg"); err != nil {
+			storage.Default.Delete(attachment.StorageKey)
+			return nil, err
+		}
+		attachment.HasThumbnail = true
+	}
+
+	if err := database.DB.Create(&attachment).Error; err != nil {
+		deleteStoredFiles(&attachment)
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func deleteStoredFiles(attachment *models.Attachment) {
+	if err := storage.Default.Delete(attachment.StorageKey); err != nil {
+		log.Printf("Failed to delete attachment file %s: %v", attachment.StorageKey, err)
+	}
+	if attachment.ThumbnailKey != "" {
+		if err := storage.Default.Delete(attachment.ThumbnailKey); err != nil {
+			log.Printf("Failed to delete attachment thumbnail %s: %v", attachment.ThumbnailKey, err)
+		}
+	}
+}
+
+// DeleteAttachment removes an attachment and its files
+func DeleteAttachment(attachment *models.Attachment) error {
+	if err := database.DB.Delete(attachment).Error; err != nil {
+		return err
+	}
+	deleteStoredFiles(attachment)
+	return nil
+}
+
+// DeleteAttachments removes the attachments of the given suggestions, including their files
+func DeleteAttachments(suggestionIDs []uint) error {
+	var attachments []models.Attachment
+	if err := database.DB.Where("suggestion_id IN ?", suggestionIDs).Find(&attachments).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Where("suggestion_id IN ?", suggestionIDs).Delete(&models.Attachment{}).Error; err != nil {
+		return err
+	}
+	for i := range attachments {
+		deleteStoredFiles(&attachments[i])
+	}
+	return nil
+}
+
+// ApproveSubmittedAttachments approves the student's attachments of a suggestion that passed review
+func ApproveSubmittedAttachments(suggestionID uint) error {
+	return database.DB.Model(&models.Attachment{}).
+		Where("suggestion_id = ? AND uploader_id IS NULL", suggestionID).
+		Update("is_approved", true).Error
+}
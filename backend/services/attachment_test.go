@@ -0,0 +1,325 @@
+package services
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/storage"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryStorage keeps stored files in memory
+type memoryStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (s *memoryStorage) Put(key string, r io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[key] = data
+	return nil
+}
+
+func (s *memoryStorage) Get(key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, key)
+	return nil
+}
+
+func useMemoryStorage(t *testing.T) *memoryStorage {
+	previous := storage.Default
+	mem := &memoryStorage{files: map[string][]byte{}}
+	storage.Default = mem
+	t.Cleanup(func() { storage.Default = previous })
+	return mem
+}
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	return img
+}
+
+// jpegWithExif encodes a JPEG and inserts an APP1 EXIF segment and a comment after the SOI marker
+func jpegWithExif(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(w, h), nil); err != nil {
+		t.Fatal(err)
+	}
+	segment := func(marker byte, payload string) []byte {
+		s := []byte{0xFF, marker, 0, 0}
+		binary.BigEndian.PutUint16(s[2:], uint16(len(payload)+2))
+		return append(s, payload...)
+	}
+	data := buf.Bytes()
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment(0xE1, "Exif\x00\x00GPSLatitude 31.2")...)
+	out = append(out, segment(0xFE, "taken by phone")...)
+	return append(out, data[2:]...)
+}
+
+func pngChunk(kind string, payload []byte) []byte {
+	chunk := make([]byte, 8, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	copy(chunk[4:], kind)
+	chunk = append(chunk, payload...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(chunk[4:]))
+	return append(chunk, crc...)
+}
+
+// pngWithText encodes a PNG and inserts a tEXt chunk after the IHDR chunk
+func pngWithText(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(w, h)); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	ihdrEnd := 8 + 12 + 13
+	out := append([]byte{}, data[:ihdrEnd]...)
+	out = append(out, pngChunk("tEXt", []byte("Comment\x00secret location"))...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+func TestDetectFileType(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		fileName string
+		want     string // Empty when the file must be rejected
+	}{
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0}, "photo.JPG", "image/jpeg"},
+		{"png", []byte("\x89PNG\r\n\x1a\n...."), "a.png", "image/png"},
+		{"gif", []byte("GIF89a...."), "a.gif", "image/gif"},
+		{"pdf", []byte("%PDF-1.7"), "notice.pdf", "application/pdf"},
+		{"docx", []byte("PK\x03\x04...."), "plan.docx", officeTypes[".docx"]},
+		{"xls", []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, "list.xls", officeTypes[".xls"]},
+		{"png renamed to jpg", []byte("\x89PNG\r\n\x1a\n...."), "a.jpg", ""},
+		{"zip archive", []byte("PK\x03\x04...."), "a.zip", ""},
+		{"executable renamed to pdf", []byte("MZ\x90\x00"), "a.pdf", ""},
+		{"html", []byte("<html><script>"), "a.html", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft, err := detectFileType(tt.data, tt.fileName)
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidAttachment) {
+					t.Fatalf("err = %v, want ErrInvalidAttachment", err)
+				}
+				return
+			}
+			if err != nil || ft.contentType != tt.want {
+				t.Fatalf("got %q, %v, want %q", ft.contentType, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	data := jpegWithExif(t, 40, 30)
+
+	stripped, err := stripJPEGMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"Exif", "GPSLatitude", "taken by phone"} {
+		if bytes.Contains(stripped, []byte(leak)) {
+			t.Errorf("stripped JPEG still contains %q", leak)
+		}
+	}
+	img, err := jpeg.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("stripped JPEG does not decode: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 40 || b.Dy() != 30 {
+		t.Errorf("stripped JPEG is %dx%d, want 40x30", b.Dx(), b.Dy())
+	}
+
+	if _, err := stripJPEGMetadata(data[:40]); err == nil {
+		t.Error("truncated JPEG was accepted")
+	}
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	stripped, err := stripPNGMetadata(pngWithText(t, 20, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stripped, []byte("secret location")) {
+		t.Error("stripped PNG still contains its text chunk")
+	}
+	if _, err := png.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Fatalf("stripped PNG does not decode: %v", err)
+	}
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(800, 400))
+
+	thumb, err := makeThumbnail(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" || cfg.Width != thumbnailSize || cfg.Height != thumbnailSize/2 {
+		t.Errorf("thumbnail is a %dx%d %s, want a %dx%d jpeg", cfg.Width, cfg.Height, format, thumbnailSize, thumbnailSize/2)
+	}
+
+	// Small images keep their size
+	buf.Reset()
+	png.Encode(&buf, testImage(50, 20))
+	thumb, _ = makeThumbnail(buf.Bytes())
+	if cfg, _, _ := image.DecodeConfig(bytes.NewReader(thumb)); cfg.Width != 50 || cfg.Height != 20 {
+		t.Errorf("small image thumbnail is %dx%d, want 50x20", cfg.Width, cfg.Height)
+	}
+}
+
+func TestMakeThumbnailRejectsHugeDimensions(t *testing.T) {
+	// A PNG header claiming 10000x10000 pixels, whose image data is never decoded
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], 10000)
+	binary.BigEndian.PutUint32(ihdr[4:], 10000)
+	ihdr[8], ihdr[9] = 8, 2 // 8-bit RGB
+	data := append([]byte("\x89PNG\r\n\x1a\n"), pngChunk("IHDR", ihdr)...)
+
+	if _, err := makeThumbnail(data); !errors.Is(err, ErrInvalidAttachment) {
+		t.Fatalf("err = %v, want ErrInvalidAttachment", err)
+	}
+}
+
+// fileHeader builds the multipart file header of an upload
+func fileHeader(t *testing.T, name string, data []byte) *multipart.FileHeader {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("files", name)
+	part.Write(data)
+	w.Close()
+
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if err := req.ParseMultipartForm(32 << 20); err != nil {
+		t.Fatal(err)
+	}
+	return req.MultipartForm.File["files"][0]
+}
+
+func TestSaveAttachment(t *testing.T) {
+	mem := useMemoryStorage(t)
+
+	attachment, err := SaveAttachment(fileHeader(t, "../../dorm.jpg", jpegWithExif(t, 640, 480)), 42, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachment.FileName != "dorm.jpg" || attachment.ContentType != "image/jpeg" {
+		t.Errorf("attachment = %q %q, want dorm.jpg image/jpeg", attachment.FileName, attachment.ContentType)
+	}
+	if !strings.HasPrefix(attachment.StorageKey, "attachments/42/") || !strings.HasSuffix(attachment.StorageKey, ".jpg") {
+		t.Errorf("storage key = %q", attachment.StorageKey)
+	}
+
+	stored := mem.files[attachment.StorageKey]
+	if bytes.Contains(stored, []byte("GPSLatitude")) {
+		t.Error("stored file still contains EXIF metadata")
+	}
+	if int64(len(stored)) != attachment.Size {
+		t.Errorf("recorded size %d, stored %d bytes", attachment.Size, len(stored))
+	}
+	if !attachment.HasThumbnail || mem.files[attachment.ThumbnailKey] == nil {
+		t.Error("thumbnail was not stored")
+	}
+
+	var saved models.Attachment
+	if err := database.DB.First(&saved, attachment.ID).Error; err != nil {
+		t.Fatalf("attachment was not recorded: %v", err)
+	}
+
+	if err := DeleteAttachment(&saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(mem.files) != 0 {
+		t.Errorf("%d files left after deleting the attachment", len(mem.files))
+	}
+}
+
+func TestSaveAttachmentRejectsInvalidFiles(t *testing.T) {
+	mem := useMemoryStorage(t)
+
+	tests := map[string][]byte{
+		"fake.jpg":   []byte("not an image at all"),
+		"broken.jpg": {0xFF, 0xD8, 0xFF, 0xE1, 0x00},
+		"large.pdf":  append([]byte("%PDF-"), make([]byte, MaxAttachmentSize)...),
+	}
+	for name, data := range tests {
+		if _, err := SaveAttachment(fileHeader(t, name, data), 1, nil, nil); !errors.Is(err, ErrInvalidAttachment) {
+			t.Errorf("%s: err = %v, want ErrInvalidAttachment", name, err)
+		}
+	}
+	if len(mem.files) != 0 {
+		t.Errorf("%d files were stored for rejected uploads", len(mem.files))
+	}
+}
+
+func TestAttachmentApproval(t *testing.T) {
+	useMemoryStorage(t)
+	pdf := []byte("%PDF-1.4\n%%EOF\n")
+
+	submitted, err := SaveAttachment(fileHeader(t, "submitted.pdf", pdf), 43, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminID := uint(1)
+	reply, err := SaveAttachment(fileHeader(t, "notice.pdf", pdf), 43, nil, &adminID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if submitted.IsApproved || !reply.IsApproved {
+		t.Errorf("approved = %v %v, want student uploads held and admin uploads approved", submitted.IsApproved, reply.IsApproved)
+	}
+
+	if err := ApproveSubmittedAttachments(43); err != nil {
+		t.Fatal(err)
+	}
+	var saved models.Attachment
+	database.DB.First(&saved, submitted.ID)
+	if !saved.IsApproved {
+		t.Error("student upload was not approved with its suggestion")
+	}
+
+	DeleteAttachments([]uint{43})
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"advice/utils"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Challenge modes for anonymous submissions, set with CHALLENGE_MODE
+const (
+	ChallengeOff     = "off"
+	ChallengePoW     = "pow"     // Hashcash-style proof of work solved by the browser
+	ChallengeCaptcha = "captcha" // Arithmetic captcha rendered as an image
+)
+
+const challengeTTL = 10 * time.Minute
+
+var (
+	ChallengeMode = utils.Getenv("CHALLENGE_MODE", ChallengePoW)
+	// PoWDifficulty is the number of leading zero bits required in the proof of work hash
+	PoWDifficulty   = utils.GetenvInt("POW_DIFFICULTY", 16)
+	challengeSecret = []byte(utils.Getenv("CHALLENGE_SECRET", "your-very-secret-challenge-key")) // WARNING: Set CHALLENGE_SECRET in production
+
+	ErrChallengeRequired = errors.New("challenge is required")
+	ErrChallengeInvalid  = errors.New("challenge is invalid or expired")
+	ErrChallengeFailed   = errors.New("challenge solution is incorrect")
+)
+
+// Challenge is issued to a client before an anonymous submission
+type Challenge struct {
+	Mode       string    `json:"mode"`
+	Token      string    `json:"token,omitempty"`
+	Difficulty int       `json:"difficulty,omitempty"` // Proof of work only
+	Image      string    `json:"image,omitempty"`      // Captcha only, PNG data URL
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+}
+
+// usedChallenges remembers solved tokens until they expire, so each challenge is accepted once
+var usedChallenges = struct {
+	sync.Mutex
+	tokens map[string]time.Time
+}{tokens: map[string]time.Time{}}
+
+func signChallenge(payload string) string {
+	mac := hmac.New(sha256.New, challengeSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func hashCaptchaAnswer(id, answer string) string {
+	return signChallenge("answer:" + id + ":" + strings.TrimSpace(answer))[:16]
+}
+
+// NewChallenge issues a challenge for the configured mode.
+// Tokens are signed and self-contained: id.expiry.mode.difficulty.answerHash.signature
+func NewChallenge() (*Challenge, error) {
+	challenge := &Challenge{Mode: ChallengeMode}
+	if ChallengeMode == ChallengeOff {
+		return challenge, nil
+	}
+
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(b)
+	challenge.ExpiresAt = time.Now().Add(challengeTTL)
+
+	answerHash := "-"
+	switch ChallengeMode {
+	case ChallengePoW:
+		challenge.Difficulty = PoWDifficulty
+	case ChallengeCaptcha:
+		question, answer, err := randomArithmetic()
+		if err != nil {
+			return nil, err
+		}
+		image, err := RenderCaptcha(question)
+		if err != nil {
+			return nil, err
+		}
+		challenge.Image = "data:image/png;base64," + base64.StdEncoding.EncodeToString(image)
+		answerHash = hashCaptchaAnswer(id, strconv.Itoa(answer))
+	default:
+		return nil, fmt.Errorf("unknown challenge mode %q", ChallengeMode)
+	}
+
+	payload := strings.Join([]string{id, strconv.FormatInt(challenge.ExpiresAt.Unix(), 10), ChallengeMode, strconv.Itoa(challenge.Difficulty), answerHash}, ".")
+	challenge.Token = payload + "." + signChallenge(payload)
+	return challenge, nil
+}
+
+// randomArithmetic creates a small addition or subtraction with a non-negative answer
+func randomArithmetic() (string, int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(2*10*10))
+	if err != nil {
+		return "", 0, err
+	}
+	v := int(n.Int64())
+	a, b, add := v%10+1, v/10%10+1, v/100 == 0
+	if add {
+		return fmt.Sprintf("%d+%d=?", a, b), a + b, nil
+	}
+	if a < b {
+		a, b = b, a
+	}
+	return fmt.Sprintf("%d-%d=?", a, b), a - b, nil
+}
+
+// leadingZeroBits counts the leading zero bits of a hash
+func leadingZeroBits(hash []byte) int {
+	count := 0
+	for _, b := range hash {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+	return count
+}
+
+// VerifyChallenge checks the solution of a challenge issued by NewChallenge.
+// For proof of work the solution is a nonce such that SHA-256(token + ":" + nonce) starts with
+// the required number of zero bits; for a captcha it is the answer to the arithmetic question.
+func VerifyChallenge(token, solution string) error {
+	if ChallengeMode == ChallengeOff {
+		return nil
+	}
+	if token == "" || solution == "" {
+		return ErrChallengeRequired
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 6 {
+		return ErrChallengeInvalid
+	}
+	payload := strings.Join(parts[:5], ".")
+	if !hmac.Equal([]byte(parts[5]), []byte(signChallenge(payload))) {
+		return ErrChallengeInvalid
+	}
+	id, mode, answerHash := parts[0], parts[2], parts[4]
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires || mode != ChallengeMode {
+		return ErrChallengeInvalid
+	}
+
+	// Each token gets a single attempt, so captcha answers cannot be guessed one by one
+	usedChallenges.Lock()
+	now := time.Now()
+	for used, expiry := range usedChallenges.tokens {
+		if now.After(expiry) {
+			delete(usedChallenges.tokens, used)
+		}
+	}
+	_, used := usedChallenges.tokens[id]
+	usedChallenges.tokens[id] = time.Unix(expires, 0)
+	usedChallenges.Unlock()
+	if used {
+		return ErrChallengeInvalid
+	}
+
+	switch mode {
+	case ChallengePoW:
+		difficulty, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return ErrChallengeInvalid
+		}
+		hash := sha256.Sum256([]byte(token + ":" + solution))
+		if leadingZeroBits(hash[:]) < difficulty {
+			return ErrChallengeFailed
+		}
+	case ChallengeCaptcha:
+		if !hmac.Equal([]byte(answerHash), []byte(hashCaptchaAnswer(id, solution))) {
+			return ErrChallengeFailed
+		}
+	}
+
+	return nil
+}
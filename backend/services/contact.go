@@ -0,0 +1,219 @@
+package services
+
+import (
+	"advice/database"
+	"advice/mailer"
+	"advice/models"
+	"advice/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"gorm.io/gorm"
+)
+
+// ContactLanguages are the languages of student notification emails
+var ContactLanguages = []string{"zh", "en"}
+
+// ErrInvalidContactEmail is returned for contact addresses that cannot receive email
+var ErrInvalidContactEmail = errors.New("invalid contact email")
+
+// publicBaseURL is where students reach the API, used for the unsubscribe link
+var publicBaseURL = strings.TrimRight(utils.Getenv("PUBLIC_BASE_URL", "http://localhost:8080"), "/")
+
+// statusNames translates suggestion statuses for English emails
+var statusNames = map[string]string{
+	"待审核":   "Pending review",
+	"待处理":   "Awaiting action",
+	"处理中":   "In progress",
+	"已解决":   "Resolved",
+	"已关闭":   "Closed",
+	"审核不通过": "Rejected",
+	"已合并":   "Merged into a similar suggestion",
+	"已撤回":   "Withdrawn",
+}
+
+type contactTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// contactTemplates holds the templates of each notification event by language
+var contactTemplates = map[string]map[string]contactTemplate{
+	"zh": {
+		"status": newContactTemplate(
+			`您的建议「{{.Title}}」状态已更新为：{{.Status}}`,
+			`同学您好：
+
+您提交的建议「{{.Title}}」（查询码 {{.TrackingCode}}）状态已更新为：{{.Status}}。
+
+您可以使用查询码随时查看处理进度。
+
+如不希望继续收到此类邮件，请访问以下链接退订：
+{{.UnsubscribeURL}}
+`),
+		"reply": newContactTemplate(
+			`您的建议「{{.Title}}」收到了新的回复`,
+			`同学您好：
+
+您提交的建议「{{.Title}}」（查询码 {{.TrackingCode}}）收到了新的回复：
+
+{{.Reply}}
+
+您可以使用查询码随时查看处理进度。
+
+如不希望继续收到此类邮件，请访问以下链接退订：
+{{.UnsubscribeURL}}
+`),
+	},
+	"en": {
+		"status": newContactTemplate(
+			`Your suggestion "{{.Title}}" is now: {{.Status}}`,
+			`Hello,
+
+The status of your suggestion "{{.Title}}" (tracking code {{.TrackingCode}}) has been updated to: {{.Status}}.
+
+You can check its progress at any time with your tracking code.
+
+To stop receiving these emails, unsubscribe here:
+{{.UnsubscribeURL}}
+`),
+		"reply": newContactTemplate(
+			`New reply to your suggestion "{{.Title}}"`,
+			`Hello,
+
+Your suggestion "{{.Title}}" (tracking code {{.TrackingCode}}) has received a new reply:
+
+{{.Reply}}
+
+You can check its progress at any time with your tracking code.
+
+To stop receiving these emails, unsubscribe here:
+{{.UnsubscribeURL}}
+`),
+	},
+}
+
+func newContactTemplate(subject, body string) contactTemplate {
+	return contactTemplate{
+		subject: template.Must(template.New("subject").Parse(subject)),
+		body:    template.Must(template.New("body").Parse(body)),
+	}
+}
+
+// IsValidContactLanguage reports whether notifications can be sent in the language
+func IsValidContactLanguage(language string) bool {
+	for _, l := range ContactLanguages {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeContactEmail validates a bare email address such as "name@example.com"
+func NormalizeContactEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > 254 {
+		return "", ErrInvalidContactEmail
+	}
+	return email, nil
+}
+
+// SaveContact stores the encrypted contact address of a suggestion
+func SaveContact(tx *gorm.DB, suggestionID uint, email, language string) error {
+	encrypted, err := utils.EncryptString(email)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.SuggestionContact{
+		SuggestionID:   suggestionID,
+		EncryptedEmail: encrypted,
+		Language:       language,
+		Unsubscribed:   false,
+	}).Error
+}
+
+// UnsubscribeURL returns the link that stops notifications for a tracking code
+func UnsubscribeURL(trackingCode string) string {
+	return fmt.Sprintf("%s/api/v1/tracking/%s/unsubscribe?token=%s",
+		publicBaseURL, url.PathEscape(trackingCode), url.QueryEscape(utils.SignUnsubscribe(trackingCode)))
+}
+
+// NotifyStudentStatus emails the student about a new status of their suggestion, if they left a contact address
+func NotifyStudentStatus(suggestionID uint) {
+	notifyStudent(suggestionID, "status", "")
+}
+
+// NotifyStudentReply emails the student about a new reply to their suggestion, if they left a contact address
+func NotifyStudentReply(suggestionID uint, reply string) {
+	notifyStudent(suggestionID, "reply", reply)
+}
+
+// notifyStudent sends a notification in the background, failures are only logged
+func notifyStudent(suggestionID uint, event, reply string) {
+	var contact models.SuggestionContact
+	err := database.DB.Where("suggestion_id = ? AND unsubscribed = ?", suggestionID, false).First(&contact).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Failed to load contact for notification:", err)
+		}
+		return
+	}
+	var suggestion models.Suggestion
+	if err := database.DB.First(&suggestion, suggestionID).Error; err != nil {
+		log.Println("Failed to load suggestion for notification:", err)
+		return
+	}
+
+	email, err := utils.DecryptString(contact.EncryptedEmail)
+	if err != nil {
+		log.Printf("Failed to decrypt contact of suggestion #%d: %v", suggestionID, err)
+		return
+	}
+	templates, ok := contactTemplates[contact.Language]
+	if !ok {
+		templates = contactTemplates["zh"]
+	}
+	status := suggestion.Status
+	if contact.Language == "en" && statusNames[status] != "" {
+		status = statusNames[status]
+	}
+	data := map[string]string{
+		"Title":          suggestion.Title,
+		"TrackingCode":   suggestion.TrackingCode,
+		"Status":         status,
+		"Reply":          reply,
+		"UnsubscribeURL": UnsubscribeURL(suggestion.TrackingCode),
+	}
+
+	var subject, body strings.Builder
+	if err := templates[event].subject.Execute(&subject, data); err != nil {
+		log.Println("Failed to render notification:", err)
+		return
+	}
+	if err := templates[event].body.Execute(&body, data); err != nil {
+		log.Println("Failed to render notification:", err)
+		return
+	}
+
+	go func() {
+		err := mailer.Default.Send(mailer.Message{
+			To:      email,
+			Subject: subject.String(),
+			Body:    body.String(),
+			Headers: map[string]string{
+				"List-Unsubscribe":      "<" + data["UnsubscribeURL"] + ">",
+				"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+			},
+		})
+		if err != nil {
+			log.Printf("Failed to email the submitter of suggestion #%d: %v", suggestionID, err)
+		}
+	}()
+}
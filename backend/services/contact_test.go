@@ -0,0 +1,126 @@
+package services
+
+import (
+	"advice/database"
+	"advice/mailer"
+	"advice/mailer/mailertest"
+	"advice/models"
+	"encoding/base64"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useFakeSMTP sends notifications through a stand-in SMTP server for the duration of a test
+func useFakeSMTP(t *testing.T) *mailertest.Server {
+	server := mailertest.NewServer(t, "", "")
+	previous := mailer.Default
+	mailer.Default = &mailer.SMTPSender{Host: server.Host, Port: server.Port, From: "notify@example.edu"}
+	t.Cleanup(func() { mailer.Default = previous })
+	return server
+}
+
+func createContactSuggestion(t *testing.T, trackingCode, email, language string) models.Suggestion {
+	suggestion := models.Suggestion{TrackingCode: trackingCode, Title: "食堂排队太久", Content: "午饭时间排队超过二十分钟", Status: "处理中"}
+	if err := database.DB.Create(&suggestion).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveContact(database.DB, suggestion.ID, email, language); err != nil {
+		t.Fatal(err)
+	}
+	return suggestion
+}
+
+// receiveNotification waits for the next email and returns its recipient, subject, body and headers
+func receiveNotification(t *testing.T, server *mailertest.Server) (string, string, string, mail.Header) {
+	var received mailertest.Message
+	select {
+	case received = <-server.Messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification was sent")
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(received.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	raw, _ := io.ReadAll(msg.Body)
+	body, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(raw), "\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return received.To[0], subject, string(body), msg.Header
+}
+
+func TestNotifyStudentStatus(t *testing.T) {
+	server := useFakeSMTP(t)
+
+	tests := []struct {
+		trackingCode, email, language string
+		subject, status               string
+	}{
+		{"MAILZH", "zh@example.com", "zh", "您的建议「食堂排队太久」状态已更新为：处理中", "状态已更新为：处理中。"},
+		{"MAILEN", "en@example.com", "en", `Your suggestion "食堂排队太久" is now: In progress`, "has been updated to: In progress."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			suggestion := createContactSuggestion(t, tt.trackingCode, tt.email, tt.language)
+
+			NotifyStudentStatus(suggestion.ID)
+			to, subject, body, header := receiveNotification(t, server)
+			if to != tt.email {
+				t.Errorf("sent to %q, want %q", to, tt.email)
+			}
+			if subject != tt.subject {
+				t.Errorf("subject = %q, want %q", subject, tt.subject)
+			}
+			if !strings.Contains(body, tt.status) || !strings.Contains(body, tt.trackingCode) {
+				t.Errorf("body does not mention the status and tracking code:\n%s", body)
+			}
+			unsubscribeURL := UnsubscribeURL(tt.trackingCode)
+			if !strings.Contains(body, unsubscribeURL) {
+				t.Errorf("body does not contain the unsubscribe link %s", unsubscribeURL)
+			}
+			if got := header.Get("List-Unsubscribe"); got != "<"+unsubscribeURL+">" {
+				t.Errorf("List-Unsubscribe = %q, want <%s>", got, unsubscribeURL)
+			}
+			if got := header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+				t.Errorf("List-Unsubscribe-Post = %q", got)
+			}
+		})
+	}
+}
+
+func TestNotifyStudentReply(t *testing.T) {
+	server := useFakeSMTP(t)
+	suggestion := createContactSuggestion(t, "MAILRP", "reply@example.com", "en")
+
+	NotifyStudentReply(suggestion.ID, "We have opened two more windows at noon.")
+	_, subject, body, _ := receiveNotification(t, server)
+	if subject != `New reply to your suggestion "食堂排队太久"` {
+		t.Errorf("subject = %q", subject)
+	}
+	if !strings.Contains(body, "We have opened two more windows at noon.") {
+		t.Errorf("body does not contain the reply:\n%s", body)
+	}
+}
+
+func TestNotifyStudentSkipsUnsubscribed(t *testing.T) {
+	server := useFakeSMTP(t)
+	suggestion := createContactSuggestion(t, "MAILUN", "gone@example.com", "zh")
+	database.DB.Model(&models.SuggestionContact{}).Where("suggestion_id = ?", suggestion.ID).Update("unsubscribed", true)
+	withoutContact := models.Suggestion{TrackingCode: "MAILNC", Title: "t", Content: "c"}
+	database.DB.Create(&withoutContact)
+
+	NotifyStudentStatus(suggestion.ID)
+	NotifyStudentReply(suggestion.ID, "reply")
+	NotifyStudentStatus(withoutContact.ID)
+	select {
+	case msg := <-server.Messages:
+		t.Errorf("notification was sent to %v", msg.To)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
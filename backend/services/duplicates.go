@@ -0,0 +1,71 @@
+package services
+
+import (
+	"advice/database"
+	"advice/models"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+const (
+	duplicateMinScore = 0.35
+	duplicateLimit    = 5
+	hintMinScore      = 0.15
+	hintLimit         = 5
+)
+
+// IDsMatching returns a FindSimilar filter keeping the suggestions selected by scope
+func IDsMatching(scope func(*gorm.DB) *gorm.DB) func(ids []uint) map[uint]bool {
+	return func(ids []uint) map[uint]bool {
+		allowed := map[uint]bool{}
+		if len(ids) == 0 {
+			return allowed
+		}
+
+		var matched []uint
+		query := database.DB.Model(&models.Suggestion{}).Where("suggestions.id IN ?", ids)
+		if err := scope(query).Pluck("suggestions.id", &matched).Error; err != nil {
+			log.Println("Failed to filter similar suggestions:", err)
+			return allowed
+		}
+		for _, id := range matched {
+			allowed[id] = true
+		}
+		return allowed
+	}
+}
+
+// RecordDuplicateCandidates stores the existing suggestions that a new suggestion possibly duplicates
+func RecordDuplicateCandidates(suggestion *models.Suggestion) {
+	// Merged duplicates, rejected and withdrawn suggestions are not worth pointing to
+	scope := func(query *gorm.DB) *gorm.DB {
+		return query.Where("canonical_id IS NULL").Where("status NOT IN ?", []string{"审核不通过", "已撤回"})
+	}
+	hits := FindSimilar(suggestion.Title, suggestion.Content, suggestion.ID, duplicateMinScore, duplicateLimit, IDsMatching(scope))
+	if len(hits) == 0 {
+		return
+	}
+
+	candidates := make([]models.DuplicateCandidate, 0, len(hits))
+	for _, hit := range hits {
+		candidates = append(candidates, models.DuplicateCandidate{
+			SuggestionID: suggestion.ID,
+			CandidateID:  hit.SuggestionID,
+			Score:        hit.Score,
+		})
+	}
+	if err := database.DB.Omit("Candidate").Create(&candidates).Error; err != nil {
+		log.Printf("Failed to record duplicate candidates of suggestion %d: %v", suggestion.ID, err)
+	}
+}
+
+// FindPublicSimilar returns the public, approved suggestions that resemble a draft, so the student can upvote one instead
+func FindPublicSimilar(title, content string) []SimilarHit {
+	scope := func(query *gorm.DB) *gorm.DB {
+		return query.Where("is_public = ?", true).
+			Where("status NOT IN ?", []string{"待审核", "审核不通过", "已撤回"}).
+			Where("canonical_id IS NULL")
+	}
+	return FindSimilar(title, content, 0, hintMinScore, hintLimit, IDsMatching(scope))
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"advice/events"
+	"advice/models"
+	"time"
+)
+
+// Suggestion event types pushed to admins
+const (
+	EventSuggestionCreated  = "suggestion.created"
+	EventSuggestionUpdated  = "suggestion.updated"
+	EventSuggestionReplied  = "suggestion.replied"
+	EventSuggestionDeleted  = "suggestion.deleted"
+	EventSuggestionResolved = "suggestion.resolved" // Published along with suggestion.updated
+)
+
+// EventTypes lists every suggestion event type
+var EventTypes = []string{EventSuggestionCreated, EventSuggestionUpdated, EventSuggestionReplied, EventSuggestionDeleted, EventSuggestionResolved}
+
+// SuggestionEvent is the data of a suggestion event, enough for clients to update a list or decide to refetch
+type SuggestionEvent struct {
+	ID           uint      `json:"id"`
+	Title        string    `json:"title,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	Priority     string    `json:"priority,omitempty"`
+	DepartmentID *uint     `json:"department_id"`
+	AssigneeID   *uint     `json:"assignee_id"`
+	IsOverdue    bool      `json:"is_overdue"`
+	ReplyID      *uint     `json:"reply_id,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func suggestionEvent(s *models.Suggestion) SuggestionEvent {
+	return SuggestionEvent{
+		ID:           s.ID,
+		Title:        s.Title,
+		Status:       s.Status,
+		Priority:     s.Priority,
+		DepartmentID: s.DepartmentID,
+		AssigneeID:   s.AssigneeID,
+		IsOverdue:    s.IsOverdue,
+		UpdatedAt:    s.UpdatedAt,
+	}
+}
+
+// PublishSuggestionEvent pushes a suggestion event to the admins who can see the suggestion,
+// or could see it in one of its former departments
+func PublishSuggestionEvent(kind string, s *models.Suggestion, formerDepartments ...*uint) {
+	events.Default.Publish(kind, append([]*uint{s.DepartmentID}, formerDepartments...), suggestionEvent(s))
+}
+
+// PublishReplyEvent pushes a new reply to the admins who can see its suggestion
+func PublishReplyEvent(s *models.Suggestion, reply *models.Reply) {
+	data := suggestionEvent(s)
+	data.ReplyID = &reply.ID
+	events.Default.Publish(EventSuggestionReplied, []*uint{s.DepartmentID}, data)
+}
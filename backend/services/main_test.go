@@ -0,0 +1,24 @@
+package services
+
+import (
+	"advice/database"
+	"os"
+	"testing"
+)
+
+// TestMain runs the tests against a fresh database in a temporary directory
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "advice-services")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	database.ConnectDatabase()
+	database.AutoMigrate()
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
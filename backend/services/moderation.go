@@ -0,0 +1,254 @@
+package services
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/utils"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Moderation actions of sensitive word lists, from least to most strict
+const (
+	ModerationMask  = "mask"  // Replace the word with asterisks
+	ModerationFlag  = "flag"  // Keep the text and flag it for admin review
+	ModerationBlock = "block" // Reject the text
+)
+
+var moderationActionRank = map[string]int{"": 0, ModerationMask: 1, ModerationFlag: 2, ModerationBlock: 3}
+
+// IsValidModerationAction reports whether action is one of the moderation actions
+func IsValidModerationAction(action string) bool {
+	return action != "" && moderationActionRank[action] > 0
+}
+
+// SplitWords splits a word list on new lines and commas, dropping empty entries
+func SplitWords(words string) []string {
+	var result []string
+	for _, w := range strings.FieldsFunc(words, func(r rune) bool { return r == '\n' || r == ',' || r == '，' }) {
+		if w = strings.TrimSpace(w); w != "" {
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
+// WordMatch is an occurrence of a sensitive word, with rune offsets into the checked text
+type WordMatch struct {
+	Start, End int
+	Word       string
+	Action     string
+}
+
+type matcherWord struct {
+	word   string
+	action string
+	length int
+}
+
+type matcherNode struct {
+	next    map[rune]int
+	fail    int
+	outputs []int // Indexes into Matcher.words ending at this node
+}
+
+// Matcher finds sensitive words in a single pass using an Aho-Corasick automaton.
+// Matching is case-insensitive and works on runes, so Chinese words need no segmentation.
+type Matcher struct {
+	nodes []matcherNode
+	words []matcherWord
+}
+
+// NewMatcher builds a matcher for words mapped to their moderation action
+func NewMatcher(words map[string]string) *Matcher {
+	m := &Matcher{nodes: []matcherNode{{next: map[rune]int{}}}}
+	for word, action := range words {
+		runes := []rune(word)
+		for i, r := range runes {
+			runes[i] = unicode.ToLower(r)
+		}
+		if len(runes) == 0 {
+			continue
+		}
+		node := 0
+		for _, r := range runes {
+			child, ok := m.nodes[node].next[r]
+			if !ok {
+				child = len(m.nodes)
+				m.nodes = append(m.nodes, matcherNode{next: map[rune]int{}})
+				m.nodes[node].next[r] = child
+			}
+			node = child
+		}
+		m.nodes[node].outputs = append(m.nodes[node].outputs, len(m.words))
+		m.words = append(m.words, matcherWord{word: word, action: action, length: len(runes)})
+	}
+
+	// Breadth-first construction of the failure links
+	queue := []int{}
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[node].next {
+			fail := m.nodes[node].fail
+			for fail != 0 && m.nodes[fail].next[r] == 0 {
+				fail = m.nodes[fail].fail
+			}
+			if target, ok := m.nodes[fail].next[r]; ok && target != child {
+				m.nodes[child].fail = target
+			}
+			m.nodes[child].outputs = append(m.nodes[child].outputs, m.nodes[m.nodes[child].fail].outputs...)
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// FindAll returns every occurrence of the matcher's words in text
+func (m *Matcher) FindAll(text string) []WordMatch {
+	var matches []WordMatch
+	node := 0
+	for i, r := range []rune(text) {
+		r = unicode.ToLower(r)
+		for node != 0 && m.nodes[node].next[r] == 0 {
+			node = m.nodes[node].fail
+		}
+		node = m.nodes[node].next[r]
+		for _, index := range m.nodes[node].outputs {
+			w := m.words[index]
+			matches = append(matches, WordMatch{Start: i + 1 - w.length, End: i + 1, Word: w.word, Action: w.action})
+		}
+	}
+	return matches
+}
+
+var moderation struct {
+	sync.RWMutex
+	matcher *Matcher
+}
+
+// ReloadWordLists rebuilds the matcher from the active sensitive word lists
+func ReloadWordLists() {
+	var lists []models.SensitiveWordList
+	if err := database.DB.Where("is_active = ?", true).Find(&lists).Error; err != nil {
+		log.Println("Failed to load sensitive word lists:", err)
+		return
+	}
+
+	// A word in several lists gets the strictest action
+	words := map[string]string{}
+	for _, list := range lists {
+		for _, word := range SplitWords(list.Words) {
+			if moderationActionRank[list.Action] > moderationActionRank[words[word]] {
+				words[word] = list.Action
+			}
+		}
+	}
+
+	moderation.Lock()
+	moderation.matcher = NewMatcher(words)
+	moderation.Unlock()
+}
+
+func currentMatcher() *Matcher {
+	moderation.RLock()
+	defer moderation.RUnlock()
+	if moderation.matcher == nil {
+		return NewMatcher(nil)
+	}
+	return moderation.matcher
+}
+
+// piiPatterns detect personal information that is masked before public display.
+// The student number format differs between schools and is set with STUDENT_NUMBER_PATTERN.
+var piiPatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{"id_card", regexp.MustCompile(`\b\d{17}[\dXx]\b`)},
+	{"phone", regexp.MustCompile(`\b1[3-9]\d{9}\b`)},
+	{"student_number", regexp.MustCompile(`\b` + utils.Getenv("STUDENT_NUMBER_PATTERN", `20\d{8}`) + `\b`)},
+}
+
+// DetectPII returns the kinds of personal information found in text
+func DetectPII(text string) []string {
+	var kinds []string
+	for _, p := range piiPatterns {
+		if p.pattern.MatchString(text) {
+			kinds = append(kinds, p.kind)
+		}
+	}
+	return kinds
+}
+
+// MaskPII hides the middle of phone, ID card and student numbers, keeping a few digits on each side
+func MaskPII(text string) string {
+	for _, p := range piiPatterns {
+		text = p.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			keep := len(match) / 4
+			return match[:keep] + strings.Repeat("*", len(match)-2*keep) + match[len(match)-keep:]
+		})
+	}
+	return text
+}
+
+// ModerationCheck is the outcome of moderating a set of texts
+type ModerationCheck struct {
+	Result       models.ModerationResult
+	Blocked      bool
+	BlockedWords []string
+}
+
+// Moderate checks texts against the sensitive word lists and the PII detector.
+// Words of "mask" lists are replaced with asterisks in place; PII is kept and only masked for public display.
+func Moderate(texts ...*string) ModerationCheck {
+	matcher := currentMatcher()
+
+	var check ModerationCheck
+	matched := map[string]bool{}
+	piiKinds := map[string]bool{}
+	for _, text := range texts {
+		matches := matcher.FindAll(*text)
+		runes := []rune(*text)
+		for _, match := range matches {
+			switch match.Action {
+			case ModerationBlock:
+				check.Blocked = true
+				check.BlockedWords = append(check.BlockedWords, match.Word)
+			case ModerationMask:
+				for i := match.Start; i < match.End; i++ {
+					runes[i] = '*'
+				}
+			}
+			if match.Action != ModerationBlock && moderationActionRank[match.Action] > moderationActionRank[check.Result.Action] {
+				check.Result.Action = match.Action
+			}
+			matched[match.Word] = true
+		}
+		*text = string(runes)
+
+		for _, kind := range DetectPII(*text) {
+			piiKinds[kind] = true
+		}
+	}
+
+	check.Result.MatchedWords = strings.Join(sortedKeys(matched), ",")
+	check.Result.PIITypes = strings.Join(sortedKeys(piiKinds), ",")
+	return check
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"advice/database"
+	"advice/mailer"
+	"advice/models"
+	"advice/utils"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Notification types
+const (
+	NotificationNewSuggestion = "new_suggestion" // A new suggestion reached the admin's department
+	NotificationFollowUp      = "follow_up"      // The student added to their suggestion
+	NotificationTransfer      = "transfer"       // A suggestion was transferred to the admin's department
+	NotificationSLAWarning    = "sla_warning"    // A suggestion is about to miss or has missed an SLA deadline
+)
+
+// NotificationTypes lists every notification type
+var NotificationTypes = []string{NotificationNewSuggestion, NotificationFollowUp, NotificationTransfer, NotificationSLAWarning}
+
+// EmailModes are the ways admins can receive notifications by email
+var EmailModes = []string{"off", "instant", "daily"}
+
+const digestCheckInterval = 10 * time.Minute
+
+// digestHour is the local hour after which daily digests are sent, configured by NOTIFICATION_DIGEST_HOUR
+var digestHour = utils.GetenvInt("NOTIFICATION_DIGEST_HOUR", 8)
+
+// IsValidNotificationType reports whether kind is a known notification type
+func IsValidNotificationType(kind string) bool {
+	for _, t := range NotificationTypes {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidEmailMode reports whether mode is a known email mode
+func IsValidEmailMode(mode string) bool {
+	for _, m := range EmailModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// isMuted reports whether a preference turns off a notification type
+func isMuted(pref models.NotificationPreference, kind string) bool {
+	for _, t := range strings.Split(pref.MutedTypes, ",") {
+		if strings.TrimSpace(t) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// NotifyAdmins creates a notification for each admin, unless they muted its type, and emails those who want it instantly
+func NotifyAdmins(adminIDs []uint, kind string, suggestionID uint, title, message string) {
+	seen := map[uint]bool{}
+	var recipients []uint
+	for _, id := range adminIDs {
+		if !seen[id] {
+			seen[id] = true
+			recipients = append(recipients, id)
+		}
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	var prefs []models.NotificationPreference
+	database.DB.Where("admin_id IN ?", recipients).Find(&prefs)
+	prefByAdmin := map[uint]models.NotificationPreference{}
+	for _, p := range prefs {
+		prefByAdmin[p.AdminID] = p
+	}
+
+	for _, adminID := range recipients {
+		pref := prefByAdmin[adminID]
+		if isMuted(pref, kind) {
+			continue
+		}
+		notification := models.Notification{
+			AdminID:      adminID,
+			Type:         kind,
+			Title:        title,
+			Message:      message,
+			SuggestionID: &suggestionID,
+		}
+		if err := database.DB.Create(&notification).Error; err != nil {
+			log.Printf("Failed to notify admin %d: %v", adminID, err)
+			continue
+		}
+		if pref.EmailMode == "instant" && pref.Email != "" {
+			go emailNotifications(pref, notification.Title, notification.Message, []uint{notification.ID})
+		}
+	}
+}
+
+// NotifySuperAdmins notifies every super admin about an event that needs their attention
+func NotifySuperAdmins(kind string, suggestionID uint, title, message string) {
+	var ids []uint
+	if err := database.DB.Model(&models.AdminUser{}).Where("role = ?", "super_admin").Pluck("id", &ids).Error; err != nil {
+		log.Println("Failed to load super admins for notification:", err)
+		return
+	}
+	NotifyAdmins(ids, kind, suggestionID, title, message)
+}
+
+// ResponsibleAdmins returns the admins of a suggestion's department and its assignee
+func ResponsibleAdmins(suggestion *models.Suggestion) []uint {
+	var ids []uint
+	if suggestion.DepartmentID != nil {
+		database.DB.Model(&models.AdminUser{}).
+			Where("role = ? AND department_id = ?", "department_admin", *suggestion.DepartmentID).
+			Pluck("id", &ids)
+	}
+	if suggestion.AssigneeID != nil {
+		ids = append(ids, *suggestion.AssigneeID)
+	}
+	return ids
+}
+
+// NotifyResponsibleAdmins notifies the admins of a suggestion's department and its assignee
+func NotifyResponsibleAdmins(suggestion *models.Suggestion, kind, title, message string) {
+	NotifyAdmins(ResponsibleAdmins(suggestion), kind, suggestion.ID, title, message)
+}
+
+// emailNotifications emails notifications to an admin and marks them as emailed
+func emailNotifications(pref models.NotificationPreference, subject, body string, ids []uint) bool {
+	err := mailer.Default.Send(mailer.Message{To: pref.Email, Subject: subject, Body: body})
+	if err != nil {
+		log.Printf("Failed to email notifications to admin %d: %v", pref.AdminID, err)
+		return false
+	}
+	database.DB.Model(&models.Notification{}).Where("id IN ?", ids).Update("emailed_at", time.Now())
+	return true
+}
+
+// SendDailyDigests emails each admin in daily mode their unread notifications not emailed yet, once a day after digestHour
+func SendDailyDigests() {
+	now := time.Now()
+	if now.Hour() < digestHour {
+		return
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), digestHour, 0, 0, 0, now.Location())
+
+	var prefs []models.NotificationPreference
+	err := database.DB.Where("email_mode = ? AND email <> ''", "daily").
+		Where("last_digest_at IS NULL OR last_digest_at < ?", today).Find(&prefs).Error
+	if err != nil {
+		log.Println("Failed to load digest preferences:", err)
+		return
+	}
+
+	for _, pref := range prefs {
+		var notifications []models.Notification
+		database.DB.Where("admin_id = ? AND read_at IS NULL AND emailed_at IS NULL", pref.AdminID).
+			Order("created_at").Find(&notifications)
+
+		if len(notifications) > 0 {
+			var body strings.Builder
+			ids := make([]uint, len(notifications))
+			fmt.Fprintf(&body, "您有 %d 条未读通知：\n\n", len(notifications))
+			for i, n := range notifications {
+				ids[i] = n.ID
+				fmt.Fprintf(&body, "[%s] %s\n%s\n\n", n.CreatedAt.Format("2006-01-02 15:04"), n.Title, n.Message)
+			}
+			if !emailNotifications(pref, fmt.Sprintf("每日通知摘要（%d 条未读）", len(notifications)), body.String(), ids) {
+				continue // Retried at the next check
+			}
+		}
+		database.DB.Model(&pref).Update("last_digest_at", now)
+	}
+}
+
+// StartDigestScheduler periodically sends the daily notification digests in the background
+func StartDigestScheduler() {
+	go func() {
+		ticker := time.NewTicker(digestCheckInterval)
+		defer ticker.Stop()
+		for {
+			SendDailyDigests()
+			<-ticker.C
+		}
+	}()
+}
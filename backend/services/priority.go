@@ -0,0 +1,30 @@
+package services
+
+// Priorities lists the suggestion priority levels from lowest to highest
+var Priorities = []string{"low", "normal", "high", "urgent"}
+
+// PriorityRankSQL ranks a suggestion's priority from 0 (low) to 3 (urgent) for sorting
+const PriorityRankSQL = "CASE priority WHEN 'urgent' THEN 3 WHEN 'high' THEN 2 WHEN 'normal' THEN 1 ELSE 0 END"
+
+// IsValidPriority reports whether p is a known priority level
+func IsValidPriority(p string) bool {
+	for _, priority := range Priorities {
+		if priority == p {
+			return true
+		}
+	}
+	return false
+}
+
+// EscalatePriority returns the priority one level above p
+func EscalatePriority(p string) string {
+	for i, priority := range Priorities {
+		if priority == p && i+1 < len(Priorities) {
+			return Priorities[i+1]
+		}
+	}
+	if IsValidPriority(p) {
+		return p
+	}
+	return "high"
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"advice/models"
+
+	"gorm.io/gorm"
+)
+
+// ReactionTypes are the public reactions besides upvotes
+var ReactionTypes = []string{"me_too", "disagree"}
+
+// IsValidReactionType reports whether t is one of ReactionTypes
+func IsValidReactionType(t string) bool {
+	for _, r := range ReactionTypes {
+		if r == t {
+			return true
+		}
+	}
+	return false
+}
+
+// RecountInteractions recalculates the reaction and approved comment counts of a suggestion
+func RecountInteractions(tx *gorm.DB, suggestionID uint) error {
+	count := func(model interface{}, query string, args ...interface{}) *gorm.DB {
+		return tx.Model(model).Select("COUNT(*)").Where("suggestion_id = ?", suggestionID).Where(query, args...)
+	}
+	return tx.Model(&models.Suggestion{}).Where("id = ?", suggestionID).Updates(map[string]interface{}{
+		"me_too_count":   count(&models.Reaction{}, "type = ?", "me_too"),
+		"disagree_count": count(&models.Reaction{}, "type = ?", "disagree"),
+		"comment_count":  count(&models.Comment{}, "status = ?", "已通过"),
+	}).Error
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"advice/database"
+	"advice/models"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// SplitKeywords splits a comma separated keyword list, accepting both ASCII and full-width commas
+func SplitKeywords(keywords string) []string {
+	var result []string
+	for _, k := range strings.FieldsFunc(keywords, func(r rune) bool { return r == ',' || r == '，' }) {
+		if k = strings.TrimSpace(k); k != "" {
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+// RuleMatches reports whether a routing rule matches a suggestion's text and category
+func RuleMatches(rule *models.RoutingRule, title, content, category string) bool {
+	if rule.Category != "" && rule.Category != category {
+		return false
+	}
+
+	text := strings.ToLower(title + "\n" + content)
+	if keywords := SplitKeywords(rule.Keywords); len(keywords) > 0 {
+		found := false
+		for _, k := range keywords {
+			if strings.Contains(text, strings.ToLower(k)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if rule.Pattern != "" {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			log.Printf("Invalid pattern in routing rule %d: %v", rule.ID, err)
+			return false
+		}
+		if !re.MatchString(title + "\n" + content) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchingRoutingRules returns every active routing rule matching the text, in the order they are tried
+func MatchingRoutingRules(title, content, category string) []models.RoutingRule {
+	var rules []models.RoutingRule
+	if err := database.DB.Preload("Department").Where("is_active = ?", true).Order("priority ASC, id ASC").Find(&rules).Error; err != nil {
+		log.Println("Failed to load routing rules:", err)
+		return nil
+	}
+
+	var matched []models.RoutingRule
+	for _, rule := range rules {
+		if RuleMatches(&rule, title, content, category) {
+			matched = append(matched, rule)
+		}
+	}
+	return matched
+}
+
+// RouteSuggestion assigns the department of the first matching routing rule to a suggestion without one.
+// It reports whether a rule fired.
+func RouteSuggestion(suggestion *models.Suggestion) bool {
+	if suggestion.DepartmentID != nil {
+		return false
+	}
+
+	matched := MatchingRoutingRules(suggestion.Title, suggestion.Content, suggestion.Category)
+	if len(matched) == 0 {
+		return false
+	}
+
+	rule := matched[0]
+	suggestion.DepartmentID = &rule.DepartmentID
+	suggestion.RoutedByRuleID = &rule.ID
+	return true
+}
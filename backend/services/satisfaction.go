@@ -0,0 +1,37 @@
+package services
+
+import (
+	"advice/models"
+	"advice/utils"
+	"time"
+)
+
+// MaxUnsatisfiedScore is the highest rating that still allows the student to reopen a resolution
+const MaxUnsatisfiedScore = 2
+
+// reopenWindow is how long after resolution the student may reopen a suggestion, configured by REOPEN_WINDOW_DAYS
+var reopenWindow = time.Duration(utils.GetenvInt("REOPEN_WINDOW_DAYS", 7)) * 24 * time.Hour
+
+// MarkResolved records who resolved a suggestion and opens its reopen window
+func MarkResolved(suggestion *models.Suggestion, adminID uint) {
+	now := time.Now()
+	until := now.Add(reopenWindow)
+	suggestion.ResolvedAt = &now
+	suggestion.ResolvedByID = &adminID
+	suggestion.ReopenableUntil = &until
+}
+
+// ClearResolution forgets the resolution of a suggestion that is no longer resolved
+func ClearResolution(suggestion *models.Suggestion) {
+	suggestion.ResolvedAt = nil
+	suggestion.ResolvedByID = nil
+	suggestion.ReopenableUntil = nil
+}
+
+// RatedAdmin returns the admin a rating is credited to: the assignee, or else the admin who resolved the suggestion
+func RatedAdmin(suggestion *models.Suggestion) *uint {
+	if suggestion.AssigneeID != nil {
+		return suggestion.AssigneeID
+	}
+	return suggestion.ResolvedByID
+}
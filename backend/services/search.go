@@ -0,0 +1,154 @@
+package services
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/utils"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SearchHit is a suggestion matching a full-text search, lower scores rank higher
+type SearchHit struct {
+	SuggestionID uint
+	Score        float64
+}
+
+// searchRankSQL weighs title matches above content matches above reply matches
+const searchRankSQL = "bm25(suggestion_fts, 0, 10.0, 5.0, 2.0)"
+
+// indexEntry builds the tokenized search index columns of a suggestion.
+// Personal information is indexed masked, as it is displayed publicly, so searching for a guessed
+// phone or student number does not confirm it. Admins find raw text with the keyword filter.
+func indexEntry(suggestion *models.Suggestion) (title, content, replies string) {
+	var replyTexts []string
+	for _, r := range suggestion.Replies {
+		replyTexts = append(replyTexts, r.Content)
+	}
+	return utils.TokenizeForIndex(MaskPII(suggestion.Title)),
+		utils.TokenizeForIndex(MaskPII(suggestion.Content)),
+		utils.TokenizeForIndex(MaskPII(strings.Join(replyTexts, "\n")))
+}
+
+func writeIndexEntry(tx *gorm.DB, suggestion *models.Suggestion) error {
+	title, content, replies := indexEntry(suggestion)
+	if err := tx.Exec("DELETE FROM suggestion_fts WHERE suggestion_id = ?", suggestion.ID).Error; err != nil {
+		return err
+	}
+	return tx.Exec("INSERT INTO suggestion_fts (suggestion_id, title, content, replies) VALUES (?, ?, ?, ?)",
+		suggestion.ID, title, content, replies).Error
+}
+
+// IndexSuggestion updates the search and similarity index entries of a suggestion and its replies
+func IndexSuggestion(id uint) {
+	var suggestion models.Suggestion
+	if err := database.DB.Preload("Replies").First(&suggestion, id).Error; err != nil {
+		log.Printf("Failed to load suggestion %d for indexing: %v", id, err)
+		return
+	}
+	simIndex.put(suggestion.ID, shingles(suggestion.Title, suggestion.Content))
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return writeIndexEntry(tx, &suggestion)
+	})
+	if err != nil {
+		log.Printf("Failed to index suggestion %d: %v", id, err)
+	}
+}
+
+// RemoveFromSearchIndex deletes the search and similarity index entries of the given suggestions
+func RemoveFromSearchIndex(ids []uint) {
+	for _, id := range ids {
+		simIndex.remove(id)
+	}
+	if err := database.DB.Exec("DELETE FROM suggestion_fts WHERE suggestion_id IN ?", ids).Error; err != nil {
+		log.Println("Failed to remove suggestions from search index:", err)
+	}
+}
+
+// searchIndexVersion is bumped when the tokens written to the index change, it is kept in PRAGMA user_version
+const searchIndexVersion = 2
+
+// RebuildSearchIndex reindexes every suggestion when the index is out of sync with the suggestions table
+// or was built with older tokens
+func RebuildSearchIndex() {
+	var indexed, total, version int64
+	database.DB.Table("suggestion_fts").Count(&indexed)
+	database.DB.Model(&models.Suggestion{}).Count(&total)
+	database.DB.Raw("PRAGMA user_version").Scan(&version)
+	if indexed == total && version >= searchIndexVersion {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM suggestion_fts").Error; err != nil {
+			return err
+		}
+		var suggestions []models.Suggestion
+		return tx.Preload("Replies").FindInBatches(&suggestions, 200, func(batch *gorm.DB, _ int) error {
+			for i := range suggestions {
+				if err := writeIndexEntry(tx, &suggestions[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	})
+	if err != nil {
+		log.Println("Failed to rebuild search index:", err)
+		return
+	}
+	if err := database.DB.Exec(fmt.Sprintf("PRAGMA user_version = %d", searchIndexVersion)).Error; err != nil {
+		log.Println("Failed to record search index version:", err)
+	}
+	log.Printf("Rebuilt search index with %d suggestions", total)
+}
+
+// BuildMatchQuery converts search text into an FTS5 query requiring every term.
+// Chinese terms become phrases of consecutive bigrams, single characters are prefix matched,
+// which finds them in bigrams and, at the end of a run, in the extra single character token.
+// It returns an empty string when the text has nothing to search for.
+func BuildMatchQuery(text string) string {
+	var clauses []string
+	for _, term := range utils.SplitTerms(text) {
+		if len([]rune(term)) == 1 {
+			clauses = append(clauses, `"`+term+`"*`)
+			continue
+		}
+		clauses = append(clauses, `"`+strings.Join(utils.Bigrams(term), " ")+`"`)
+	}
+	return strings.Join(clauses, " AND ")
+}
+
+// SearchSuggestions returns a page of suggestions matching the search text, best match first.
+// scope restricts the suggestions that may be returned, the suggestions table is available as "suggestions".
+func SearchSuggestions(text string, scope func(*gorm.DB) *gorm.DB, limit, offset int) ([]SearchHit, int64, error) {
+	match := BuildMatchQuery(text)
+	if match == "" {
+		return nil, 0, nil
+	}
+
+	base := func() *gorm.DB {
+		query := database.DB.Table("suggestion_fts").
+			Joins("JOIN suggestions ON suggestions.id = suggestion_fts.suggestion_id").
+			Where("suggestion_fts MATCH ?", match)
+		return scope(query)
+	}
+
+	var total int64
+	if err := base().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var hits []SearchHit
+	err := base().
+		Select("suggestion_fts.suggestion_id, " + searchRankSQL + " AS score").
+		Order("score").
+		Limit(limit).
+		Offset(offset).
+		Scan(&hits).Error
+	return hits, total, err
+}
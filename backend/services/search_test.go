@@ -0,0 +1,55 @@
+package services
+
+import (
+	"advice/database"
+	"advice/models"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestSearchDoesNotMatchMaskedPersonalInfo(t *testing.T) {
+	suggestion := models.Suggestion{
+		TrackingCode: "SRCH01",
+		Title:        "宿舍热水器坏了",
+		Content:      "三号楼热水器一直不出热水，请联系我 13812345678",
+		Category:     "其他",
+		Status:       "待处理",
+	}
+	if err := database.DB.Create(&suggestion).Error; err != nil {
+		t.Fatal(err)
+	}
+	IndexSuggestion(suggestion.ID)
+	t.Cleanup(func() {
+		RemoveFromSearchIndex([]uint{suggestion.ID})
+		database.DB.Delete(&suggestion)
+	})
+
+	all := func(query *gorm.DB) *gorm.DB { return query }
+	if _, total, err := SearchSuggestions("热水器", all, 10, 0); err != nil || total != 1 {
+		t.Errorf("search for 热水器: total = %d, err = %v, want 1 hit", total, err)
+	}
+	if _, total, err := SearchSuggestions("13812345678", all, 10, 0); err != nil || total != 0 {
+		t.Errorf("search for the phone number: total = %d, err = %v, want no hits", total, err)
+	}
+
+	// A right and a wrong guess of the masked digits score alike
+	allowAll := func(ids []uint) map[uint]bool {
+		permitted := map[uint]bool{}
+		for _, id := range ids {
+			permitted[id] = true
+		}
+		return permitted
+	}
+	score := func(content string) float64 {
+		for _, hit := range FindSimilar("热水器", content, 0, 0, 10, allowAll) {
+			if hit.SuggestionID == suggestion.ID {
+				return hit.Score
+			}
+		}
+		return 0
+	}
+	if right, wrong := score("13812345678"), score("13800000678"); right != wrong {
+		t.Errorf("similarity of the right phone number %v, of a wrong one %v, want equal", right, wrong)
+	}
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/utils"
+	"log"
+	"sort"
+	"sync"
+)
+
+// SimilarHit is a suggestion whose text resembles a query text
+type SimilarHit struct {
+	SuggestionID uint    `json:"suggestion_id"`
+	Score        float64 `json:"score"` // Jaccard similarity of the character bigrams, from 0 to 1
+}
+
+// similarityIndex is an in-memory inverted index from character bigrams to suggestions
+type similarityIndex struct {
+	mu       sync.RWMutex
+	docs     map[uint]map[string]struct{}
+	postings map[string]map[uint]struct{}
+}
+
+var simIndex = &similarityIndex{
+	docs:     map[uint]map[string]struct{}{},
+	postings: map[string]map[uint]struct{}{},
+}
+
+// shingles returns the set of character bigrams of a suggestion's title and content.
+// Personal information is masked first, so a guessed phone number cannot be confirmed through similarity hints.
+func shingles(title, content string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, token := range utils.BigramTokens(MaskPII(title) + "\n" + MaskPII(content)) {
+		set[token] = struct{}{}
+	}
+	return set
+}
+
+func (idx *similarityIndex) put(id uint, set map[string]struct{}) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeLocked(id)
+	idx.docs[id] = set
+	for token := range set {
+		if idx.postings[token] == nil {
+			idx.postings[token] = map[uint]struct{}{}
+		}
+		idx.postings[token][id] = struct{}{}
+	}
+}
+
+func (idx *similarityIndex) remove(id uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(id)
+}
+
+func (idx *similarityIndex) removeLocked(id uint) {
+	for token := range idx.docs[id] {
+		delete(idx.postings[token], id)
+		if len(idx.postings[token]) == 0 {
+			delete(idx.postings, token)
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// query scores every indexed suggestion sharing a bigram with set, best match first
+func (idx *similarityIndex) query(set map[string]struct{}, minScore float64) []SimilarHit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	overlap := map[uint]int{}
+	for token := range set {
+		for id := range idx.postings[token] {
+			overlap[id]++
+		}
+	}
+
+	var hits []SimilarHit
+	for id, shared := range overlap {
+		score := float64(shared) / float64(len(set)+len(idx.docs[id])-shared)
+		if score >= minScore {
+			hits = append(hits, SimilarHit{SuggestionID: id, Score: score})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].SuggestionID > hits[j].SuggestionID
+	})
+	return hits
+}
+
+// LoadSimilarityIndex builds the similarity index from every suggestion in the database
+func LoadSimilarityIndex() {
+	var suggestions []models.Suggestion
+	if err := database.DB.Select("id", "title", "content").Find(&suggestions).Error; err != nil {
+		log.Println("Failed to load similarity index:", err)
+		return
+	}
+	for _, s := range suggestions {
+		simIndex.put(s.ID, shingles(s.Title, s.Content))
+	}
+}
+
+// FindSimilar returns up to limit suggestions whose text resembles the given title and content,
+// skipping the suggestion excludeID. allowed filters the candidate IDs, for example by visibility.
+func FindSimilar(title, content string, excludeID uint, minScore float64, limit int, allowed func(ids []uint) map[uint]bool) []SimilarHit {
+	set := shingles(title, content)
+	if len(set) == 0 {
+		return nil
+	}
+
+	hits := simIndex.query(set, minScore)
+	ids := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.SuggestionID)
+	}
+	permitted := allowed(ids)
+
+	var result []SimilarHit
+	for _, hit := range hits {
+		if hit.SuggestionID == excludeID || !permitted[hit.SuggestionID] {
+			continue
+		}
+		result = append(result, hit)
+		if len(result) == limit {
+			break
+		}
+	}
+	return result
+}
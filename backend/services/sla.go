@@ -0,0 +1,257 @@
+package services
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/utils"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const slaCheckInterval = 5 * time.Minute
+
+// slaWarningWindow is how long before an SLA deadline admins are warned, configured by SLA_WARNING_HOURS
+var slaWarningWindow = time.Duration(utils.GetenvInt("SLA_WARNING_HOURS", 24)) * time.Hour
+
+// ClosedStatuses are the statuses that no longer need any action from admins
+var ClosedStatuses = []string{"已解决", "已关闭", "审核不通过", "已合并", "已撤回"}
+
+// Calendar holds the holidays and make-up working days used for working-day calculation
+type Calendar struct {
+	holidays map[string]bool
+	workdays map[string]bool
+}
+
+// LoadCalendar reads the holiday calendar from the database
+func LoadCalendar() Calendar {
+	cal := Calendar{holidays: map[string]bool{}, workdays: map[string]bool{}}
+
+	var holidays []models.Holiday
+	database.DB.Find(&holidays)
+	for _, h := range holidays {
+		if h.IsWorkday {
+			cal.workdays[h.Date] = true
+		} else {
+			cal.holidays[h.Date] = true
+		}
+	}
+	return cal
+}
+
+// IsWorkingDay reports whether t is a working day according to the calendar
+func (cal Calendar) IsWorkingDay(t time.Time) bool {
+	day := t.Format("2006-01-02")
+	if cal.workdays[day] {
+		return true
+	}
+	if cal.holidays[day] {
+		return false
+	}
+	return !utils.IsWeekend(t)
+}
+
+// FindSLAPolicy returns the most specific policy matching the suggestion's department and category
+func FindSLAPolicy(suggestion *models.Suggestion) *models.SLAPolicy {
+	var policies []models.SLAPolicy
+	database.DB.Find(&policies)
+
+	var best *models.SLAPolicy
+	bestScore := -1
+	for i, p := range policies {
+		score := 0
+		if p.DepartmentID != nil {
+			if suggestion.DepartmentID == nil || *p.DepartmentID != *suggestion.DepartmentID {
+				continue
+			}
+			score += 2
+		}
+		if p.Category != "" {
+			if p.Category != suggestion.Category {
+				continue
+			}
+			score++
+		}
+		if score > bestScore {
+			best = &policies[i]
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// ApplySLA computes the due dates of a suggestion from its submission time
+func ApplySLA(suggestion *models.Suggestion) {
+	policy := FindSLAPolicy(suggestion)
+	if policy == nil {
+		suggestion.FirstReplyDueAt = nil
+		suggestion.ResolutionDueAt = nil
+		return
+	}
+
+	start := suggestion.CreatedAt
+	if start.IsZero() {
+		start = time.Now()
+	}
+	cal := LoadCalendar()
+	firstReplyDue := utils.AddWorkingDays(start, policy.FirstReplyDays, cal.IsWorkingDay)
+	resolutionDue := utils.AddWorkingDays(start, policy.ResolutionDays, cal.IsWorkingDay)
+	suggestion.FirstReplyDueAt = &firstReplyDue
+	suggestion.ResolutionDueAt = &resolutionDue
+}
+
+// WhereOverdue restricts a suggestion query to open suggestions that have passed an SLA deadline
+func WhereOverdue(query *gorm.DB, now time.Time) *gorm.DB {
+	return query.Where("status NOT IN ?", ClosedStatuses).
+		Where("(first_replied_at IS NULL AND first_reply_due_at < ?) OR resolution_due_at < ?", now, now)
+}
+
+// IsOverdue reports whether a suggestion is open and has passed an SLA deadline, matching WhereOverdue
+func IsOverdue(s *models.Suggestion, now time.Time) bool {
+	for _, closed := range ClosedStatuses {
+		if s.Status == closed {
+			return false
+		}
+	}
+	return (s.FirstRepliedAt == nil && s.FirstReplyDueAt != nil && s.FirstReplyDueAt.Before(now)) ||
+		(s.ResolutionDueAt != nil && s.ResolutionDueAt.Before(now))
+}
+
+// slaDeadline is one of the two SLA deadlines of a suggestion, escalated and warned about separately
+type slaDeadline struct {
+	name        string
+	pending     string // Condition for the deadline to still apply, empty when it applies until the suggestion closes
+	dueColumn   string
+	escalatedAt string
+	warnedAt    string
+	due         func(s *models.Suggestion) *time.Time
+}
+
+// open restricts a suggestion query to open suggestions the deadline still applies to
+func (d slaDeadline) open(query *gorm.DB) *gorm.DB {
+	query = query.Where("status NOT IN ?", ClosedStatuses)
+	if d.pending != "" {
+		query = query.Where(d.pending)
+	}
+	return query
+}
+
+var slaDeadlines = []slaDeadline{
+	{
+		name:        "首次回复",
+		pending:     "first_replied_at IS NULL",
+		dueColumn:   "first_reply_due_at",
+		escalatedAt: "first_reply_escalated_at",
+		warnedAt:    "first_reply_warned_at",
+		due:         func(s *models.Suggestion) *time.Time { return s.FirstReplyDueAt },
+	},
+	{
+		name:        "解决",
+		dueColumn:   "resolution_due_at",
+		escalatedAt: "resolution_escalated_at",
+		warnedAt:    "resolution_warned_at",
+		due:         func(s *models.Suggestion) *time.Time { return s.ResolutionDueAt },
+	},
+}
+
+// CheckOverdueSuggestions escalates suggestions that have newly missed an SLA deadline, once per deadline,
+// and updates the overdue flag of suggestions that were replied to, closed or rescheduled since
+func CheckOverdueSuggestions() {
+	now := time.Now()
+
+	for _, deadline := range slaDeadlines {
+		var suggestions []models.Suggestion
+		err := deadline.open(database.DB).
+			Where(deadline.escalatedAt+" IS NULL AND "+deadline.dueColumn+" < ?", now).
+			Find(&suggestions).Error
+		if err != nil {
+			log.Println("Failed to check overdue suggestions:", err)
+			return
+		}
+
+		for _, s := range suggestions {
+			priority := EscalatePriority(s.Priority)
+			err := database.DB.Model(&s).Updates(map[string]interface{}{
+				"is_overdue":         true,
+				deadline.escalatedAt: now,
+				"priority":           priority,
+			}).Error
+			if err != nil {
+				log.Printf("Failed to escalate suggestion %d: %v", s.ID, err)
+				continue
+			}
+
+			s.IsOverdue, s.Priority = true, priority
+			PublishSuggestionEvent(EventSuggestionUpdated, &s)
+
+			message := fmt.Sprintf("建议 #%d「%s」已超过%s时限，优先级已提升为 %s", s.ID, s.Title, deadline.name, priority)
+			NotifyAdmins(ResponsibleAdmins(&s), NotificationSLAWarning, s.ID, "建议处理超时", message)
+			NotifySuperAdmins(NotificationSLAWarning, s.ID, "建议处理超时", message)
+		}
+	}
+
+	refreshOverdueFlags(now)
+	warnApproachingDeadlines(now)
+}
+
+// refreshOverdueFlags recomputes is_overdue for suggestions whose flag no longer matches WhereOverdue
+func refreshOverdueFlags(now time.Time) {
+	overdueIDs := WhereOverdue(database.DB.Model(&models.Suggestion{}).Select("id"), now)
+
+	var stale []models.Suggestion
+	err := database.DB.Where("is_overdue = ? AND id NOT IN (?)", true, overdueIDs).
+		Or("is_overdue = ? AND id IN (?)", false, overdueIDs).
+		Find(&stale).Error
+	if err != nil {
+		log.Println("Failed to refresh overdue flags:", err)
+		return
+	}
+	for _, s := range stale {
+		if err := database.DB.Model(&s).Update("is_overdue", !s.IsOverdue).Error; err != nil {
+			log.Printf("Failed to update overdue flag of suggestion %d: %v", s.ID, err)
+			continue
+		}
+		s.IsOverdue = !s.IsOverdue
+		PublishSuggestionEvent(EventSuggestionUpdated, &s)
+	}
+}
+
+// warnApproachingDeadlines notifies responsible admins once per deadline about open suggestions whose SLA deadline is near
+func warnApproachingDeadlines(now time.Time) {
+	soon := now.Add(slaWarningWindow)
+
+	for _, deadline := range slaDeadlines {
+		var suggestions []models.Suggestion
+		err := deadline.open(database.DB).
+			Where(deadline.warnedAt+" IS NULL AND "+deadline.escalatedAt+" IS NULL").
+			Where(deadline.dueColumn+" >= ? AND "+deadline.dueColumn+" < ?", now, soon).
+			Find(&suggestions).Error
+		if err != nil {
+			log.Println("Failed to check approaching SLA deadlines:", err)
+			return
+		}
+
+		for _, s := range suggestions {
+			if err := database.DB.Model(&s).Update(deadline.warnedAt, now).Error; err != nil {
+				log.Printf("Failed to warn about suggestion %d: %v", s.ID, err)
+				continue
+			}
+			NotifyResponsibleAdmins(&s, NotificationSLAWarning, "建议即将超过处理时限",
+				fmt.Sprintf("建议 #%d「%s」将于 %s 超过%s时限，请及时处理", s.ID, s.Title, deadline.due(&s).Format("2006-01-02 15:04"), deadline.name))
+		}
+	}
+}
+
+// StartSLAScheduler periodically checks for overdue suggestions in the background
+func StartSLAScheduler() {
+	go func() {
+		ticker := time.NewTicker(slaCheckInterval)
+		defer ticker.Stop()
+		for {
+			CheckOverdueSuggestions()
+			<-ticker.C
+		}
+	}()
+}
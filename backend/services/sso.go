@@ -0,0 +1,91 @@
+package services
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/sso"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSSONoRole         = errors.New("no admin role is mapped to the external account")
+	ErrSSONoDepartment   = errors.New("no valid department is mapped to the external account")
+	ErrSSOUsernameTaken  = errors.New("the username of the external account belongs to a local admin")
+	ErrSSOMissingAccount = errors.New("the admin linked to the external account no longer exists")
+)
+
+// ProvisionSSOAdmin returns the admin an external identity signs in as, creating it on first login.
+// New admins get their role from the role mappings and have no password, so they can only sign in through SSO.
+// Later changes to the mappings or groups don't affect existing admins, which super admins manage as usual.
+// An identity with the username of a local admin is refused, unless a super admin linked it to that admin,
+// or sso.LinkLocalAccounts is set, the username is verified and the admin is not a super admin.
+func ProvisionSSOAdmin(identity *sso.Identity) (*models.AdminUser, error) {
+	now := time.Now()
+	username := identity.Username
+	if username == "" {
+		username = identity.Subject
+	}
+
+	var link models.AdminIdentity
+	err := database.DB.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&link).Error
+	if err == nil {
+		var admin models.AdminUser
+		if err := database.DB.First(&admin, link.AdminID).Error; err != nil {
+			return nil, ErrSSOMissingAccount
+		}
+		database.DB.Model(&link).Updates(map[string]interface{}{"username": username, "email": identity.Email, "last_login_at": now})
+		return &admin, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	link = models.AdminIdentity{
+		Provider:    identity.Provider,
+		Subject:     identity.Subject,
+		Username:    username,
+		Email:       identity.Email,
+		LastLoginAt: &now,
+	}
+
+	var existing models.AdminUser
+	if err := database.DB.Where("username = ?", username).First(&existing).Error; err == nil {
+		if !sso.LinkLocalAccounts || !identity.UsernameVerified || existing.Role == "super_admin" {
+			return nil, ErrSSOUsernameTaken
+		}
+		link.AdminID = existing.ID
+		if err := database.DB.Create(&link).Error; err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+
+	role, departmentID, ok := sso.MapRole(identity.Groups)
+	if !ok {
+		return nil, ErrSSONoRole
+	}
+	if role == "department_admin" {
+		if departmentID == nil {
+			return nil, ErrSSONoDepartment
+		}
+		if err := database.DB.First(&models.Department{}, *departmentID).Error; err != nil {
+			return nil, ErrSSONoDepartment
+		}
+	}
+
+	admin := models.AdminUser{Username: username, Role: role, DepartmentID: departmentID}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&admin).Error; err != nil {
+			return err
+		}
+		link.AdminID = admin.ID
+		return tx.Create(&link).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
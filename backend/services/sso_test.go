@@ -0,0 +1,63 @@
+package services
+
+import (
+	"advice/database"
+	"advice/models"
+	"advice/sso"
+	"errors"
+	"testing"
+)
+
+func createLocalAdmin(t *testing.T, username, role string) models.AdminUser {
+	admin := models.AdminUser{Username: username, PasswordHash: "x", Role: role}
+	if role == "department_admin" {
+		departmentID := uint(1)
+		admin.DepartmentID = &departmentID
+	}
+	if err := database.DB.Create(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	return admin
+}
+
+func TestProvisionSSOAdminLinking(t *testing.T) {
+	previous := sso.LinkLocalAccounts
+	t.Cleanup(func() { sso.LinkLocalAccounts = previous })
+	local := createLocalAdmin(t, "zhang", "department_admin")
+	root := createLocalAdmin(t, "root", "super_admin")
+
+	tests := []struct {
+		name     string
+		link     bool
+		identity sso.Identity
+		want     *models.AdminUser // nil when the login must be refused
+	}{
+		{"linking disabled", false, sso.Identity{Provider: "cas", Subject: "zhang", Username: "zhang", UsernameVerified: true}, nil},
+		{"unverified username", true, sso.Identity{Provider: "oidc", Subject: "sub-1", Username: "zhang"}, nil},
+		{"super admin", true, sso.Identity{Provider: "cas", Subject: "root", Username: "root", UsernameVerified: true}, nil},
+		{"verified username", true, sso.Identity{Provider: "oidc", Subject: "sub-2", Username: "zhang", UsernameVerified: true}, &local},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sso.LinkLocalAccounts = tt.link
+			admin, err := ProvisionSSOAdmin(&tt.identity)
+			if tt.want == nil {
+				if !errors.Is(err, ErrSSOUsernameTaken) {
+					t.Fatalf("err = %v, want ErrSSOUsernameTaken", err)
+				}
+				return
+			}
+			if err != nil || admin.ID != tt.want.ID {
+				t.Fatalf("signed in as %v, %v, want admin #%d", admin, err, tt.want.ID)
+			}
+		})
+	}
+
+	// A super admin links an account explicitly, which then signs in whatever the settings
+	sso.LinkLocalAccounts = false
+	database.DB.Create(&models.AdminIdentity{AdminID: root.ID, Provider: "oidc", Subject: "sub-root"})
+	admin, err := ProvisionSSOAdmin(&sso.Identity{Provider: "oidc", Subject: "sub-root", Username: "root"})
+	if err != nil || admin.ID != root.ID {
+		t.Fatalf("explicitly linked account signed in as %v, %v, want the super admin", admin, err)
+	}
+}
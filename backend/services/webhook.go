@@ -0,0 +1,282 @@
+package services
+
+import (
+	"advice/database"
+	"advice/events"
+	"advice/models"
+	"advice/utils"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	webhookPollInterval  = 5 * time.Second
+	webhookBatchSize     = 20
+	webhookResponseLimit = 1024
+	webhookBaseBackoff   = 30 * time.Second
+	webhookMaxBackoff    = 6 * time.Hour
+)
+
+// webhookMaxAttempts is how many times a delivery is tried before it fails, configured by WEBHOOK_MAX_ATTEMPTS
+var webhookMaxAttempts = utils.GetenvInt("WEBHOOK_MAX_ATTEMPTS", 8)
+
+var webhookClient = &http.Client{
+	Timeout: time.Duration(utils.GetenvInt("WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second,
+	// Redirects are not followed, the receiver must answer at the configured URL
+	CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// webhookWake triggers the delivery worker as soon as new deliveries are queued
+var webhookWake = make(chan struct{}, 1)
+
+// WebhookPayload is the JSON body posted to webhooks
+type WebhookPayload struct {
+	EventID    string      `json:"event_id"`
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// NewWebhookSecret generates a random signing secret
+func NewWebhookSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidateWebhookURL checks that a webhook URL is an absolute http(s) URL
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q", raw)
+	}
+	return nil
+}
+
+// IsValidEventType reports whether kind is a suggestion event type
+func IsValidEventType(kind string) bool {
+	for _, t := range EventTypes {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// SignWebhook returns the signature of a delivery: the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the webhook secret
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookMatches reports whether a webhook wants an event
+func webhookMatches(webhook models.Webhook, event events.Event) bool {
+	if webhook.EventTypes != "" {
+		wanted := false
+		for _, t := range strings.Split(webhook.EventTypes, ",") {
+			if strings.TrimSpace(t) == event.Type {
+				wanted = true
+				break
+			}
+		}
+		if !wanted {
+			return false
+		}
+	}
+	if webhook.DepartmentID == nil {
+		return true
+	}
+	for _, departmentID := range event.Departments {
+		if departmentID != nil && *departmentID == *webhook.DepartmentID {
+			return true
+		}
+	}
+	return false
+}
+
+// EnqueueWebhookDelivery queues a payload for a webhook and wakes the delivery worker
+func EnqueueWebhookDelivery(webhookID uint, payload WebhookPayload, replayOf *uint) (*models.WebhookDelivery, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return enqueueDelivery(webhookID, payload.Event, string(body), replayOf)
+}
+
+func enqueueDelivery(webhookID uint, eventType, body string, replayOf *uint) (*models.WebhookDelivery, error) {
+	now := time.Now()
+	delivery := models.WebhookDelivery{
+		WebhookID:     webhookID,
+		EventType:     eventType,
+		Payload:       body,
+		Status:        "pending",
+		Attempts:      0,
+		NextAttemptAt: &now,
+		ReplayOfID:    replayOf,
+	}
+	if err := database.DB.Create(&delivery).Error; err != nil {
+		return nil, err
+	}
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+	return &delivery, nil
+}
+
+// ReplayWebhookDelivery queues the payload of a past delivery again
+func ReplayWebhookDelivery(delivery *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	return enqueueDelivery(delivery.WebhookID, delivery.EventType, delivery.Payload, &delivery.ID)
+}
+
+// enqueueEvent queues an event for every active webhook that wants it
+func enqueueEvent(event events.Event) {
+	var webhooks []models.Webhook
+	if err := database.DB.Where("is_active = ?", true).Find(&webhooks).Error; err != nil {
+		log.Println("Failed to load webhooks:", err)
+		return
+	}
+	for _, webhook := range webhooks {
+		if !webhookMatches(webhook, event) {
+			continue
+		}
+		payload := WebhookPayload{EventID: event.ID, Event: event.Type, OccurredAt: event.Time, Data: event.Data}
+		if _, err := EnqueueWebhookDelivery(webhook.ID, payload, nil); err != nil {
+			log.Printf("Failed to queue event %s for webhook %d: %v", event.ID, webhook.ID, err)
+		}
+	}
+}
+
+// webhookBackoff is the delay before the next attempt after a failed one, doubling from webhookBaseBackoff
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookBaseBackoff
+	for i := 1; i < attempts && delay < webhookMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookMaxBackoff)
+}
+
+// attemptDelivery posts a delivery once and records the outcome
+func attemptDelivery(delivery *models.WebhookDelivery) {
+	var webhook models.Webhook
+	if err := database.DB.First(&webhook, delivery.WebhookID).Error; err != nil {
+		database.DB.Model(delivery).Updates(map[string]interface{}{"status": "failed", "next_attempt_at": nil, "error": "webhook no longer exists"})
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"attempts":        delivery.Attempts + 1,
+		"last_attempt_at": now,
+		"response_status": 0,
+		"response_body":   "",
+		"error":           "",
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "advice-webhooks/1.0")
+		req.Header.Set("X-Webhook-Event", delivery.EventType)
+		req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+		req.Header.Set("X-Webhook-Timestamp", timestamp)
+		req.Header.Set("X-Webhook-Signature", SignWebhook(webhook.Secret, timestamp, []byte(delivery.Payload)))
+
+		var resp *http.Response
+		resp, err = webhookClient.Do(req)
+		if err == nil {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+			resp.Body.Close()
+			updates["response_status"] = resp.StatusCode
+			updates["response_body"] = string(body)
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+			}
+		}
+	}
+
+	switch {
+	case err == nil:
+		updates["status"] = "succeeded"
+		updates["next_attempt_at"] = nil
+	case delivery.Attempts+1 >= webhookMaxAttempts:
+		updates["status"] = "failed"
+		updates["next_attempt_at"] = nil
+		updates["error"] = err.Error()
+	default:
+		updates["next_attempt_at"] = now.Add(webhookBackoff(delivery.Attempts + 1))
+		updates["error"] = err.Error()
+	}
+	if err := database.DB.Model(delivery).Updates(updates).Error; err != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// deliverDueWebhooks attempts the pending deliveries whose time has come, oldest first
+func deliverDueWebhooks() {
+	for {
+		var deliveries []models.WebhookDelivery
+		err := database.DB.Where("status = ? AND next_attempt_at <= ?", "pending", time.Now()).
+			Order("next_attempt_at, id").Limit(webhookBatchSize).Find(&deliveries).Error
+		if err != nil {
+			log.Println("Failed to load webhook deliveries:", err)
+			return
+		}
+		for i := range deliveries {
+			attemptDelivery(&deliveries[i])
+		}
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// StartWebhookDispatcher queues the events of the event bus for webhooks and delivers them in the background.
+// Deliveries are stored, so pending ones survive restarts.
+func StartWebhookDispatcher() {
+	go func() {
+		lastEventID := ""
+		for {
+			sub, missed, complete := events.Default.Subscribe(lastEventID)
+			if !complete {
+				log.Println("Webhook dispatcher fell behind, some events were not delivered")
+			}
+			for _, event := range missed {
+				enqueueEvent(event)
+				lastEventID = event.ID
+			}
+			// The bus closes the subscription if the dispatcher falls behind, then it resubscribes and catches up
+			for event := range sub.C {
+				enqueueEvent(event)
+				lastEventID = event.ID
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+		for {
+			deliverDueWebhooks()
+			select {
+			case <-ticker.C:
+			case <-webhookWake:
+			}
+		}
+	}()
+}
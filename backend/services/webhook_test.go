@@ -0,0 +1,209 @@
+package services
+
+import (
+	"advice/database"
+	"advice/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the requests posted to it and answers with status
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   []string
+}
+
+func newWebhookReceiver(t *testing.T, status int) (*webhookReceiver, *httptest.Server) {
+	r := &webhookReceiver{status: status}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, string(body))
+		r.mu.Unlock()
+		w.WriteHeader(r.status)
+		w.Write([]byte("received"))
+	}))
+	t.Cleanup(srv.Close)
+	return r, srv
+}
+
+func createWebhook(t *testing.T, url string) models.Webhook {
+	webhook := models.Webhook{Name: "test", URL: url, Secret: "webhook-test-secret", IsActive: true}
+	if err := database.DB.Create(&webhook).Error; err != nil {
+		t.Fatal(err)
+	}
+	return webhook
+}
+
+func queueTestDelivery(t *testing.T, webhookID uint) *models.WebhookDelivery {
+	delivery, err := EnqueueWebhookDelivery(webhookID, WebhookPayload{
+		EventID:    "42",
+		Event:      "suggestion.created",
+		OccurredAt: time.Now(),
+		Data:       map[string]interface{}{"id": 7, "title": "图书馆开放时间"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delivery
+}
+
+// reloadDelivery returns the stored state of a delivery
+func reloadDelivery(t *testing.T, id uint) *models.WebhookDelivery {
+	var delivery models.WebhookDelivery
+	if err := database.DB.First(&delivery, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &delivery
+}
+
+func TestWebhookDeliverySignature(t *testing.T) {
+	receiver, srv := newWebhookReceiver(t, http.StatusOK)
+	webhook := createWebhook(t, srv.URL+"/hook")
+	delivery := queueTestDelivery(t, webhook.ID)
+
+	attemptDelivery(delivery)
+
+	if len(receiver.requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(receiver.requests))
+	}
+	req, body := receiver.requests[0], receiver.bodies[0]
+	if body != delivery.Payload {
+		t.Errorf("body = %s, want the queued payload %s", body, delivery.Payload)
+	}
+	timestamp := req.Header.Get("X-Webhook-Timestamp")
+	if sent, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+		t.Errorf("X-Webhook-Timestamp = %q, want the current Unix time", timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write([]byte(timestamp + "." + body))
+	if got, want := req.Header.Get("X-Webhook-Signature"), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("X-Webhook-Signature = %q, want %q", got, want)
+	}
+	if req.Header.Get("X-Webhook-Event") != "suggestion.created" || req.Header.Get("X-Webhook-Delivery") != strconv.Itoa(int(delivery.ID)) {
+		t.Errorf("event headers = %q, %q", req.Header.Get("X-Webhook-Event"), req.Header.Get("X-Webhook-Delivery"))
+	}
+
+	stored := reloadDelivery(t, delivery.ID)
+	if stored.Status != "succeeded" || stored.Attempts != 1 || stored.NextAttemptAt != nil {
+		t.Errorf("delivery is %s after %d attempts, next at %v, want succeeded once", stored.Status, stored.Attempts, stored.NextAttemptAt)
+	}
+	if stored.ResponseStatus != http.StatusOK || stored.ResponseBody != "received" {
+		t.Errorf("recorded response %d %q", stored.ResponseStatus, stored.ResponseBody)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		6:  16 * time.Minute,
+		10: 4*time.Hour + 16*time.Minute,
+		11: 6 * time.Hour,
+		50: 6 * time.Hour,
+	}
+	for attempts, want := range tests {
+		if got := webhookBackoff(attempts); got != want {
+			t.Errorf("webhookBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestWebhookDeliveryRetriesThenFails(t *testing.T) {
+	previous := webhookMaxAttempts
+	webhookMaxAttempts = 3
+	t.Cleanup(func() { webhookMaxAttempts = previous })
+
+	receiver, srv := newWebhookReceiver(t, http.StatusServiceUnavailable)
+	delivery := queueTestDelivery(t, createWebhook(t, srv.URL).ID)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		before := time.Now()
+		attemptDelivery(delivery)
+		delivery = reloadDelivery(t, delivery.ID)
+		if delivery.Attempts != attempt || delivery.ResponseStatus != http.StatusServiceUnavailable || delivery.Error == "" {
+			t.Fatalf("attempt %d recorded %d attempts, status %d, error %q", attempt, delivery.Attempts, delivery.ResponseStatus, delivery.Error)
+		}
+		if attempt < 3 {
+			if delivery.Status != "pending" || delivery.NextAttemptAt == nil {
+				t.Fatalf("attempt %d: delivery is %s, want pending with a next attempt", attempt, delivery.Status)
+			}
+			if wait := delivery.NextAttemptAt.Sub(before); wait < webhookBackoff(attempt) || wait > webhookBackoff(attempt)+time.Second {
+				t.Errorf("attempt %d: next attempt in %v, want %v", attempt, wait, webhookBackoff(attempt))
+			}
+		}
+	}
+	if delivery.Status != "failed" || delivery.NextAttemptAt != nil {
+		t.Errorf("after webhookMaxAttempts the delivery is %s, next at %v, want failed", delivery.Status, delivery.NextAttemptAt)
+	}
+	if len(receiver.requests) != 3 {
+		t.Errorf("receiver got %d requests, want 3", len(receiver.requests))
+	}
+
+	// Failed deliveries are no longer picked up
+	deliverDueWebhooks()
+	if len(receiver.requests) != 3 {
+		t.Errorf("a failed delivery was attempted again")
+	}
+}
+
+func TestWebhookDeliveryDoesNotFollowRedirects(t *testing.T) {
+	target, targetSrv := newWebhookReceiver(t, http.StatusOK)
+	redirect := httptest.NewServer(http.RedirectHandler(targetSrv.URL, http.StatusFound))
+	t.Cleanup(redirect.Close)
+	delivery := queueTestDelivery(t, createWebhook(t, redirect.URL).ID)
+
+	attemptDelivery(delivery)
+
+	if len(target.requests) != 0 {
+		t.Fatal("the redirect was followed")
+	}
+	stored := reloadDelivery(t, delivery.ID)
+	if stored.Status != "pending" || stored.ResponseStatus != http.StatusFound {
+		t.Errorf("delivery is %s with status %d, want pending after a 302", stored.Status, stored.ResponseStatus)
+	}
+}
+
+func TestReplayWebhookDelivery(t *testing.T) {
+	receiver, srv := newWebhookReceiver(t, http.StatusOK)
+	original := queueTestDelivery(t, createWebhook(t, srv.URL).ID)
+	attemptDelivery(original)
+	original = reloadDelivery(t, original.ID)
+
+	replay, err := ReplayWebhookDelivery(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.ID == original.ID || replay.ReplayOfID == nil || *replay.ReplayOfID != original.ID {
+		t.Fatalf("replay #%d replays %v, want a new delivery of #%d", replay.ID, replay.ReplayOfID, original.ID)
+	}
+	if replay.Status != "pending" || replay.Attempts != 0 || replay.Payload != original.Payload {
+		t.Errorf("replay is %s after %d attempts, want a pending copy of the payload", replay.Status, replay.Attempts)
+	}
+
+	attemptDelivery(replay)
+	if len(receiver.requests) != 2 {
+		t.Fatalf("receiver got %d requests, want 2", len(receiver.requests))
+	}
+	if receiver.bodies[1] != receiver.bodies[0] {
+		t.Errorf("replayed body = %s, want %s", receiver.bodies[1], receiver.bodies[0])
+	}
+	if got := receiver.requests[1].Header.Get("X-Webhook-Delivery"); got != strconv.Itoa(int(replay.ID)) {
+		t.Errorf("replay was sent as delivery %s, want %d", got, replay.ID)
+	}
+	if original = reloadDelivery(t, original.ID); original.Status != "succeeded" || original.Attempts != 1 {
+		t.Errorf("replaying changed the original delivery to %s after %d attempts", original.Status, original.Attempts)
+	}
+}
@@ -0,0 +1,75 @@
+package sso
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CASProvider logs admins in with a CAS server, validating service tickets with the CAS 3.0 protocol
+type CASProvider struct {
+	URL             string
+	ServiceURL      string
+	GroupsAttribute string
+}
+
+type casResponse struct {
+	Success *struct {
+		User       string `xml:"user"`
+		Attributes struct {
+			Values []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"attributes"`
+	} `xml:"authenticationSuccess"`
+	Failure *struct {
+		Code    string `xml:"code,attr"`
+		Message string `xml:",chardata"`
+	} `xml:"authenticationFailure"`
+}
+
+// LoginURL returns the CAS login page URL the admin is redirected to
+func (p *CASProvider) LoginURL() string {
+	return p.URL + "/login?" + url.Values{"service": {p.ServiceURL}}.Encode()
+}
+
+// Validate checks a service ticket with the CAS server and returns the identity it belongs to
+func (p *CASProvider) Validate(ticket string) (*Identity, error) {
+	query := url.Values{"service": {p.ServiceURL}, "ticket": {ticket}}
+	resp, err := httpClient.Get(p.URL + "/p3/serviceValidate?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("cas validation: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cas validation: unexpected status %d", resp.StatusCode)
+	}
+
+	var result casResponse
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("cas validation response: %w", err)
+	}
+	if result.Failure != nil {
+		return nil, fmt.Errorf("cas validation failed: %s %s", result.Failure.Code, strings.TrimSpace(result.Failure.Message))
+	}
+	if result.Success == nil || strings.TrimSpace(result.Success.User) == "" {
+		return nil, fmt.Errorf("cas validation: no user in response")
+	}
+
+	user := strings.TrimSpace(result.Success.User)
+	identity := &Identity{Provider: "cas", Subject: user, Username: user, UsernameVerified: true}
+	for _, attr := range result.Success.Attributes.Values {
+		value := strings.TrimSpace(attr.Value)
+		switch attr.XMLName.Local {
+		case p.GroupsAttribute:
+			identity.Groups = append(identity.Groups, value)
+		case "mail", "email":
+			identity.Email = value
+		}
+	}
+	return identity, nil
+}
@@ -0,0 +1,85 @@
+package sso
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testServiceURL = "http://localhost:8080/api/v1/admin/sso/cas/callback"
+
+// newTestCAS starts a CAS server that validates the ticket "ST-valid" for the test service
+func newTestCAS(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cas/p3/serviceValidate" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		if r.URL.Query().Get("ticket") != "ST-valid" || r.URL.Query().Get("service") != testServiceURL {
+			w.Write([]byte(`<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas">
+  <cas:authenticationFailure code="INVALID_TICKET">Ticket ST-expired not recognized</cas:authenticationFailure>
+</cas:serviceResponse>`))
+			return
+		}
+		w.Write([]byte(`<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas">
+  <cas:authenticationSuccess>
+    <cas:user> 20230001 </cas:user>
+    <cas:attributes>
+      <cas:mail>wang@example.edu</cas:mail>
+      <cas:groups>staff</cas:groups>
+      <cas:groups>it</cas:groups>
+      <cas:displayName>王老师</cas:displayName>
+    </cas:attributes>
+  </cas:authenticationSuccess>
+</cas:serviceResponse>`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCASLoginURL(t *testing.T) {
+	p := &CASProvider{URL: "https://cas.example.edu/cas", ServiceURL: testServiceURL}
+	want := "https://cas.example.edu/cas/login?service=" + url.QueryEscape(testServiceURL)
+	if got := p.LoginURL(); got != want {
+		t.Errorf("LoginURL = %q, want %q", got, want)
+	}
+}
+
+func TestCASValidate(t *testing.T) {
+	srv := newTestCAS(t)
+	p := &CASProvider{URL: srv.URL + "/cas", ServiceURL: testServiceURL, GroupsAttribute: "groups"}
+
+	identity, err := p.Validate("ST-valid")
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if identity.Provider != "cas" || identity.Subject != "20230001" || identity.Username != "20230001" || !identity.UsernameVerified {
+		t.Errorf("identity = %+v", identity)
+	}
+	if identity.Email != "wang@example.edu" || strings.Join(identity.Groups, ",") != "staff,it" {
+		t.Errorf("attributes read as email %q and groups %v", identity.Email, identity.Groups)
+	}
+}
+
+func TestCASValidateRejectsTickets(t *testing.T) {
+	srv := newTestCAS(t)
+
+	p := &CASProvider{URL: srv.URL + "/cas", ServiceURL: testServiceURL, GroupsAttribute: "groups"}
+	if _, err := p.Validate("ST-expired"); err == nil || !strings.Contains(err.Error(), "INVALID_TICKET") {
+		t.Errorf("Validate of an unknown ticket: err = %v, want INVALID_TICKET", err)
+	}
+
+	// A ticket issued for another service is refused
+	other := &CASProvider{URL: srv.URL + "/cas", ServiceURL: "https://evil.example.com/callback", GroupsAttribute: "groups"}
+	if _, err := other.Validate("ST-valid"); err == nil {
+		t.Error("ticket was accepted for another service")
+	}
+
+	broken := &CASProvider{URL: srv.URL + "/missing", ServiceURL: testServiceURL}
+	if _, err := broken.Validate("ST-valid"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Validate against a wrong URL: err = %v, want a 404 error", err)
+	}
+}
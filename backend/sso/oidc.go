@@ -0,0 +1,250 @@
+package sso
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// discoveryTTL is how long the discovery document and keys are cached; unknown key IDs refresh the keys earlier
+const discoveryTTL = time.Hour
+
+// OIDCProvider logs admins in with the OpenID Connect authorization code flow, protected by PKCE
+type OIDCProvider struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+	GroupsClaim   string
+	// EmailDomains are the school's email domains, whose local parts are usernames at the provider
+	EmailDomains []string
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// idTokenClaims are the fixed claims of an ID token; the configurable username and groups claims are read separately
+type idTokenClaims struct {
+	Nonce string `json:"nonce"`
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+func getJSON(rawURL string, v interface{}) error {
+	resp, err := httpClient.Get(rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", rawURL, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// load fetches the discovery document and signing keys when they are missing or stale, or when force is set
+func (p *OIDCProvider) load(force bool) (*oidcDiscovery, map[string]*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil && !force && time.Since(p.fetchedAt) < discoveryTTL {
+		return p.discovery, p.keys, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := getJSON(p.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != p.Issuer {
+		return nil, nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", discovery.Issuer, p.Issuer)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return nil, nil, fmt.Errorf("oidc keys: %w", err)
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	p.discovery, p.keys, p.fetchedAt = &discovery, keys, time.Now()
+	return p.discovery, p.keys, nil
+}
+
+// AuthURL returns the authorization endpoint URL the admin is redirected to
+func (p *OIDCProvider) AuthURL(state, nonce, verifier string) (string, error) {
+	discovery, _, err := p.load(false)
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the identity in the verified ID token
+func (p *OIDCProvider) Exchange(code, verifier, nonce string) (*Identity, error) {
+	discovery, _, err := p.load(false)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("oidc token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.IDToken == "" {
+		return nil, fmt.Errorf("oidc token request failed: %s %s", token.Error, token.ErrorDescription)
+	}
+	return p.verify(token.IDToken, nonce)
+}
+
+// verify checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *OIDCProvider) verify(rawToken, nonce string) (*Identity, error) {
+	keyfunc := func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		_, keys, err := p.load(false)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := keys[kid]; ok {
+			return key, nil
+		}
+		// The provider may have rotated its keys
+		if _, keys, err = p.load(true); err != nil {
+			return nil, err
+		}
+		if key, ok := keys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, keyfunc,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("invalid id token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid id token: missing subject")
+	}
+
+	// The username and groups claims are configurable, so they are read from the raw claims
+	raw := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(rawToken, raw); err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+	identity := &Identity{
+		Provider: "oidc",
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+		Groups:   claimStrings(raw[p.GroupsClaim]),
+	}
+	if username := claimStrings(raw[p.UsernameClaim]); len(username) > 0 {
+		identity.Username = username[0]
+	}
+	// Claims such as preferred_username can often be edited by their users, a verified email cannot.
+	// Anyone can verify an address at another domain, so only the school's domains vouch for the local part.
+	if raw["email_verified"] == true && identity.Username != "" {
+		localPart, domain, _ := strings.Cut(claims.Email, "@")
+		identity.UsernameVerified = identity.Username == claims.Email ||
+			(identity.Username == localPart && p.isSchoolDomain(domain))
+	}
+	return identity, nil
+}
+
+// isSchoolDomain reports whether domain is one of the configured email domains
+func (p *OIDCProvider) isSchoolDomain(domain string) bool {
+	for _, d := range p.EmailDomains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,349 @@
+package sso
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "advice"
+	testClientSecret = "client-secret"
+	testRedirectURL  = "http://localhost:8080/api/v1/admin/sso/oidc/callback"
+)
+
+// testIdP is an in-process OpenID Connect provider. Authorization codes are registered directly by the tests,
+// standing in for the login page.
+type testIdP struct {
+	server *httptest.Server
+
+	mu         sync.Mutex
+	key        *rsa.PrivateKey
+	kid        string
+	codes      map[string]testCode
+	jwksServed int
+}
+
+// testCode is an authorization code waiting to be redeemed, with the claims of its ID token
+type testCode struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	idp := &testIdP{key: newTestKey(t), kid: "key-1", codes: map[string]testCode{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func writeTestJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (idp *testIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.jwksServed++
+	pub := idp.key.PublicKey
+	writeTestJSON(w, map[string]interface{}{"keys": []map[string]string{{
+		"kid": idp.kid,
+		"kty": "RSA",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+func (idp *testIdP) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, _ := r.BasicAuth()
+	r.ParseForm()
+	idp.mu.Lock()
+	code, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case clientID != testClientID || clientSecret != testClientSecret:
+		w.WriteHeader(http.StatusUnauthorized)
+		writeTestJSON(w, map[string]string{"error": "invalid_client"})
+	case !ok || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("redirect_uri") != testRedirectURL ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != code.challenge:
+		w.WriteHeader(http.StatusBadRequest)
+		writeTestJSON(w, map[string]string{"error": "invalid_grant"})
+	default:
+		writeTestJSON(w, map[string]string{"token_type": "Bearer", "id_token": idp.sign(code.claims)})
+	}
+}
+
+// claims returns valid ID token claims for the test client, to be adjusted by each test
+func (idp *testIdP) claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":                idp.server.URL,
+		"aud":                testClientID,
+		"sub":                "user-123",
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              nonce,
+		"preferred_username": "zhang",
+		"email":              "zhang@example.edu",
+		"groups":             []string{"staff", "logistics"},
+	}
+}
+
+// sign signs claims with the current key of the provider
+func (idp *testIdP) sign(claims jwt.MapClaims) string {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return signTestToken(idp.key, idp.kid, claims)
+}
+
+func signTestToken(key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+// rotate replaces the signing key of the provider
+func (idp *testIdP) rotate(key *rsa.PrivateKey, kid string) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.key, idp.kid = key, kid
+}
+
+func (idp *testIdP) provider() *OIDCProvider {
+	return &OIDCProvider{
+		Issuer:        idp.server.URL,
+		ClientID:      testClientID,
+		ClientSecret:  testClientSecret,
+		RedirectURL:   testRedirectURL,
+		Scopes:        []string{"openid", "profile", "email"},
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+		EmailDomains:  []string{"example.edu"},
+	}
+}
+
+// authorize follows the authorization URL like a browser whose user signs in, and returns the authorization code
+func (idp *testIdP) authorize(t *testing.T, authURL string, claims jwt.MapClaims) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL, idp.server.URL+"/authorize?") {
+		t.Fatalf("authorization URL %s is not at the provider's endpoint", authURL)
+	}
+	query := u.Query()
+	if query.Get("client_id") != testClientID || query.Get("redirect_uri") != testRedirectURL || query.Get("response_type") != "code" {
+		t.Fatalf("authorization request %v is not for the test client", query)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization request %v does not use PKCE with S256", query)
+	}
+	if query.Get("scope") != "openid profile email" {
+		t.Errorf("scope = %q", query.Get("scope"))
+	}
+	claims["nonce"] = query.Get("nonce")
+
+	code := RandomString()
+	idp.mu.Lock()
+	idp.codes[code] = testCode{challenge: query.Get("code_challenge"), claims: claims}
+	idp.mu.Unlock()
+	return code
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+	state, nonce, verifier := RandomString(), RandomString(), RandomString()
+
+	authURL, err := p.AuthURL(state, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := url.Parse(authURL); got.Query().Get("state") != state || got.Query().Get("nonce") != nonce {
+		t.Errorf("authorization URL does not carry the state and nonce: %s", authURL)
+	}
+	code := idp.authorize(t, authURL, idp.claims(""))
+
+	identity, err := p.Exchange(code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := Identity{Provider: "oidc", Subject: "user-123", Username: "zhang", Email: "zhang@example.edu"}
+	if identity.Provider != want.Provider || identity.Subject != want.Subject || identity.Username != want.Username || identity.Email != want.Email {
+		t.Errorf("identity = %+v, want %+v", identity, want)
+	}
+	if strings.Join(identity.Groups, ",") != "staff,logistics" {
+		t.Errorf("groups = %v", identity.Groups)
+	}
+	if identity.UsernameVerified {
+		t.Error("preferred_username was trusted without a verified email")
+	}
+
+	// Codes can only be redeemed once
+	if _, err := p.Exchange(code, verifier, nonce); err == nil {
+		t.Error("an authorization code was redeemed twice")
+	}
+}
+
+func TestOIDCLoginRequiresPKCEVerifier(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+	nonce, verifier := RandomString(), RandomString()
+	authURL, err := p.AuthURL(RandomString(), nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := idp.authorize(t, authURL, idp.claims(""))
+
+	if _, err := p.Exchange(code, RandomString(), nonce); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("Exchange with another verifier: err = %v, want invalid_grant", err)
+	}
+}
+
+func TestOIDCVerifiedUsername(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+	tests := []struct {
+		name          string
+		username      string
+		email         string
+		emailVerified interface{}
+		want          bool
+	}{
+		{"local part of a verified email", "zhang", "zhang@example.edu", true, true},
+		{"verified email", "zhang@example.edu", "zhang@example.edu", true, true},
+		{"other name", "li", "zhang@example.edu", true, false},
+		{"unverified email", "zhang", "zhang@example.edu", false, false},
+		{"verification as a string", "zhang", "zhang@example.edu", "true", false},
+		{"local part at another domain", "zhang", "zhang@gmail.com", true, false},
+		{"whole email at another domain", "zhang@gmail.com", "zhang@gmail.com", true, true},
+		{"domain in another case", "zhang", "zhang@Example.EDU", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := idp.claims("n")
+			claims["preferred_username"] = tt.username
+			claims["email"] = tt.email
+			claims["email_verified"] = tt.emailVerified
+			identity, err := p.verify(idp.sign(claims), "n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.UsernameVerified != tt.want {
+				t.Errorf("UsernameVerified = %v, want %v", identity.UsernameVerified, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCVerifyRejectsInvalidTokens(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+	otherKey := newTestKey(t)
+
+	tests := []struct {
+		name   string
+		token  func(claims jwt.MapClaims) string
+		reason string
+	}{
+		{"wrong nonce", func(c jwt.MapClaims) string { c["nonce"] = "other"; return idp.sign(c) }, "nonce"},
+		{"wrong audience", func(c jwt.MapClaims) string { c["aud"] = "other-client"; return idp.sign(c) }, "aud"},
+		{"expired", func(c jwt.MapClaims) string { c["exp"] = time.Now().Add(-5 * time.Minute).Unix(); return idp.sign(c) }, "expired"},
+		{"no expiry", func(c jwt.MapClaims) string { delete(c, "exp"); return idp.sign(c) }, "exp"},
+		{"wrong issuer", func(c jwt.MapClaims) string { c["iss"] = "https://evil.example.com"; return idp.sign(c) }, "iss"},
+		{"no subject", func(c jwt.MapClaims) string { delete(c, "sub"); return idp.sign(c) }, "subject"},
+		{"signed with another key", func(c jwt.MapClaims) string { return signTestToken(otherKey, "key-1", c) }, "signature"},
+		{"unsigned", func(c jwt.MapClaims) string {
+			s, _ := jwt.NewWithClaims(jwt.SigningMethodNone, c).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return s
+		}, "signing method"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.verify(tt.token(idp.claims("nonce")), "nonce")
+			if err == nil || !strings.Contains(err.Error(), tt.reason) {
+				t.Fatalf("err = %v, want an error about %q", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+	oldKey := idp.key
+
+	if _, err := p.verify(idp.sign(idp.claims("n")), "n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.verify(idp.sign(idp.claims("n")), "n"); err != nil {
+		t.Fatal(err)
+	}
+	if idp.jwksServed != 1 {
+		t.Fatalf("keys were fetched %d times for two tokens, want once", idp.jwksServed)
+	}
+
+	// A token signed with a new key ID makes the provider fetch the keys again
+	idp.rotate(newTestKey(t), "key-2")
+	if _, err := p.verify(idp.sign(idp.claims("n")), "n"); err != nil {
+		t.Fatalf("token signed with the rotated key: %v", err)
+	}
+	if idp.jwksServed != 2 {
+		t.Errorf("keys were fetched %d times, want a refetch after the rotation", idp.jwksServed)
+	}
+
+	// The retired key is no longer accepted
+	if _, err := p.verify(signTestToken(oldKey, "key-1", idp.claims("n")), "n"); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Errorf("token signed with the retired key: err = %v, want unknown signing key", err)
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+	p.Issuer = strings.Replace(idp.server.URL, "127.0.0.1", "localhost", 1)
+
+	if _, err := p.AuthURL("s", "n", "v"); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("AuthURL with another issuer: err = %v, want an issuer mismatch", err)
+	}
+}
@@ -0,0 +1,215 @@
+package sso
+
+import (
+	"advice/utils"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Identity is an account authenticated by an external identity provider
+type Identity struct {
+	Provider string // "oidc" or "cas"
+	Subject  string // Stable ID of the account at the provider
+	Username string
+	Email    string
+	Groups   []string // Values of the groups claim or attribute, matched against the role mappings
+	// UsernameVerified is set when the provider vouches for Username: the CAS login name, or an OIDC username
+	// that is the verified email or, at a school domain, its local part. Only such identities are linked to local admins.
+	UsernameVerified bool
+}
+
+var (
+	// OIDC is the OpenID Connect provider, nil when OIDC login is not configured
+	OIDC *OIDCProvider
+	// CAS is the CAS server, nil when CAS login is not configured
+	CAS *CASProvider
+	// FrontendURL receives the token of a successful login, or the error, in its fragment
+	FrontendURL string
+	// RoleMappings give new admins a role from their groups, in the order of SSO_ROLE_MAPPINGS
+	RoleMappings []RoleMapping
+	// DefaultRole is given to new admins matching no mapping; empty refuses them
+	DefaultRole         string
+	DefaultDepartmentID *uint
+	// LinkLocalAccounts lets an external account with a verified username sign in as the department admin of the same
+	// username. Anyone who can get that username at the provider takes over the admin, so super admins are never linked
+	// this way; a super admin links them explicitly instead.
+	LinkLocalAccounts bool
+)
+
+// RoleMapping gives the admins of a group a role, and a department for department admins
+type RoleMapping struct {
+	Group        string
+	Role         string
+	DepartmentID *uint
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Init configures the identity providers from the environment. Each one is enabled when its URL is set.
+//
+//	OIDC_ISSUER                issuer URL, discovered through /.well-known/openid-configuration
+//	OIDC_CLIENT_ID             client registered at the issuer
+//	OIDC_CLIENT_SECRET         secret of the client
+//	OIDC_REDIRECT_URL          callback URL registered for the client, .../api/v1/admin/sso/oidc/callback
+//	OIDC_SCOPES                default "openid profile email"
+//	OIDC_USERNAME_CLAIM        default "preferred_username"
+//	SSO_EMAIL_DOMAINS          comma separated school email domains, e.g. "example.edu". A username equal to the local part
+//	                           of a verified email counts as verified only for these domains, otherwise it has to be the whole email.
+//	CAS_URL                    CAS server prefix, e.g. https://cas.example.edu/cas
+//	CAS_SERVICE_URL            callback URL sent as service, .../api/v1/admin/sso/cas/callback
+//	SSO_GROUPS_CLAIM           OIDC claim or CAS attribute holding the groups, default "groups"
+//	SSO_FRONTEND_URL           page receiving the token, default http://localhost:5173/admin/sso-callback
+//	SSO_ROLE_MAPPINGS          comma separated group=role[:department_id], e.g. "it-staff=super_admin,logistics=department_admin:2"
+//	SSO_DEFAULT_ROLE           role of new admins matching no mapping, empty to refuse them
+//	SSO_DEFAULT_DEPARTMENT_ID  department of new department admins whose mapping has none
+//	SSO_LINK_LOCAL_ACCOUNTS    sign in to the existing department admin of the same verified username instead of refusing, default false.
+//	                           Only enable it when usernames at the provider cannot be chosen by their users.
+func Init() {
+	FrontendURL = utils.Getenv("SSO_FRONTEND_URL", "http://localhost:5173/admin/sso-callback")
+	groupsClaim := utils.Getenv("SSO_GROUPS_CLAIM", "groups")
+	LinkLocalAccounts = utils.GetenvBool("SSO_LINK_LOCAL_ACCOUNTS", false)
+
+	for _, raw := range utils.GetenvList("SSO_ROLE_MAPPINGS", nil) {
+		mapping, err := parseRoleMapping(raw)
+		if err != nil {
+			log.Fatalf("Invalid SSO_ROLE_MAPPINGS entry %q: %v", raw, err)
+		}
+		RoleMappings = append(RoleMappings, mapping)
+	}
+	if DefaultRole = utils.Getenv("SSO_DEFAULT_ROLE", ""); DefaultRole != "" && !validRole(DefaultRole) {
+		log.Fatalf("Invalid SSO_DEFAULT_ROLE %q", DefaultRole)
+	}
+	if raw := utils.Getenv("SSO_DEFAULT_DEPARTMENT_ID", ""); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			log.Fatalf("Invalid SSO_DEFAULT_DEPARTMENT_ID %q", raw)
+		}
+		departmentID := uint(id)
+		DefaultDepartmentID = &departmentID
+	}
+	if DefaultDepartmentID == nil {
+		for _, m := range RoleMappings {
+			if m.Role == "department_admin" && m.DepartmentID == nil {
+				log.Printf("SSO role mapping of group %q has no department and SSO_DEFAULT_DEPARTMENT_ID is unset, its members will be refused", m.Group)
+			}
+		}
+		if DefaultRole == "department_admin" {
+			log.Println("SSO_DEFAULT_ROLE is department_admin but SSO_DEFAULT_DEPARTMENT_ID is unset, unmapped accounts will be refused")
+		}
+	}
+
+	if issuer := utils.Getenv("OIDC_ISSUER", ""); issuer != "" {
+		OIDC = &OIDCProvider{
+			Issuer:        strings.TrimRight(issuer, "/"),
+			ClientID:      utils.Getenv("OIDC_CLIENT_ID", ""),
+			ClientSecret:  utils.Getenv("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:   utils.Getenv("OIDC_REDIRECT_URL", ""),
+			Scopes:        strings.Fields(utils.Getenv("OIDC_SCOPES", "openid profile email")),
+			UsernameClaim: utils.Getenv("OIDC_USERNAME_CLAIM", "preferred_username"),
+			GroupsClaim:   groupsClaim,
+			EmailDomains:  utils.GetenvList("SSO_EMAIL_DOMAINS", nil),
+		}
+		if OIDC.ClientID == "" || OIDC.RedirectURL == "" {
+			log.Fatal("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required with OIDC_ISSUER")
+		}
+	}
+	if casURL := utils.Getenv("CAS_URL", ""); casURL != "" {
+		CAS = &CASProvider{
+			URL:             strings.TrimRight(casURL, "/"),
+			ServiceURL:      utils.Getenv("CAS_SERVICE_URL", ""),
+			GroupsAttribute: groupsClaim,
+		}
+		if CAS.ServiceURL == "" {
+			log.Fatal("CAS_SERVICE_URL is required with CAS_URL")
+		}
+	}
+}
+
+// MapRole returns the role and department for an identity's groups. The highest role among the matching
+// mappings wins, and DefaultRole applies when none matches. ok is false when the identity gets no role.
+func MapRole(groups []string) (role string, departmentID *uint, ok bool) {
+	member := map[string]bool{}
+	for _, g := range groups {
+		member[g] = true
+	}
+	for _, m := range RoleMappings {
+		if !member[m.Group] {
+			continue
+		}
+		// The first matching mapping of the highest role wins
+		if role == "" || (role == "department_admin" && m.Role == "super_admin") {
+			role, departmentID = m.Role, m.DepartmentID
+		}
+	}
+	if role == "" {
+		role = DefaultRole
+	}
+	if role == "" {
+		return "", nil, false
+	}
+	if role == "super_admin" {
+		return role, nil, true
+	}
+	if departmentID == nil {
+		departmentID = DefaultDepartmentID
+	}
+	return role, departmentID, true
+}
+
+func validRole(role string) bool {
+	return role == "super_admin" || role == "department_admin"
+}
+
+func parseRoleMapping(raw string) (RoleMapping, error) {
+	group, target, found := strings.Cut(raw, "=")
+	if !found || strings.TrimSpace(group) == "" {
+		return RoleMapping{}, errors.New("expected group=role[:department_id]")
+	}
+	role, department, hasDepartment := strings.Cut(strings.TrimSpace(target), ":")
+	mapping := RoleMapping{Group: strings.TrimSpace(group), Role: role}
+	if !validRole(role) {
+		return RoleMapping{}, errors.New("role must be super_admin or department_admin")
+	}
+	if hasDepartment {
+		id, err := strconv.ParseUint(department, 10, 0)
+		if err != nil {
+			return RoleMapping{}, errors.New("invalid department ID")
+		}
+		departmentID := uint(id)
+		mapping.DepartmentID = &departmentID
+	}
+	return mapping, nil
+}
+
+// RandomString returns a URL safe random string, used for OIDC state, nonce and PKCE verifier
+func RandomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// claimStrings reads a claim that may be a single string or a list of strings
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
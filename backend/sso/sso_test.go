@@ -0,0 +1,61 @@
+package sso
+
+import "testing"
+
+func uintPtr(v uint) *uint { return &v }
+
+func TestMapRole(t *testing.T) {
+	previousMappings, previousRole, previousDepartment := RoleMappings, DefaultRole, DefaultDepartmentID
+	t.Cleanup(func() {
+		RoleMappings, DefaultRole, DefaultDepartmentID = previousMappings, previousRole, previousDepartment
+	})
+
+	RoleMappings = []RoleMapping{
+		{Group: "logistics", Role: "department_admin", DepartmentID: uintPtr(2)},
+		{Group: "academic", Role: "department_admin", DepartmentID: uintPtr(1)},
+		{Group: "it", Role: "super_admin"},
+		{Group: "counselors", Role: "department_admin"},
+	}
+	DefaultDepartmentID = uintPtr(3)
+
+	tests := []struct {
+		name        string
+		defaultRole string
+		groups      []string
+		role        string
+		department  *uint
+		ok          bool
+	}{
+		{"department admin", "", []string{"staff", "logistics"}, "department_admin", uintPtr(2), true},
+		{"first matching mapping of a role", "", []string{"academic", "logistics"}, "department_admin", uintPtr(2), true},
+		{"super admin outranks a department mapping listed before", "", []string{"logistics", "it"}, "super_admin", nil, true},
+		{"super admin whatever the group order", "", []string{"it", "academic"}, "super_admin", nil, true},
+		{"default department", "", []string{"counselors"}, "department_admin", uintPtr(3), true},
+		{"no mapping and no default role", "", []string{"students"}, "", nil, false},
+		{"no groups", "", nil, "", nil, false},
+		{"default role", "department_admin", []string{"students"}, "department_admin", uintPtr(3), true},
+		{"mapping over default role", "department_admin", []string{"it"}, "super_admin", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			DefaultRole = tt.defaultRole
+			role, department, ok := MapRole(tt.groups)
+			if role != tt.role || ok != tt.ok || (department == nil) != (tt.department == nil) ||
+				(department != nil && *department != *tt.department) {
+				t.Errorf("MapRole(%v) = %q, %v, %v, want %q, %v, %v", tt.groups, role, department, ok, tt.role, tt.department, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseRoleMapping(t *testing.T) {
+	mapping, err := parseRoleMapping(" logistics = department_admin:2")
+	if err != nil || mapping.Group != "logistics" || mapping.Role != "department_admin" || mapping.DepartmentID == nil || *mapping.DepartmentID != 2 {
+		t.Errorf("parseRoleMapping = %+v, %v", mapping, err)
+	}
+	for _, raw := range []string{"it", "=super_admin", "it=admin", "it=department_admin:two"} {
+		if _, err := parseRoleMapping(raw); err == nil {
+			t.Errorf("parseRoleMapping(%q) succeeded", raw)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"advice/utils"
+	"errors"
+	"io"
+	"log"
+)
+
+// ErrNotFound is returned when a stored object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Storage stores attachment files under opaque keys
+type Storage interface {
+	Put(key string, r io.Reader, size int64, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// Default is the storage used for attachments, configured by Init
+var Default Storage
+
+// Init configures the default storage from the environment.
+//
+//	ATTACHMENT_STORAGE   "local" (default) or "s3"
+//	ATTACHMENT_DIR       directory of the local storage, default "uploads"
+//	S3_ENDPOINT          S3-compatible endpoint URL, e.g. http://127.0.0.1:9000
+//	S3_REGION            default "us-east-1"
+//	S3_BUCKET            bucket name
+//	S3_ACCESS_KEY_ID     access key
+//	S3_SECRET_ACCESS_KEY secret key
+func Init() {
+	switch backend := utils.Getenv("ATTACHMENT_STORAGE", "local"); backend {
+	case "local":
+		local, err := NewLocalStorage(utils.Getenv("ATTACHMENT_DIR", "uploads"))
+		if err != nil {
+			log.Fatal("Failed to initialize attachment storage:", err)
+		}
+		Default = local
+	case "s3":
+		Default = NewS3Storage(
+			utils.Getenv("S3_ENDPOINT", ""),
+			utils.Getenv("S3_REGION", "us-east-1"),
+			utils.Getenv("S3_BUCKET", ""),
+			utils.Getenv("S3_ACCESS_KEY_ID", ""),
+			utils.Getenv("S3_SECRET_ACCESS_KEY", ""),
+		)
+	default:
+		log.Fatalf("Unknown attachment storage %q", backend)
+	}
+}
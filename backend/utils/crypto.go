@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+var contactKey = sha256.Sum256([]byte(Getenv("CONTACT_ENCRYPTION_KEY", "your-very-secret-contact-key"))) // WARNING: Set CONTACT_ENCRYPTION_KEY in production
+
+// EncryptString encrypts personal data such as contact addresses with AES-GCM
+func EncryptString(plaintext string) (string, error) {
+	gcm, err := contactCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString decrypts a value encrypted by EncryptString
+func DecryptString(ciphertext string) (string, error) {
+	gcm, err := contactCipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func contactCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(contactKey[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SignUnsubscribe returns the token of the unsubscribe link of a tracking code
+func SignUnsubscribe(trackingCode string) string {
+	mac := hmac.New(sha256.New, contactKey[:])
+	mac.Write([]byte("unsubscribe:" + trackingCode))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyUnsubscribe reports whether token was created by SignUnsubscribe for the tracking code
+func VerifyUnsubscribe(trackingCode, token string) bool {
+	return hmac.Equal([]byte(token), []byte(SignUnsubscribe(trackingCode)))
+}
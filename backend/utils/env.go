@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Getenv returns the environment variable key, or fallback when it is unset or empty
+func Getenv(key, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// GetenvInt returns the environment variable key as an integer, or fallback when it is unset or invalid
+func GetenvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(Getenv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetenvBool returns the environment variable key as a boolean, or fallback when it is unset or invalid
+func GetenvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(Getenv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetenvList returns the comma separated environment variable key, or fallback when it is unset
+func GetenvList(key string, fallback []string) []string {
+	raw := Getenv(key, "")
+	if raw == "" {
+		return fallback
+	}
+	var values []string
+	for _, v := range strings.Split(raw, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"html"
+	"strings"
+)
+
+// Highlight returns an HTML-escaped excerpt of at most width characters around the first
+// occurrence of any search term, with every occurrence wrapped in <mark></mark>.
+// It returns an empty string when no term occurs in text.
+func Highlight(text string, terms []string, width int) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// Lowercasing changed the length, compare against the lowercased text instead
+		runes = lower
+	}
+
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		termRunes := []rune(term)
+		if len(termRunes) == 0 {
+			continue
+		}
+		for i := 0; i+len(termRunes) <= len(lower); i++ {
+			if string(lower[i:i+len(termRunes)]) != term {
+				continue
+			}
+			for j := i; j < i+len(termRunes); j++ {
+				marked[j] = true
+			}
+			if first == -1 || i < first {
+				first = i
+			}
+		}
+	}
+	if first == -1 {
+		return ""
+	}
+
+	start := first - width/4
+	if start < 0 || len(runes) <= width {
+		start = 0
+	}
+	end := start + width
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	inMark := false
+	for i := start; i < end; i++ {
+		if marked[i] != inMark {
+			if marked[i] {
+				b.WriteString("<mark>")
+			} else {
+				b.WriteString("</mark>")
+			}
+			inMark = marked[i]
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+	}
+	if inMark {
+		b.WriteString("</mark>")
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// Excerpt returns the HTML-escaped first width characters of text
+func Excerpt(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return html.EscapeString(text)
+	}
+	return html.EscapeString(string(runes[:width])) + "…"
+}
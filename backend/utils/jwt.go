@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"crypto/sha256"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -50,3 +51,79 @@ func ValidateJWT(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// ssoStateSecret signs SSO login state with a key derived from jwtSecret, so state tokens are never accepted as admin tokens
+var ssoStateSecret = func() []byte {
+	sum := sha256.Sum256(append([]byte("sso-state:"), jwtSecret...))
+	return sum[:]
+}()
+
+// SSOState is the state of an SSO login kept in a cookie between the redirect to the provider and its callback
+type SSOState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+// SignSSOState signs the state of an SSO login, valid for 10 minutes
+func SignSSOState(state, nonce, verifier string) (string, error) {
+	claims := &SSOState{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(10 * time.Minute)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ssoStateSecret)
+}
+
+// ParseSSOState verifies a signed SSO login state
+func ParseSSOState(tokenString string) (*SSOState, error) {
+	claims := &SSOState{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return ssoStateSecret, nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// eventTicketSecret signs event stream tickets with a key derived from jwtSecret, so tickets are never accepted as admin tokens
+var eventTicketSecret = func() []byte {
+	sum := sha256.Sum256(append([]byte("event-ticket:"), jwtSecret...))
+	return sum[:]
+}()
+
+// EventTicketTTL is how long an event stream ticket can be used to open the stream
+const EventTicketTTL = time.Minute
+
+// eventTicket carries the claims of an admin to the event stream, which EventSource opens without an Authorization header
+type eventTicket struct {
+	Claims
+	SessionExpiresAt *jwt.NumericDate `json:"session_exp,omitempty"` // Expiry of the admin token the ticket was issued for
+}
+
+// SignEventTicket issues a short-lived ticket for the event stream, so the admin token never ends up in URLs and logs
+func SignEventTicket(claims *Claims) (string, error) {
+	ticket := &eventTicket{Claims: *claims, SessionExpiresAt: claims.ExpiresAt}
+	ticket.ExpiresAt = jwt.NewNumericDate(time.Now().Add(EventTicketTTL))
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, ticket).SignedString(eventTicketSecret)
+}
+
+// ParseEventTicket verifies an event stream ticket and returns the admin claims it was issued for,
+// which expire with the admin token rather than the ticket
+func ParseEventTicket(tokenString string) (*Claims, error) {
+	ticket := &eventTicket{}
+	_, err := jwt.ParseWithClaims(tokenString, ticket, func(token *jwt.Token) (interface{}, error) {
+		return eventTicketSecret, nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	claims := ticket.Claims
+	claims.ExpiresAt = ticket.SessionExpiresAt
+	return &claims, nil
+}
@@ -1,13 +1,35 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// studentPasswordCost is lower than the admin cost so that whole rosters can be imported in one request
+const studentPasswordCost = bcrypt.DefaultCost
 
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	return string(bytes), err
 }
 
+// HashStudentPassword hashes the password of a student account
+func HashStudentPassword(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), studentPasswordCost)
+	return string(bytes), err
+}
+
 func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// GeneratePassword creates a random initial password of 12 lowercase letters and digits
+func GeneratePassword() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:12]
+}
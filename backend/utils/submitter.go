@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+)
+
+// NewSubmitterKey creates a random key that bundles the suggestions of an anonymous submitter
+func NewSubmitterKey() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return base32.StdEncoding.EncodeToString(b)
+}
+
+// HashSubmitterKey returns the hash stored in place of a submitter key.
+// Keys are random enough that an unkeyed hash cannot be reversed.
+func HashSubmitterKey(key string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(key))))
+	return hex.EncodeToString(sum[:])
+}
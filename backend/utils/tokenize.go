@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// isCJK reports whether r is a Chinese, Japanese or Korean character that is written without spaces
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// SplitTerms splits text into runs of CJK characters and lowercased words, dropping punctuation
+func SplitTerms(text string) []string {
+	var terms []string
+	var current []rune
+	currentCJK := false
+
+	flush := func() {
+		if len(current) > 0 {
+			terms = append(terms, strings.ToLower(string(current)))
+			current = current[:0]
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if !currentCJK {
+				flush()
+			}
+			currentCJK = true
+			current = append(current, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if currentCJK {
+				flush()
+			}
+			currentCJK = false
+			current = append(current, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return terms
+}
+
+// Bigrams splits a run of CJK characters into overlapping two-character tokens.
+// Other terms, and single characters, are returned as is.
+func Bigrams(term string) []string {
+	runes := []rune(term)
+	if len(runes) < 2 || !isCJK(runes[0]) {
+		return []string{term}
+	}
+
+	tokens := make([]string, 0, len(runes)-1)
+	for i := 0; i+1 < len(runes); i++ {
+		tokens = append(tokens, string(runes[i:i+2]))
+	}
+	return tokens
+}
+
+// BigramTokens splits text into its words and the character bigrams of its CJK runs
+func BigramTokens(text string) []string {
+	var tokens []string
+	for _, term := range SplitTerms(text) {
+		tokens = append(tokens, Bigrams(term)...)
+	}
+	return tokens
+}
+
+// TokenizeForIndex turns text into space separated tokens so that a whitespace tokenizer
+// can index Chinese text by character bigrams. The last character of each CJK run is also
+// indexed on its own, so that a prefix query for a single character finds it in any position.
+func TokenizeForIndex(text string) string {
+	var tokens []string
+	for _, term := range SplitTerms(text) {
+		tokens = append(tokens, Bigrams(term)...)
+		if runes := []rune(term); len(runes) > 1 && isCJK(runes[0]) {
+			tokens = append(tokens, string(runes[len(runes)-1]))
+		}
+	}
+	return strings.Join(tokens, " ")
+}
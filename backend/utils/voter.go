@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+var voterSecret = []byte(Getenv("VOTER_SECRET", "your-very-secret-voter-key")) // WARNING: Set VOTER_SECRET in production
+
+func signVoterID(id string) string {
+	mac := hmac.New(sha256.New, voterSecret)
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewVoterToken creates a signed token identifying an anonymous voter
+func NewVoterToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	return id + "." + signVoterID(id)
+}
+
+// ParseVoterToken returns the voter ID of a token created by NewVoterToken
+func ParseVoterToken(token string) (string, bool) {
+	id, signature, found := strings.Cut(token, ".")
+	if !found || id == "" {
+		return "", false
+	}
+	if !hmac.Equal([]byte(signature), []byte(signVoterID(id))) {
+		return "", false
+	}
+	return id, true
+}
+
+// HashIP returns a keyed hash of an IP address, so that votes can be grouped by IP without storing it
+func HashIP(ip string) string {
+	mac := hmac.New(sha256.New, voterSecret)
+	mac.Write([]byte("ip:" + ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,21 @@
+package utils
+
+import "time"
+
+// IsWeekend reports whether t falls on a Saturday or Sunday
+func IsWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+// AddWorkingDays returns the time the given number of working days after start, keeping the time of day.
+// isWorkingDay decides which calendar days count.
+func AddWorkingDays(start time.Time, days int, isWorkingDay func(time.Time) bool) time.Time {
+	due := start
+	for days > 0 {
+		due = due.AddDate(0, 0, 1)
+		if isWorkingDay(due) {
+			days--
+		}
+	}
+	return due
+}
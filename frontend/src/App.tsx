@@ -1,5 +1,6 @@
 import { BrowserRouter as Router, Routes, Route, Navigate } from 'react-router-dom';
 import AdminLoginPage from './pages/AdminLoginPage';
+import AdminSSOCallbackPage from './pages/AdminSSOCallbackPage';
 import AdminDashboardPage from './pages/AdminDashboardPage';
 import ProtectedRoute from './components/ProtectedRoute';
 import PublicSuggestionsPage from './pages/PublicSuggestionsPage';
@@ -22,6 +23,7 @@ function App() {
         </Route>
         
         <Route path="/admin/login" element={<AdminLoginPage />} />
+        <Route path="/admin/sso-callback" element={<AdminSSOCallbackPage />} />
         <Route element={<ProtectedRoute />}>
           <Route path="/admin/dashboard/*" element={<AdminDashboardPage />} />
         </Route>
@@ -3,7 +3,8 @@ import apiClient from './axios';
 // We need to set the auth token for all admin requests
 apiClient.interceptors.request.use(config => {
   const token = localStorage.getItem('admin_token');
-  if (token) {
+  // Requests made with a student token keep it
+  if (token && !config.headers.Authorization) {
     config.headers.Authorization = `Bearer ${token}`;
   }
   return config;
@@ -50,6 +51,17 @@ export const deleteAdmin = async (id: number) => {
   return response.data;
 };
 
+// Links an SSO account to an existing admin; subject is the OIDC sub claim or the CAS login name
+export const linkAdminIdentity = async (id: number, data: { provider: 'oidc' | 'cas'; subject: string }) => {
+  const response = await apiClient.post(`/admin/users/${id}/identities`, data);
+  return response.data;
+};
+
+export const unlinkAdminIdentity = async (id: number, identityId: number) => {
+  const response = await apiClient.delete(`/admin/users/${id}/identities/${identityId}`);
+  return response.data;
+};
+
 // --- Dashboard ---
 export const getDashboardStats = async () => {
   const response = await apiClient.get('/admin/dashboard/stats');
@@ -82,4 +94,128 @@ export const deleteSuggestions = async (ids: number[]) => {
     data: { ids },
   });
   return response.data;
-}; 
\ No newline at end of file
+}; 
+export const getComments = async (params: { status?: string; suggestion_id?: number; page: number; pageSize: number }) => {
+  const response = await apiClient.get('/admin/comments', { params });
+  return response.data;
+};
+
+export const moderateComment = async (id: number, status: string) => {
+  const response = await apiClient.put(`/admin/comments/${id}/status`, { status });
+  return response.data;
+};
+
+export const deleteComment = async (id: number) => {
+  const response = await apiClient.delete(`/admin/comments/${id}`);
+  return response.data;
+};
+
+export const transferSuggestion = async (id: number, departmentId: number) => {
+  const response = await apiClient.put(`/admin/suggestions/${id}/department`, { department_id: departmentId });
+  return response.data;
+};
+
+export type NotificationType = 'new_suggestion' | 'follow_up' | 'transfer' | 'sla_warning';
+
+export interface NotificationPreferences {
+  muted_types: NotificationType[];
+  email: string;
+  email_mode: 'off' | 'instant' | 'daily';
+}
+
+export const getNotifications = async (params: { unread?: boolean; type?: NotificationType; page: number; pageSize: number }) => {
+  const response = await apiClient.get('/admin/notifications', { params });
+  return response.data;
+};
+
+export const markNotification = async (id: number, read: boolean) => {
+  const response = await apiClient.put(`/admin/notifications/${id}`, { read });
+  return response.data;
+};
+
+export const markAllNotificationsRead = async () => {
+  const response = await apiClient.post('/admin/notifications/read-all');
+  return response.data;
+};
+
+export const getNotificationPreferences = async (): Promise<NotificationPreferences> => {
+  const response = await apiClient.get('/admin/notifications/preferences');
+  return response.data;
+};
+
+export const updateNotificationPreferences = async (data: NotificationPreferences) => {
+  const response = await apiClient.put('/admin/notifications/preferences', data);
+  return response.data;
+};
+
+export interface WebhookInput {
+  name: string;
+  url: string;
+  event_types?: string[];
+  department_id?: number | null;
+  secret?: string;
+  is_active?: boolean;
+}
+
+export const getWebhooks = async () => {
+  const response = await apiClient.get('/admin/webhooks');
+  return response.data;
+};
+
+export const createWebhook = async (data: WebhookInput) => {
+  const response = await apiClient.post('/admin/webhooks', data);
+  return response.data;
+};
+
+export const updateWebhook = async (id: number, data: WebhookInput) => {
+  const response = await apiClient.put(`/admin/webhooks/${id}`, data);
+  return response.data;
+};
+
+export const deleteWebhook = async (id: number) => {
+  const response = await apiClient.delete(`/admin/webhooks/${id}`);
+  return response.data;
+};
+
+export const testWebhook = async (id: number) => {
+  const response = await apiClient.post(`/admin/webhooks/${id}/test`);
+  return response.data;
+};
+
+// The new secret is only returned by this request
+export const rotateWebhookSecret = async (id: number) => {
+  const response = await apiClient.post(`/admin/webhooks/${id}/rotate-secret`);
+  return response.data;
+};
+
+export const getWebhookDeliveries = async (id: number, params: { status?: string; page: number; pageSize: number }) => {
+  const response = await apiClient.get(`/admin/webhooks/${id}/deliveries`, { params });
+  return response.data;
+};
+
+export const replayWebhookDelivery = async (id: number, deliveryId: number) => {
+  const response = await apiClient.post(`/admin/webhooks/${id}/deliveries/${deliveryId}/replay`);
+  return response.data;
+};
+
+export const getStudents = async (params: { keyword?: string; page: number; pageSize: number }) => {
+  const response = await apiClient.get('/admin/students', { params });
+  return response.data;
+};
+
+export const importStudents = async (file: File) => {
+  const formData = new FormData();
+  formData.append('file', file);
+  const response = await apiClient.post('/admin/students/import', formData, {
+    headers: { 'Content-Type': 'multipart/form-data' },
+  });
+  return response.data;
+};
+
+export const updateStudent = async (
+  id: number,
+  data: { name?: string; class?: string; password?: string; is_active?: boolean },
+) => {
+  const response = await apiClient.put(`/admin/students/${id}`, data);
+  return response.data;
+};
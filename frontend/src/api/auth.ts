@@ -12,4 +12,18 @@ export interface LoginResponse {
 export const login = async (credentials: LoginCredentials): Promise<LoginResponse> => {
   const response = await apiClient.post('/admin/login', credentials);
   return response.data;
-}; 
\ No newline at end of file
+};
+
+export interface SSOProviders {
+  oidc: boolean;
+  cas: boolean;
+}
+
+export const getSSOProviders = async (): Promise<SSOProviders> => {
+  const response = await apiClient.get('/admin/sso/providers');
+  return response.data;
+};
+
+// The browser navigates to this URL, which redirects to the identity provider and back to /admin/sso-callback
+export const getSSOLoginURL = (provider: 'oidc' | 'cas'): string =>
+  `${apiClient.defaults.baseURL}/admin/sso/${provider}/login`;
@@ -0,0 +1,15 @@
+import apiClient from './axios';
+
+export interface Category {
+  ID: number;
+  Name: string;
+  Description: string;
+  IsActive: boolean;
+  SortOrder: number;
+  DefaultDepartmentID: number | null;
+}
+
+export const getCategories = async (): Promise<Category[]> => {
+  const response = await apiClient.get('/categories');
+  return response.data;
+};
@@ -0,0 +1,36 @@
+import apiClient from './axios';
+
+export interface Challenge {
+  mode: 'off' | 'pow' | 'captcha';
+  token?: string;
+  difficulty?: number;
+  image?: string;
+  expires_at?: string;
+}
+
+export const getChallenge = async (): Promise<Challenge> => {
+  const response = await apiClient.get('/challenge');
+  return response.data;
+};
+
+const leadingZeroBits = (bytes: Uint8Array) => {
+  let count = 0;
+  for (const byte of bytes) {
+    if (byte !== 0) {
+      return count + Math.clz32(byte) - 24;
+    }
+    count += 8;
+  }
+  return count;
+};
+
+// Finds a nonce such that SHA-256(token + ":" + nonce) starts with `difficulty` zero bits
+export const solveProofOfWork = async (token: string, difficulty: number): Promise<string> => {
+  const encoder = new TextEncoder();
+  for (let nonce = 0; ; nonce++) {
+    const digest = await crypto.subtle.digest('SHA-256', encoder.encode(`${token}:${nonce}`));
+    if (leadingZeroBits(new Uint8Array(digest)) >= difficulty) {
+      return String(nonce);
+    }
+  }
+};
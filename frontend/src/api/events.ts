@@ -0,0 +1,82 @@
+import apiClient from './axios';
+
+export type SuggestionEventType =
+  | 'suggestion.created'
+  | 'suggestion.updated'
+  | 'suggestion.replied'
+  | 'suggestion.deleted'
+  | 'suggestion.resolved';
+
+export interface SuggestionEvent {
+  id: number;
+  title?: string;
+  status?: string;
+  priority?: string;
+  department_id: number | null;
+  assignee_id: number | null;
+  is_overdue: boolean;
+  reply_id?: number;
+  updated_at: string;
+}
+
+const types: SuggestionEventType[] = ['suggestion.created', 'suggestion.updated', 'suggestion.replied', 'suggestion.deleted'];
+
+// Fetches a short-lived ticket for the event stream, as EventSource cannot send the admin token
+// and tokens in URLs end up in access logs.
+const getEventTicket = async (): Promise<string> => {
+  const token = localStorage.getItem('admin_token') || '';
+  const response = await apiClient.post('/admin/events/ticket', null, { headers: { Authorization: `Bearer ${token}` } });
+  return response.data.ticket;
+};
+
+// Subscribes to the admin event stream. EventSource reconnects by itself and sends
+// Last-Event-ID; once its ticket has expired the server refuses it, and a new ticket is
+// fetched to reconnect from the last event received. onReset is called when missed
+// events could not be replayed.
+export const subscribeAdminEvents = (
+  onEvent: (type: SuggestionEventType, event: SuggestionEvent) => void,
+  onReset?: () => void,
+) => {
+  let source: EventSource | null = null;
+  let lastEventId = '';
+  let closed = false;
+  let retryTimer: ReturnType<typeof setTimeout> | undefined;
+
+  const connect = async () => {
+    let ticket: string;
+    try {
+      ticket = await getEventTicket();
+    } catch {
+      if (!closed) retryTimer = setTimeout(connect, 3000);
+      return;
+    }
+    if (closed) return;
+
+    const params = new URLSearchParams({ ticket });
+    if (lastEventId) params.set('last_event_id', lastEventId);
+    const current = new EventSource(`${apiClient.defaults.baseURL}/admin/events?${params}`);
+    source = current;
+    types.forEach((type) => {
+      current.addEventListener(type, (e) => {
+        const message = e as MessageEvent;
+        lastEventId = message.lastEventId;
+        onEvent(type, JSON.parse(message.data));
+      });
+    });
+    if (onReset) {
+      current.addEventListener('reset', () => onReset());
+    }
+    current.onerror = () => {
+      if (current.readyState === EventSource.CLOSED && !closed) {
+        retryTimer = setTimeout(connect, 3000);
+      }
+    };
+  };
+  connect();
+
+  return () => {
+    closed = true;
+    clearTimeout(retryTimer);
+    source?.close();
+  };
+};
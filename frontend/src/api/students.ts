@@ -0,0 +1,31 @@
+import apiClient from './axios';
+
+export interface Student {
+  ID: number;
+  StudentNumber: string;
+  Name: string;
+  Class: string;
+  IsActive: boolean;
+}
+
+export interface StudentLoginResponse {
+  token: string;
+  student: Student;
+}
+
+const authHeaders = (token: string) => ({ headers: { Authorization: `Bearer ${token}` } });
+
+export const studentLogin = async (credentials: { student_number: string; password: string }): Promise<StudentLoginResponse> => {
+  const response = await apiClient.post('/student/login', credentials);
+  return response.data;
+};
+
+export const getStudentProfile = async (token: string): Promise<Student> => {
+  const response = await apiClient.get('/student/me', authHeaders(token));
+  return response.data;
+};
+
+export const changeStudentPassword = async (token: string, data: { old_password: string; new_password: string }) => {
+  const response = await apiClient.put('/student/password', data, authHeaders(token));
+  return response.data;
+};
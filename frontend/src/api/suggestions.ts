@@ -8,14 +8,34 @@ export interface SuggestionSubmission {
   submitter_name?: string;
   submitter_class?: string;
   is_public?: boolean;
+  contact_email?: string;
+  language?: 'zh' | 'en';
+  submitter_key?: string;
+  show_identity?: boolean;
+  challenge_token?: string;
+  challenge_solution?: string;
 }
 
 export interface SubmissionResponse {
   tracking_code: string;
+  submitter_key: string;
 }
 
-export const submitSuggestion = async (data: SuggestionSubmission): Promise<SubmissionResponse> => {
-  const response = await apiClient.post('/suggestions', data);
+export interface SubmitterSuggestion {
+  tracking_code: string;
+  title: string;
+  status: string;
+  department_name: string;
+  reply_count: number;
+  unread_reply_count: number;
+  created_at: string;
+  updated_at: string;
+}
+
+// Suggestions submitted with the token of a logged-in student are verified
+export const submitSuggestion = async (data: SuggestionSubmission, studentToken?: string | null): Promise<SubmissionResponse> => {
+  const config = studentToken ? { headers: { Authorization: `Bearer ${studentToken}` } } : undefined;
+  const response = await apiClient.post('/suggestions', data, config);
   return response.data;
 };
 
@@ -32,4 +52,58 @@ export const getPublicSuggestions = async (params: { page: number; pageSize: num
 export const upvoteSuggestion = async (id: number) => {
   const response = await apiClient.post(`/suggestions/${id}/upvote`);
   return response.data;
-}; 
\ No newline at end of file
+}; 
+export const removeUpvote = async (id: number) => {
+  const response = await apiClient.delete(`/suggestions/${id}/upvote`);
+  return response.data;
+};
+
+export type ReactionType = 'me_too' | 'disagree';
+
+export const reactToSuggestion = async (id: number, type: ReactionType) => {
+  const response = await apiClient.post(`/suggestions/${id}/reactions`, { type });
+  return response.data;
+};
+
+export const removeReaction = async (id: number) => {
+  const response = await apiClient.delete(`/suggestions/${id}/reactions`);
+  return response.data;
+};
+
+export const getSuggestionComments = async (params: { suggestion_id: number; page: number; pageSize: number }) => {
+  const response = await apiClient.get('/comments', { params });
+  return response.data;
+};
+
+export const submitComment = async (id: number, data: { nickname?: string; content: string }) => {
+  const response = await apiClient.post(`/suggestions/${id}/comments`, data);
+  return response.data;
+};
+
+export const rateSuggestion = async (trackingCode: string, data: { score: number; comment?: string }) => {
+  const response = await apiClient.post(`/tracking/${trackingCode}/rating`, data);
+  return response.data;
+};
+
+export const reopenSuggestion = async (trackingCode: string, reason: string) => {
+  const response = await apiClient.post(`/tracking/${trackingCode}/reopen`, { reason });
+  return response.data;
+};
+
+export const editSuggestion = async (
+  trackingCode: string,
+  data: { title: string; content: string; category: string; department_id: number },
+) => {
+  const response = await apiClient.put(`/tracking/${trackingCode}`, data);
+  return response.data;
+};
+
+export const withdrawSuggestion = async (trackingCode: string, reason?: string) => {
+  const response = await apiClient.post(`/tracking/${trackingCode}/withdraw`, { reason });
+  return response.data;
+};
+
+export const getSubmitterSuggestions = async (submitterKey: string): Promise<SubmitterSuggestion[]> => {
+  const response = await apiClient.get('/submitter/suggestions', { headers: { 'X-Submitter-Key': submitterKey } });
+  return response.data;
+};
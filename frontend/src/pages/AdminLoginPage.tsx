@@ -1,15 +1,22 @@
-import React from 'react';
-import { Form, Input, Button, Card, Layout, Typography, message, Space } from 'antd';
-import { UserOutlined, LockOutlined, MessageOutlined } from '@ant-design/icons';
+import React, { useEffect, useState } from 'react';
+import { Form, Input, Button, Card, Layout, Typography, message, Space, Divider } from 'antd';
+import { UserOutlined, LockOutlined, MessageOutlined, SafetyCertificateOutlined } from '@ant-design/icons';
 import { useNavigate } from 'react-router-dom';
-import { login } from '../api/auth';
-import type { LoginCredentials } from '../api/auth';
+import { login, getSSOProviders, getSSOLoginURL } from '../api/auth';
+import type { LoginCredentials, SSOProviders } from '../api/auth';
 
 const { Content } = Layout;
 const { Title, Text } = Typography;
 
 const AdminLoginPage: React.FC = () => {
   const navigate = useNavigate();
+  const [ssoProviders, setSSOProviders] = useState<SSOProviders>({ oidc: false, cas: false });
+
+  useEffect(() => {
+    getSSOProviders()
+      .then(setSSOProviders)
+      .catch(() => setSSOProviders({ oidc: false, cas: false }));
+  }, []);
 
   const onFinish = async (values: LoginCredentials) => {
     try {
@@ -65,6 +72,23 @@ const AdminLoginPage: React.FC = () => {
               </Button>
             </Form.Item>
           </Form>
+          {(ssoProviders.oidc || ssoProviders.cas) && (
+            <>
+              <Divider plain>或</Divider>
+              <Space direction="vertical" style={{ width: '100%' }}>
+                {ssoProviders.oidc && (
+                  <Button icon={<SafetyCertificateOutlined />} block size="large" href={getSSOLoginURL('oidc')}>
+                    统一身份认证登录
+                  </Button>
+                )}
+                {ssoProviders.cas && (
+                  <Button icon={<SafetyCertificateOutlined />} block size="large" href={getSSOLoginURL('cas')}>
+                    CAS 登录
+                  </Button>
+                )}
+              </Space>
+            </>
+          )}
         </Card>
       </Content>
     </Layout>
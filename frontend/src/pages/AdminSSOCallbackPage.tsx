@@ -0,0 +1,43 @@
+import React, { useEffect, useState } from 'react';
+import { Result, Button, Spin, Layout } from 'antd';
+import { useNavigate } from 'react-router-dom';
+
+const { Content } = Layout;
+
+// Receives the result of an SSO login from the backend in the URL fragment, which is never sent to servers
+const AdminSSOCallbackPage: React.FC = () => {
+  const navigate = useNavigate();
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    window.history.replaceState(null, '', window.location.pathname);
+
+    const token = params.get('token');
+    if (token) {
+      localStorage.setItem('admin_token', token);
+      navigate('/admin/dashboard', { replace: true });
+      return;
+    }
+    setError(params.get('error') || '登录失败，请重试。');
+  }, [navigate]);
+
+  return (
+    <Layout style={{ minHeight: '100vh' }}>
+      <Content style={{ display: 'flex', justifyContent: 'center', alignItems: 'center' }}>
+        {error ? (
+          <Result
+            status="error"
+            title="统一身份认证登录失败"
+            subTitle={error}
+            extra={<Button type="primary" onClick={() => navigate('/admin/login')}>返回登录</Button>}
+          />
+        ) : (
+          <Spin size="large" tip="正在登录..." />
+        )}
+      </Content>
+    </Layout>
+  );
+};
+
+export default AdminSSOCallbackPage;
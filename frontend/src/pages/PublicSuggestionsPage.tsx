@@ -102,6 +102,7 @@ const PublicSuggestionsPage: React.FC = () => {
                                         <Card
                                             hoverable
                                             title={item.Title}
+                                            extra={item.IsVerified && <Tag color="green">已验证</Tag>}
                                             bordered={false}
                                             style={{ 
                                                 boxShadow: '0 2px 8px rgba(0, 0, 0, 0.09)',
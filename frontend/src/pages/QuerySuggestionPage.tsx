@@ -12,10 +12,22 @@ import {
   Descriptions,
   Tag,
   Empty,
+  Rate,
+  List,
+  Badge,
+  message,
 } from 'antd';
 import { SearchOutlined } from '@ant-design/icons';
 import axios from 'axios';
-import { getSuggestionByCode } from '../api/suggestions';
+import {
+  getSuggestionByCode,
+  rateSuggestion,
+  reopenSuggestion,
+  editSuggestion,
+  withdrawSuggestion,
+  getSubmitterSuggestions,
+  SubmitterSuggestion,
+} from '../api/suggestions';
 
 const { Title, Paragraph, Text } = Typography;
 
@@ -24,6 +36,116 @@ const QuerySuggestionPage: React.FC = () => {
   const [queryLoading, setQueryLoading] = useState(false);
   const [isModalVisible, setIsModalVisible] = useState(false);
   const [currentSuggestion, setCurrentSuggestion] = useState<any>(null);
+  const [score, setScore] = useState(0);
+  const [feedback, setFeedback] = useState('');
+  const [feedbackLoading, setFeedbackLoading] = useState(false);
+  const [editForm] = Form.useForm();
+  const [isEditing, setIsEditing] = useState(false);
+  const [submitterKey, setSubmitterKey] = useState(localStorage.getItem('submitter_key') || '');
+  const [mySuggestions, setMySuggestions] = useState<SubmitterSuggestion[] | null>(null);
+  const [mineLoading, setMineLoading] = useState(false);
+
+  // The rating of the current resolution, if the student already gave one
+  const currentRating = currentSuggestion?.Ratings?.find(
+    (r: any) => r.ResolvedAt === currentSuggestion.ResolvedAt,
+  );
+  const canReopen =
+    currentSuggestion?.Status === '已解决' &&
+    currentSuggestion.ReopenableUntil &&
+    new Date(currentSuggestion.ReopenableUntil) > new Date() &&
+    (!currentRating || currentRating.Score <= 2);
+
+  const refresh = async () => {
+    setCurrentSuggestion(await getSuggestionByCode(currentSuggestion.TrackingCode));
+  };
+
+  const handleRate = async () => {
+    setFeedbackLoading(true);
+    try {
+      await rateSuggestion(currentSuggestion.TrackingCode, { score, comment: feedback });
+      message.success('感谢您的评价');
+      setFeedback('');
+      await refresh();
+    } catch (error) {
+      message.error(axios.isAxiosError(error) && error.response?.data?.error ? error.response.data.error : '评价失败');
+    } finally {
+      setFeedbackLoading(false);
+    }
+  };
+
+  const handleReopen = async () => {
+    if (!feedback.trim()) {
+      message.warning('请填写重新处理的理由');
+      return;
+    }
+    setFeedbackLoading(true);
+    try {
+      await reopenSuggestion(currentSuggestion.TrackingCode, feedback);
+      message.success('建议已重新提交处理');
+      setFeedback('');
+      await refresh();
+    } catch (error) {
+      message.error(axios.isAxiosError(error) && error.response?.data?.error ? error.response.data.error : '操作失败');
+    } finally {
+      setFeedbackLoading(false);
+    }
+  };
+
+  const startEdit = () => {
+    editForm.setFieldsValue({ title: currentSuggestion.Title, content: currentSuggestion.Content });
+    setIsEditing(true);
+  };
+
+  const handleEdit = async (values: { title: string; content: string }) => {
+    setFeedbackLoading(true);
+    try {
+      // Category and department are kept as submitted
+      await editSuggestion(currentSuggestion.TrackingCode, {
+        ...values,
+        category: currentSuggestion.Category,
+        department_id: currentSuggestion.DepartmentID ?? 0,
+      });
+      message.success('建议已修改');
+      setIsEditing(false);
+      await refresh();
+    } catch (error) {
+      message.error(axios.isAxiosError(error) && error.response?.data?.error ? error.response.data.error : '修改失败');
+    } finally {
+      setFeedbackLoading(false);
+    }
+  };
+
+  const handleWithdraw = () => {
+    Modal.confirm({
+      title: '确定撤回这条建议吗？',
+      content: '撤回后建议将不再处理，且无法恢复。',
+      okText: '撤回',
+      okType: 'danger',
+      cancelText: '取消',
+      onOk: async () => {
+        try {
+          await withdrawSuggestion(currentSuggestion.TrackingCode);
+          message.success('建议已撤回');
+          await refresh();
+        } catch (error) {
+          message.error(axios.isAxiosError(error) && error.response?.data?.error ? error.response.data.error : '撤回失败');
+        }
+      },
+    });
+  };
+
+  const loadMySuggestions = async () => {
+    if (!submitterKey.trim()) return;
+    setMineLoading(true);
+    try {
+      setMySuggestions(await getSubmitterSuggestions(submitterKey.trim()));
+      localStorage.setItem('submitter_key', submitterKey.trim());
+    } catch (error) {
+      message.error(axios.isAxiosError(error) && error.response?.data?.error ? error.response.data.error : '查询失败');
+    } finally {
+      setMineLoading(false);
+    }
+  };
 
   const onSearch = async (values: { code: string }) => {
     const { code } = values;
@@ -54,6 +176,8 @@ const QuerySuggestionPage: React.FC = () => {
 
   const handleModalClose = () => {
     setIsModalVisible(false);
+    setIsEditing(false);
+    if (mySuggestions) loadMySuggestions(); // Opened replies are no longer unread
     setCurrentSuggestion(null);
   };
 
@@ -91,6 +215,45 @@ const QuerySuggestionPage: React.FC = () => {
               </Form.Item>
             </Form>
           </Card>
+          <Card style={{ marginTop: 16 }}>
+            <Title level={5}>我的建议</Title>
+            <Paragraph type="secondary">输入提交建议后获得的提交者密钥，查看您提交的所有建议。</Paragraph>
+            <Input.Search
+              value={submitterKey}
+              onChange={(e) => setSubmitterKey(e.target.value)}
+              onSearch={loadMySuggestions}
+              loading={mineLoading}
+              enterButton="查看"
+              placeholder="提交者密钥"
+            />
+            {mySuggestions && (
+              <List
+                style={{ marginTop: 16 }}
+                dataSource={mySuggestions}
+                renderItem={(item) => (
+                  <List.Item
+                    actions={[
+                      <Button type="link" key="view" onClick={() => onSearch({ code: item.tracking_code })}>
+                        查看
+                      </Button>,
+                    ]}
+                  >
+                    <List.Item.Meta
+                      title={
+                        <Badge count={item.unread_reply_count} offset={[12, 0]}>
+                          {item.title}
+                        </Badge>
+                      }
+                      description={`${item.tracking_code} · ${item.department_name || '全部部门'} · ${new Date(
+                        item.created_at,
+                      ).toLocaleString()}`}
+                    />
+                    <Tag>{item.status}</Tag>
+                  </List.Item>
+                )}
+              />
+            )}
+          </Card>
         </Col>
       </Row>
 
@@ -100,6 +263,16 @@ const QuerySuggestionPage: React.FC = () => {
           visible={isModalVisible}
           onCancel={handleModalClose}
           footer={[
+            currentSuggestion.Status === '待审核' && !isEditing && (
+              <Button key="edit" onClick={startEdit}>
+                修改建议
+              </Button>
+            ),
+            currentSuggestion.Status !== '已撤回' && (
+              <Button key="withdraw" danger onClick={handleWithdraw}>
+                撤回建议
+              </Button>
+            ),
             <Button key="back" onClick={handleModalClose}>
               关闭
             </Button>,
@@ -121,6 +294,25 @@ const QuerySuggestionPage: React.FC = () => {
               {new Date(currentSuggestion.CreatedAt).toLocaleString()}
             </Descriptions.Item>
           </Descriptions>
+          {isEditing && (
+            <>
+              <Divider>修改建议</Divider>
+              <Form form={editForm} onFinish={handleEdit} layout="vertical">
+                <Form.Item name="title" label="标题" rules={[{ required: true, message: '标题不能为空' }]}>
+                  <Input maxLength={100} />
+                </Form.Item>
+                <Form.Item name="content" label="内容" rules={[{ required: true, message: '内容不能为空' }]}>
+                  <Input.TextArea rows={5} maxLength={3000} />
+                </Form.Item>
+                <Button type="primary" htmlType="submit" loading={feedbackLoading}>
+                  保存修改
+                </Button>
+                <Button onClick={() => setIsEditing(false)} style={{ marginLeft: 8 }}>
+                  取消
+                </Button>
+              </Form>
+            </>
+          )}
           <Divider>回复</Divider>
           {currentSuggestion.Replies && currentSuggestion.Replies.length > 0 ? (
             currentSuggestion.Replies.map((reply: any) => (
@@ -132,6 +324,42 @@ const QuerySuggestionPage: React.FC = () => {
           ) : (
             <Empty description="暂无回复" />
           )}
+          {currentSuggestion.Status === '已解决' && (
+            <>
+              <Divider>处理结果评价</Divider>
+              {currentRating ? (
+                <Paragraph>
+                  您的评价：<Rate disabled value={currentRating.Score} />
+                  {currentRating.Comment && <div>{currentRating.Comment}</div>}
+                </Paragraph>
+              ) : (
+                <Paragraph>
+                  <Rate value={score} onChange={setScore} />
+                </Paragraph>
+              )}
+              {(!currentRating || canReopen) && (
+                <Input.TextArea
+                  rows={3}
+                  value={feedback}
+                  onChange={(e) => setFeedback(e.target.value)}
+                  placeholder={currentRating ? '请说明需要重新处理的原因' : '选填：您对处理结果的意见'}
+                  maxLength={1000}
+                />
+              )}
+              <div style={{ marginTop: 12 }}>
+                {!currentRating && (
+                  <Button type="primary" onClick={handleRate} disabled={!score} loading={feedbackLoading}>
+                    提交评价
+                  </Button>
+                )}
+                {canReopen && (
+                  <Button danger onClick={handleReopen} loading={feedbackLoading} style={{ marginLeft: 8 }}>
+                    不满意，重新处理
+                  </Button>
+                )}
+              </div>
+            </>
+          )}
         </Modal>
       )}
     </>
@@ -16,17 +16,37 @@ import {
 import { SendOutlined, SmileOutlined } from '@ant-design/icons';
 import { getDepartments } from '../api/departments';
 import type { Department } from '../api/departments';
+import { getCategories } from '../api/categories';
+import type { Category } from '../api/categories';
+import { getChallenge, solveProofOfWork } from '../api/challenge';
+import type { Challenge } from '../api/challenge';
 import { submitSuggestion } from '../api/suggestions';
 import type { SuggestionSubmission } from '../api/suggestions';
+import { studentLogin, getStudentProfile } from '../api/students';
+import type { Student } from '../api/students';
 
 const { Title, Paragraph, Text } = Typography;
 const { Option } = Select;
 
 const SubmitSuggestionPage: React.FC = () => {
   const [departments, setDepartments] = useState<Department[]>([]);
+  const [categories, setCategories] = useState<Category[]>([]);
   const [form] = Form.useForm();
   const [loading, setLoading] = useState(false);
   const [trackingCode, setTrackingCode] = useState<string | null>(null);
+  const [submitterKey, setSubmitterKey] = useState<string | null>(null);
+  const [challenge, setChallenge] = useState<Challenge | null>(null);
+  const [student, setStudent] = useState<Student | null>(null);
+  const [loginVisible, setLoginVisible] = useState(false);
+  const [loginLoading, setLoginLoading] = useState(false);
+
+  const loadChallenge = async () => {
+    try {
+      setChallenge(await getChallenge());
+    } catch (error) {
+      console.error('Failed to fetch challenge', error);
+    }
+  };
 
   useEffect(() => {
     const fetchDepartments = async () => {
@@ -37,9 +57,45 @@ const SubmitSuggestionPage: React.FC = () => {
         console.error('Failed to fetch departments', error);
       }
     };
+    const fetchCategories = async () => {
+      try {
+        const data = await getCategories();
+        setCategories(data);
+      } catch (error) {
+        console.error('Failed to fetch categories', error);
+      }
+    };
     fetchDepartments();
+    fetchCategories();
+    loadChallenge();
+    // An expired student token is dropped, the student logs in again
+    const studentToken = localStorage.getItem('student_token');
+    if (studentToken) {
+      getStudentProfile(studentToken)
+        .then(setStudent)
+        .catch(() => localStorage.removeItem('student_token'));
+    }
   }, []);
 
+  const onStudentLogin = async (values: { student_number: string; password: string }) => {
+    setLoginLoading(true);
+    try {
+      const response = await studentLogin(values);
+      localStorage.setItem('student_token', response.token);
+      setStudent(response.student);
+      setLoginVisible(false);
+    } catch (error) {
+      Modal.error({ title: '登录失败', content: '学号或密码不正确。' });
+    } finally {
+      setLoginLoading(false);
+    }
+  };
+
+  const onStudentLogout = () => {
+    localStorage.removeItem('student_token');
+    setStudent(null);
+  };
+
   const onFinish = async (values: any) => {
     const SUBMISSION_LIMIT = 3;
     const SUBMISSION_PERIOD = 60000; // 1 minute in milliseconds
@@ -59,16 +115,27 @@ const SubmitSuggestionPage: React.FC = () => {
 
     setLoading(true);
     try {
+      const { captcha, ...fields } = values;
+      let challengeSolution: string | undefined = captcha;
+      if (challenge?.mode === 'pow' && challenge.token) {
+        challengeSolution = await solveProofOfWork(challenge.token, challenge.difficulty || 0);
+      }
       const submissionData: SuggestionSubmission = {
-        ...values,
+        ...fields,
         department_id: Number(values.department_id),
         is_public: values.is_public || false,
+        // Later submissions are bundled with the earlier ones of this browser
+        submitter_key: localStorage.getItem('submitter_key') || undefined,
+        challenge_token: challenge?.token,
+        challenge_solution: challengeSolution,
       };
-      const response = await submitSuggestion(submissionData);
+      const response = await submitSuggestion(submissionData, student ? localStorage.getItem('student_token') : null);
 
       const newTimestamps = [...recentTimestamps, now];
       localStorage.setItem('suggestion_timestamps', JSON.stringify(newTimestamps));
 
+      localStorage.setItem('submitter_key', response.submitter_key);
+      setSubmitterKey(response.submitter_key);
       setTrackingCode(response.tracking_code);
       form.resetFields();
     } catch (error) {
@@ -79,6 +146,9 @@ const SubmitSuggestionPage: React.FC = () => {
       });
     } finally {
       setLoading(false);
+      // Every challenge can be used only once
+      form.setFieldValue('captcha', undefined);
+      loadChallenge();
     }
   };
 
@@ -101,6 +171,11 @@ const SubmitSuggestionPage: React.FC = () => {
                   <Paragraph>
                     请妥善保管此查询码，您可以随时使用它来查询建议的处理进度和回复。
                   </Paragraph>
+                  <Paragraph>
+                    您的提交者密钥是: <Text strong copyable>{submitterKey}</Text>
+                    <br />
+                    凭此密钥可在查询页面查看您提交的所有建议，请勿泄露给他人。
+                  </Paragraph>
                 </>
               }
               extra={[
@@ -162,20 +237,72 @@ const SubmitSuggestionPage: React.FC = () => {
                   </Col>
                   <Col xs={24} sm={12}>
                     <Form.Item label="建议分类" name="category">
-                      <Input placeholder="例如：教学设施、课程安排、生活后勤等" />
+                      <Select placeholder="请选择建议分类" allowClear>
+                        {categories.map((cat) => (
+                          <Option key={cat.ID} value={cat.Name} title={cat.Description}>
+                            {cat.Name}
+                          </Option>
+                        ))}
+                      </Select>
                     </Form.Item>
                   </Col>
                 </Row>
                 <Divider>选填信息（有助于我们更好地与您联系）</Divider>
+                {student ? (
+                  <>
+                    <Paragraph>
+                      已登录学生账号：{student.Name}（{student.StudentNumber}），提交的建议将带有“已验证”标识。
+                      <Button type="link" onClick={onStudentLogout}>
+                        退出登录
+                      </Button>
+                    </Paragraph>
+                    <Form.Item
+                      name="show_identity"
+                      valuePropName="checked"
+                      help="不勾选时建议仍为匿名，管理员只能看到“已验证”标识，看不到您的身份。"
+                    >
+                      <Checkbox>向管理员公开我的姓名、班级和学号</Checkbox>
+                    </Form.Item>
+                  </>
+                ) : (
+                  <>
+                    <Paragraph>
+                      <Button type="link" onClick={() => setLoginVisible(true)} style={{ padding: 0 }}>
+                        登录学生账号
+                      </Button>
+                      后提交的建议将带有“已验证”标识，也可以不登录直接匿名提交。
+                    </Paragraph>
+                    <Row gutter={16}>
+                      <Col xs={24} sm={12}>
+                        <Form.Item label="您的姓名" name="submitter_name">
+                          <Input placeholder="您的姓名" />
+                        </Form.Item>
+                      </Col>
+                      <Col xs={24} sm={12}>
+                        <Form.Item label="您的班级" name="submitter_class">
+                          <Input placeholder="例如：软件工程2101班" />
+                        </Form.Item>
+                      </Col>
+                    </Row>
+                  </>
+                )}
                 <Row gutter={16}>
                   <Col xs={24} sm={12}>
-                    <Form.Item label="您的姓名" name="submitter_name">
-                      <Input placeholder="您的姓名" />
+                    <Form.Item
+                      label="联系邮箱"
+                      name="contact_email"
+                      rules={[{ type: 'email', message: '请输入有效的邮箱地址' }]}
+                      help="填写后，建议状态变更或收到回复时会邮件通知您，邮箱不会公开"
+                    >
+                      <Input placeholder="选填" />
                     </Form.Item>
                   </Col>
                   <Col xs={24} sm={12}>
-                    <Form.Item label="您的班级" name="submitter_class">
-                      <Input placeholder="例如：软件工程2101班" />
+                    <Form.Item label="通知语言" name="language" initialValue="zh">
+                      <Select>
+                        <Option value="zh">中文</Option>
+                        <Option value="en">English</Option>
+                      </Select>
                     </Form.Item>
                   </Col>
                 </Row>
@@ -186,6 +313,30 @@ const SubmitSuggestionPage: React.FC = () => {
                 >
                   <Checkbox>同意在建议处理完成后公示（匿名）</Checkbox>
                 </Form.Item>
+                {challenge?.mode === 'captcha' && (
+                  <Form.Item label="验证码" required>
+                    <Row gutter={8} align="middle">
+                      <Col flex="auto">
+                        <Form.Item
+                          name="captcha"
+                          noStyle
+                          rules={[{ required: true, message: '请输入图中算式的结果' }]}
+                        >
+                          <Input placeholder="请输入图中算式的结果" />
+                        </Form.Item>
+                      </Col>
+                      <Col>
+                        <img
+                          src={challenge.image}
+                          alt="验证码"
+                          title="看不清？点击换一张"
+                          style={{ cursor: 'pointer', height: 32 }}
+                          onClick={loadChallenge}
+                        />
+                      </Col>
+                    </Row>
+                  </Form.Item>
+                )}
                 <Form.Item>
                   <Button
                     type="primary"
@@ -202,6 +353,19 @@ const SubmitSuggestionPage: React.FC = () => {
             </>
           )}
         </Card>
+        <Modal title="学生登录" visible={loginVisible} onCancel={() => setLoginVisible(false)} footer={null} destroyOnClose>
+          <Form onFinish={onStudentLogin} layout="vertical">
+            <Form.Item label="学号" name="student_number" rules={[{ required: true, message: '请输入学号' }]}>
+              <Input />
+            </Form.Item>
+            <Form.Item label="密码" name="password" rules={[{ required: true, message: '请输入密码' }]}>
+              <Input.Password />
+            </Form.Item>
+            <Button type="primary" htmlType="submit" loading={loginLoading} block>
+              登录
+            </Button>
+          </Form>
+        </Modal>
       </Col>
     </Row>
   );
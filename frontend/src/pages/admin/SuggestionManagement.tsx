@@ -9,6 +9,7 @@ import {
 } from '../../api/admin';
 import type { Department } from '../../api/departments';
 import { getDepartments } from '../../api/departments';
+import { subscribeAdminEvents } from '../../api/events';
 import { ReloadOutlined, MessageOutlined } from '@ant-design/icons';
 import { jwtDecode } from 'jwt-decode';
 
@@ -99,6 +100,20 @@ const SuggestionManagement: React.FC = () => {
     fetchSuggestions(1, view, filters);
   }, [view, filters]);
 
+  // Refresh the current page when suggestions change instead of polling
+  useEffect(() => {
+    let timer: ReturnType<typeof setTimeout> | undefined;
+    const refresh = () => {
+      clearTimeout(timer);
+      timer = setTimeout(() => fetchSuggestions(pagination.current, view, filters), 500);
+    };
+    const unsubscribe = subscribeAdminEvents(refresh, refresh);
+    return () => {
+      clearTimeout(timer);
+      unsubscribe();
+    };
+  }, [view, filters, pagination.current]);
+
   const handleTableChange = (newPagination: any) => {
     fetchSuggestions(newPagination.current, view, filters);
   };
@@ -163,7 +178,17 @@ const SuggestionManagement: React.FC = () => {
 
   const columns = [
     { title: '标题', dataIndex: 'Title', key: 'title', width: 250 },
-    { title: '提交人', dataIndex: 'SubmitterName', key: 'submitter', render: (name: string) => name || '匿名' },
+    {
+      title: '提交人',
+      dataIndex: 'SubmitterName',
+      key: 'submitter',
+      render: (name: string, record: any) => (
+        <>
+          {name || '匿名'}
+          {record.IsVerified && <Tag color="green" style={{ marginLeft: 8 }}>已验证</Tag>}
+        </>
+      ),
+    },
     {
       title: '部门',
       dataIndex: 'Department',
@@ -244,7 +269,11 @@ const SuggestionManagement: React.FC = () => {
             <Descriptions bordered column={1} size="small">
                 <Descriptions.Item label="标题">{selectedSuggestion.Title}</Descriptions.Item>
                 <Descriptions.Item label="分类">{selectedSuggestion.Category || '无'}</Descriptions.Item>
-                <Descriptions.Item label="提交人">{selectedSuggestion.SubmitterName || '匿名'}</Descriptions.Item>
+                <Descriptions.Item label="提交人">
+                    {selectedSuggestion.SubmitterName || '匿名'}
+                    {selectedSuggestion.StudentNumber && `（学号 ${selectedSuggestion.StudentNumber}）`}
+                    {selectedSuggestion.IsVerified && <Tag color="green" style={{ marginLeft: 8 }}>已验证</Tag>}
+                </Descriptions.Item>
                 <Descriptions.Item label="提交班级">{selectedSuggestion.SubmitterClass || '无'}</Descriptions.Item>
                 <Descriptions.Item label="内容">{selectedSuggestion.Content}</Descriptions.Item>
             </Descriptions>
@@ -95,7 +95,19 @@ const UserManagement: React.FC = () => {
   };
   
   const columns = [
-    { title: '用户名', dataIndex: 'Username', key: 'username', render: (text: string) => <Space><UserOutlined />{text}</Space> },
+    {
+      title: '用户名',
+      dataIndex: 'Username',
+      key: 'username',
+      render: (text: string, record: any) => (
+        <Space>
+          <UserOutlined />{text}
+          {record.Identities?.map((identity: any) => (
+            <Tag key={identity.ID} color="cyan" title={identity.Email || identity.Subject}>{identity.Provider.toUpperCase()}</Tag>
+          ))}
+        </Space>
+      ),
+    },
     { 
       title: '角色', 
       dataIndex: 'Role', 